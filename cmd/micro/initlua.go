@@ -52,6 +52,11 @@ func luaImportMicro() *lua.LTable {
 		return action.MainTab().CurPane()
 	}))
 	ulua.L.SetField(pkg, "CurTab", luar.New(ulua.L, action.MainTab))
+	ulua.L.SetField(pkg, "Quickfix", luar.New(ulua.L, action.GetQuickfix))
+	ulua.L.SetField(pkg, "Pick", luar.New(ulua.L, action.Pick))
+	ulua.L.SetField(pkg, "SetQuickfix", luar.New(ulua.L, action.SetQuickfix))
+	ulua.L.SetField(pkg, "AddQuickfix", luar.New(ulua.L, action.AddQuickfix))
+	ulua.L.SetField(pkg, "ClearQuickfix", luar.New(ulua.L, action.ClearQuickfix))
 	ulua.L.SetField(pkg, "Tabs", luar.New(ulua.L, func() *action.TabList {
 		return action.Tabs
 	}))
@@ -84,6 +89,7 @@ func luaImportMicroConfig() *lua.LTable {
 	ulua.L.SetField(pkg, "RTColorscheme", luar.New(ulua.L, config.RTColorscheme))
 	ulua.L.SetField(pkg, "RTSyntax", luar.New(ulua.L, config.RTSyntax))
 	ulua.L.SetField(pkg, "RTHelp", luar.New(ulua.L, config.RTHelp))
+	ulua.L.SetField(pkg, "RTSnippet", luar.New(ulua.L, config.RTSnippet))
 	ulua.L.SetField(pkg, "RTPlugin", luar.New(ulua.L, config.RTPlugin))
 	ulua.L.SetField(pkg, "RegisterCommonOption", luar.New(ulua.L, config.RegisterCommonOptionPlug))
 	ulua.L.SetField(pkg, "RegisterGlobalOption", luar.New(ulua.L, config.RegisterGlobalOptionPlug))
@@ -120,6 +126,11 @@ func luaImportMicroBuffer() *lua.LTable {
 	ulua.L.SetField(pkg, "MTInfo", luar.New(ulua.L, buffer.MTInfo))
 	ulua.L.SetField(pkg, "MTWarning", luar.New(ulua.L, buffer.MTWarning))
 	ulua.L.SetField(pkg, "MTError", luar.New(ulua.L, buffer.MTError))
+	ulua.L.SetField(pkg, "VTInline", luar.New(ulua.L, buffer.VTInline))
+	ulua.L.SetField(pkg, "VTEndOfLine", luar.New(ulua.L, buffer.VTEndOfLine))
+	ulua.L.SetField(pkg, "NewQuickfixEntry", luar.New(ulua.L, buffer.NewQuickfixEntry))
+	ulua.L.SetField(pkg, "ParseErrorFormat", luar.New(ulua.L, buffer.ParseErrorFormat))
+	ulua.L.SetField(pkg, "RegisterCompleter", luar.New(ulua.L, buffer.RegisterCompleter))
 	ulua.L.SetField(pkg, "Loc", luar.New(ulua.L, func(x, y int) buffer.Loc {
 		return buffer.Loc{x, y}
 	}))
@@ -132,6 +143,7 @@ func luaImportMicroBuffer() *lua.LTable {
 	ulua.L.SetField(pkg, "BTScratch", luar.New(ulua.L, buffer.BTScratch.Kind))
 	ulua.L.SetField(pkg, "BTRaw", luar.New(ulua.L, buffer.BTRaw.Kind))
 	ulua.L.SetField(pkg, "BTInfo", luar.New(ulua.L, buffer.BTInfo.Kind))
+	ulua.L.SetField(pkg, "BTList", luar.New(ulua.L, buffer.BTList.Kind))
 	ulua.L.SetField(pkg, "NewBuffer", luar.New(ulua.L, func(text, path string) *buffer.Buffer {
 		return buffer.NewBufferFromString(text, path, buffer.BTDefault)
 	}))
@@ -282,9 +282,10 @@ func exit(rc int) {
 			b.Fini()
 		}
 	}
+	action.ShutdownLSP()
 
 	if screen.Screen != nil {
-		screen.Screen.Fini()
+		screen.Fini()
 	}
 
 	os.Exit(rc)
@@ -369,7 +370,7 @@ func main() {
 	defer func() {
 		if err := recover(); err != nil {
 			if screen.Screen != nil {
-				screen.Screen.Fini()
+				screen.Fini()
 			}
 			if e, ok := err.(*lua.ApiError); ok {
 				fmt.Println("Lua API error:", e)
@@ -412,7 +413,7 @@ func main() {
 
 	if len(b) == 0 {
 		// No buffers to open
-		screen.Screen.Fini()
+		screen.Fini()
 		runtime.Goexit()
 	}
 
@@ -441,6 +442,7 @@ func main() {
 	if a := config.GetGlobalOption("autosave").(float64); a > 0 {
 		config.SetAutoTime(a)
 	}
+	config.StartColorschemeWatcher()
 
 	screen.Events = make(chan tcell.Event)
 
@@ -506,6 +508,10 @@ func DoEvent() {
 		for _, b := range buffer.OpenBuffers {
 			b.AutoSave()
 		}
+	case <-config.ColorschemeModified:
+		if err := config.ReloadColorscheme(); err != nil {
+			action.InfoBar.Error(err)
+		}
 	case <-shell.CloseTerms:
 		action.Tabs.CloseTerms()
 	case event = <-screen.Events:
@@ -531,6 +537,13 @@ func DoEvent() {
 		return
 	}
 
+	if background, ok := screen.BackgroundChange(event); ok {
+		if err := config.SetTermBackground(background); err != nil {
+			action.InfoBar.Error(err)
+		}
+		return
+	}
+
 	if event != nil {
 		_, resize := event.(*tcell.EventResize)
 		if resize {
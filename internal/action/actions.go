@@ -7,6 +7,7 @@ import (
 	"os"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -702,6 +703,16 @@ func (h *BufPane) InsertNewline() bool {
 	if h.Buf.Settings["autoindent"].(bool) {
 		if cx < len(ws) {
 			ws = ws[0:cx]
+		} else if indent, ok := h.Buf.SmartIndent(h.Cursor.Y); ok {
+			ws = []byte(indent)
+			if h.Buf.IndentIncreases(h.Cursor.Y-1) && h.Buf.IndentDecreases(h.Cursor.Y) {
+				// put the closing text after the cursor on its own line, for
+				// example when the cursor is between braces
+				inner := indent + h.Buf.IndentString(util.IntOpt(h.Buf.Settings["tabsize"]))
+				h.Buf.Insert(h.Cursor.Loc, inner+"\n"+indent)
+				h.Cursor.GotoLoc(buffer.Loc{X: util.CharacterCountInString(inner), Y: h.Cursor.Y - 1})
+				ws = nil
+			}
 		}
 		h.Buf.Insert(h.Cursor.Loc, string(ws))
 		// for i := 0; i < len(ws); i++ {
@@ -843,6 +854,65 @@ func (h *BufPane) IndentSelection() bool {
 	return false
 }
 
+// Reindent indents the selected lines, or the current line, with the
+// indentation rules of the filetype
+func (h *BufPane) Reindent() bool {
+	startY, endY := h.Cursor.Y, h.Cursor.Y
+	if h.Cursor.HasSelection() {
+		start, end := h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if end.LessThan(start) {
+			start, end = end, start
+		}
+		startY, endY = start.Y, end.Move(-1, h.Buf).Y
+	}
+	if !h.Buf.Reindent(startY, endY) {
+		InfoBar.Error("No indentation rules for the filetype ", h.Buf.Settings["filetype"])
+		return false
+	}
+	h.Buf.RelocateCursors()
+	h.Relocate()
+	return true
+}
+
+// ToggleComment comments the lines of the selections, or the lines of the
+// cursors without a selection, with the comment strings of the syntax, or
+// uncomments them if they are all commented
+func (h *BufPane) ToggleComment() bool {
+	var ranges [][2]int
+	for _, c := range h.Buf.GetCursors() {
+		startY, endY := c.Y, c.Y
+		if c.HasSelection() {
+			start, end := c.CurSelection[0], c.CurSelection[1]
+			if end.LessThan(start) {
+				start, end = end, start
+			}
+			startY, endY = start.Y, end.Move(-1, h.Buf).Y
+		}
+		ranges = append(ranges, [2]int{startY, endY})
+	}
+
+	// merge the overlapping ranges so that each line is toggled once
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			last[1] = util.Max(last[1], r[1])
+		} else {
+			merged = append(merged, r)
+		}
+	}
+
+	if !h.Buf.ToggleComments(merged) {
+		return false
+	}
+	h.Buf.RelocateCursors()
+	h.Relocate()
+	return true
+}
+
 // IndentLine moves the current line forward one indentation
 func (h *BufPane) IndentLine() bool {
 	if h.Cursor.HasSelection() {
@@ -903,7 +973,9 @@ func (h *BufPane) OutdentSelection() bool {
 	return false
 }
 
-// Autocomplete cycles the suggestions and performs autocompletion if there are suggestions
+// Autocomplete opens the autocompletion menu, or selects its next
+// suggestion if it is already open. The suggestion is inserted directly if
+// it is the only one.
 func (h *BufPane) Autocomplete() bool {
 	b := h.Buf
 
@@ -911,6 +983,10 @@ func (h *BufPane) Autocomplete() bool {
 		return false
 	}
 
+	if b.Menu != nil {
+		b.Menu.Move(1)
+		return true
+	}
 	if b.HasSuggestions {
 		b.CycleAutocomplete(true)
 		return true
@@ -926,15 +1002,60 @@ func (h *BufPane) Autocomplete() bool {
 		return false
 	}
 
-	return b.Autocomplete(buffer.BufferComplete)
+	if !b.OpenCompletionMenu() {
+		return false
+	}
+	if len(b.Menu.Items) == 1 {
+		b.AcceptCompletion()
+		h.Cursor = b.GetActiveCursor()
+		h.Relocate()
+	}
+	return true
 }
 
-// CycleAutocompleteBack cycles back in the autocomplete suggestion list
+// ExpandSnippet inserts the snippet named by the word before the cursor
+func (h *BufPane) ExpandSnippet() bool {
+	if !h.Buf.ExpandSnippet() {
+		return false
+	}
+	h.Cursor = h.Buf.GetActiveCursor()
+	h.Relocate()
+	return true
+}
+
+// NextSnippetStop moves the cursor to the next tabstop of the snippet
+// being filled in
+func (h *BufPane) NextSnippetStop() bool {
+	if !h.Buf.NextSnippetStop() {
+		return false
+	}
+	h.Cursor = h.Buf.GetActiveCursor()
+	h.Relocate()
+	return true
+}
+
+// PrevSnippetStop moves the cursor to the previous tabstop of the snippet
+// being filled in
+func (h *BufPane) PrevSnippetStop() bool {
+	if !h.Buf.PrevSnippetStop() {
+		return false
+	}
+	h.Cursor = h.Buf.GetActiveCursor()
+	h.Relocate()
+	return true
+}
+
+// CycleAutocompleteBack selects the previous suggestion of the
+// autocompletion menu
 func (h *BufPane) CycleAutocompleteBack() bool {
 	if h.Cursor.HasSelection() {
 		return false
 	}
 
+	if h.Buf.Menu != nil {
+		h.Buf.Menu.Move(-1)
+		return true
+	}
 	if h.Buf.HasSuggestions {
 		h.Buf.CycleAutocomplete(false)
 		return true
@@ -1109,6 +1230,8 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 		h.GotoLoc(h.Cursor.CurSelection[1])
 		h.Buf.LastSearch = str
 		h.Buf.LastSearchRegex = useRegex
+		h.Buf.LastSearchCaseToggled = false
+		h.Buf.LastSearchWholeWord = false
 		h.Buf.HighlightSearch = h.Buf.Settings["hlsearch"].(bool)
 	} else {
 		h.Cursor.ResetSelection()
@@ -1116,16 +1239,78 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 	return nil
 }
 
+// findFlags holds the options of the find prompt while it is open, so that
+// they can be toggled from the command bar
+type findFlags struct {
+	h         *BufPane
+	useRegex  bool
+	matchCase bool
+	// caseToggled is set once the case sensitivity has been toggled, the
+	// search then no longer follows the ignorecase option
+	caseToggled bool
+	wholeWord   bool
+	// update refreshes the prompt after an option has been toggled
+	update func()
+}
+
+// curFind is the state of the find prompt, nil if it is not open
+var curFind *findFlags
+
+// prompt returns the prompt of the find prompt, listing the active options
+func (f *findFlags) prompt() string {
+	var flags []string
+	if f.useRegex {
+		flags = append(flags, "regex")
+	}
+	if f.matchCase {
+		flags = append(flags, "case-sensitive")
+	}
+	if f.wholeWord {
+		flags = append(flags, "whole word")
+	}
+	if len(flags) == 0 {
+		return "Find: "
+	}
+	return "Find (" + strings.Join(flags, ", ") + "): "
+}
+
+// search finds the next match of the given search string from the location
+// where the find prompt was opened
+func (f *findFlags) search(s string) ([2]buffer.Loc, bool, error) {
+	b := f.h.Buf
+	s, useRegex := buffer.SearchPattern(s, f.useRegex, f.matchCase, f.wholeWord)
+	return b.FindNext(s, b.Start(), b.End(), f.h.searchOrig, true, useRegex)
+}
+
 func (h *BufPane) find(useRegex bool) bool {
 	h.searchOrig = h.Cursor.Loc
-	prompt := "Find: "
-	if useRegex {
-		prompt = "Find (regex): "
+	f := &findFlags{h: h, useRegex: useRegex, matchCase: !h.Buf.Settings["ignorecase"].(bool)}
+
+	// the last search is changed while typing so that the search
+	// statusline fields show the matches of the current input
+	lastSearch := h.Buf.LastSearch
+	lastSearchRegex := h.Buf.LastSearchRegex
+	lastSearchMatchCase := h.Buf.LastSearchMatchCase
+	lastSearchCaseToggled := h.Buf.LastSearchCaseToggled
+	lastSearchWholeWord := h.Buf.LastSearchWholeWord
+	restoreSearch := func() {
+		h.Buf.LastSearch = lastSearch
+		h.Buf.LastSearchRegex = lastSearchRegex
+		h.Buf.LastSearchMatchCase = lastSearchMatchCase
+		h.Buf.LastSearchCaseToggled = lastSearchCaseToggled
+		h.Buf.LastSearchWholeWord = lastSearchWholeWord
 	}
+
 	var eventCallback func(resp string)
 	if h.Buf.Settings["incsearch"].(bool) {
 		eventCallback = func(resp string) {
-			match, found, _ := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, useRegex)
+			h.Buf.LastSearch = resp
+			h.Buf.LastSearchRegex = f.useRegex
+			h.Buf.LastSearchMatchCase = f.matchCase
+			h.Buf.LastSearchCaseToggled = f.caseToggled
+			h.Buf.LastSearchWholeWord = f.wholeWord
+
+			match, found, _ := f.search(resp)
 			if found {
 				h.Cursor.SetSelectionStart(match[0])
 				h.Cursor.SetSelectionEnd(match[1])
@@ -1140,9 +1325,13 @@ func (h *BufPane) find(useRegex bool) bool {
 	}
 	findCallback := func(resp string, canceled bool) {
 		// Finished callback
+		if curFind == f {
+			curFind = nil
+		}
 		if !canceled {
-			match, found, err := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, useRegex)
+			match, found, err := f.search(resp)
 			if err != nil {
+				restoreSearch()
 				InfoBar.Error(err)
 			} else if found {
 				h.Cursor.SetSelectionStart(match[0])
@@ -1151,16 +1340,28 @@ func (h *BufPane) find(useRegex bool) bool {
 				h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
 				h.GotoLoc(h.Cursor.CurSelection[1])
 				h.Buf.LastSearch = resp
-				h.Buf.LastSearchRegex = useRegex
+				h.Buf.LastSearchRegex = f.useRegex
+				h.Buf.LastSearchMatchCase = f.matchCase
+				h.Buf.LastSearchCaseToggled = f.caseToggled
+				h.Buf.LastSearchWholeWord = f.wholeWord
 				h.Buf.HighlightSearch = h.Buf.Settings["hlsearch"].(bool)
 			} else {
+				restoreSearch()
 				h.Cursor.ResetSelection()
 				InfoBar.Message("No matches found")
 			}
 		} else {
+			restoreSearch()
 			h.Cursor.ResetSelection()
 		}
 	}
+	f.update = func() {
+		InfoBar.Msg = f.prompt()
+		if eventCallback != nil {
+			eventCallback(string(InfoBar.LineBytes(0)))
+		}
+	}
+
 	pattern := string(h.Cursor.GetSelection())
 	if useRegex && pattern != "" {
 		pattern = regexp.QuoteMeta(pattern)
@@ -1168,7 +1369,8 @@ func (h *BufPane) find(useRegex bool) bool {
 	if eventCallback != nil && pattern != "" {
 		eventCallback(pattern)
 	}
-	InfoBar.Prompt(prompt, pattern, "Find", eventCallback, findCallback)
+	InfoBar.Prompt(f.prompt(), pattern, "Find", eventCallback, findCallback)
+	curFind = f
 	if pattern != "" {
 		InfoBar.SelectAll()
 	}
@@ -1212,7 +1414,8 @@ func (h *BufPane) FindNext() bool {
 	if h.Cursor.HasSelection() {
 		searchLoc = h.Cursor.CurSelection[1]
 	}
-	match, found, err := h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, true, h.Buf.LastSearchRegex)
+	search, useRegex := h.Buf.LastSearchPattern()
+	match, found, err := h.Buf.FindNext(search, h.Buf.Start(), h.Buf.End(), searchLoc, true, useRegex)
 	if err != nil {
 		InfoBar.Error(err)
 	} else if found && searchLoc == match[0] && match[0] == match[1] {
@@ -1222,7 +1425,7 @@ func (h *BufPane) FindNext() bool {
 		} else {
 			searchLoc = searchLoc.Move(1, h.Buf)
 		}
-		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, true, h.Buf.LastSearchRegex)
+		match, found, _ = h.Buf.FindNext(search, h.Buf.Start(), h.Buf.End(), searchLoc, true, useRegex)
 	}
 	if found {
 		h.Cursor.SetSelectionStart(match[0])
@@ -1249,7 +1452,8 @@ func (h *BufPane) FindPrevious() bool {
 	if h.Cursor.HasSelection() {
 		searchLoc = h.Cursor.CurSelection[0]
 	}
-	match, found, err := h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, false, h.Buf.LastSearchRegex)
+	search, useRegex := h.Buf.LastSearchPattern()
+	match, found, err := h.Buf.FindNext(search, h.Buf.Start(), h.Buf.End(), searchLoc, false, useRegex)
 	if err != nil {
 		InfoBar.Error(err)
 	} else if found && searchLoc == match[0] && match[0] == match[1] {
@@ -1259,7 +1463,7 @@ func (h *BufPane) FindPrevious() bool {
 		} else {
 			searchLoc = searchLoc.Move(-1, h.Buf)
 		}
-		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, false, h.Buf.LastSearchRegex)
+		match, found, _ = h.Buf.FindNext(search, h.Buf.Start(), h.Buf.End(), searchLoc, false, useRegex)
 	}
 	if found {
 		h.Cursor.SetSelectionStart(match[0])
@@ -1874,6 +2078,7 @@ func (h *BufPane) ToggleOverwriteMode() bool {
 
 // Escape leaves current mode
 func (h *BufPane) Escape() bool {
+	h.Buf.ExitSnippet()
 	return true
 }
 
@@ -1898,13 +2103,16 @@ func (h *BufPane) ClearInfo() bool {
 // ForceQuit closes the tab or view even if there are unsaved changes
 // (no prompt)
 func (h *BufPane) ForceQuit() bool {
+	if h.quitCallback != nil {
+		h.quitCallback()
+	}
 	h.Buf.Close()
 	if len(h.tab.Panes) > 1 {
 		h.Unsplit()
 	} else if len(Tabs.List) > 1 {
 		Tabs.RemoveTab(h.splitID)
 	} else {
-		screen.Screen.Fini()
+		screen.Fini()
 		InfoBar.Close()
 		runtime.Goexit()
 	}
@@ -1954,7 +2162,7 @@ func (h *BufPane) QuitAll() bool {
 
 	quit := func() {
 		buffer.CloseOpenBuffers()
-		screen.Screen.Fini()
+		screen.Fini()
 		InfoBar.Close()
 		runtime.Goexit()
 	}
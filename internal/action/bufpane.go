@@ -43,6 +43,7 @@ func BufMouseActionGeneral(a BufMouseAction) PaneMouseAction {
 
 func init() {
 	BufBindings = NewKeyTree()
+	paletteActions = BufKeyActions
 }
 
 // LuaAction makes an action from a lua function. It returns either a BufKeyAction
@@ -250,6 +251,11 @@ type BufPane struct {
 	// remember original location of a search in case the search is canceled
 	searchOrig buffer.Loc
 
+	// quitCallback is called when the user closes the pane with the Quit or
+	// ForceQuit action, but not when the buffer is closed otherwise, for
+	// example when micro exits or another buffer is opened in the pane
+	quitCallback func()
+
 	// The pane may not yet be fully initialized after its creation
 	// since we may not know the window geometry yet. In such case we finish
 	// its initialization a bit later, after the initial resize.
@@ -289,6 +295,7 @@ func NewBufPaneFromBuf(buf *buffer.Buffer, tab *Tab) *BufPane {
 func (h *BufPane) finishInitialize() {
 	h.initialRelocate()
 	h.initialized = true
+	lspAttach(h.Buf)
 
 	err := config.RunPluginFn("onBufPaneOpen", luar.New(ulua.L, h))
 	if err != nil {
@@ -346,11 +353,13 @@ func (h *BufPane) resetMouse() {
 // OpenBuffer opens the given buffer in this pane.
 func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
 	h.Buf.Close()
+	h.quitCallback = nil
 	h.Buf = b
 	h.BWindow.SetBuffer(b)
 	h.Cursor = b.GetActiveCursor()
 	h.Resize(h.GetView().Width, h.GetView().Height)
 	h.initialRelocate()
+	lspAttach(b)
 	// Set mouseReleased to true because we assume the mouse is not being
 	// pressed when the editor is opened
 	h.resetMouse()
@@ -459,6 +468,9 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 		h.paste(e.Text())
 		h.Relocate()
 	case *tcell.EventKey:
+		if h.Buf.Menu != nil && h.completionMenuKey(e) {
+			break
+		}
 		ke := keyEvent(e)
 
 		done := h.DoKeyEvent(ke)
@@ -526,6 +538,29 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 	}
 }
 
+// completionMenuKey handles the keys moving in the autocompletion menu,
+// accepting and closing it. It returns false for the other keys.
+func (h *BufPane) completionMenuKey(e *tcell.EventKey) bool {
+	if e.Modifiers() != tcell.ModNone {
+		return false
+	}
+	switch e.Key() {
+	case tcell.KeyUp:
+		h.Buf.Menu.Move(-1)
+	case tcell.KeyDown:
+		h.Buf.Menu.Move(1)
+	case tcell.KeyEnter:
+		h.Buf.AcceptCompletion()
+		h.Cursor = h.Buf.GetActiveCursor()
+		h.Relocate()
+	case tcell.KeyEscape:
+		h.Buf.CloseCompletionMenu()
+	default:
+		return false
+	}
+	return true
+}
+
 // Bindings returns the current bindings tree for this buffer.
 func (h *BufPane) Bindings() *KeyTree {
 	if h.bindings != nil {
@@ -555,6 +590,9 @@ func (h *BufPane) DoKeyEvent(e Event) bool {
 func (h *BufPane) execAction(action BufAction, name string, te *tcell.EventMouse) bool {
 	if name != "Autocomplete" && name != "CycleAutocompleteBack" {
 		h.Buf.HasSuggestions = false
+		if name != "Backspace" {
+			h.Buf.CloseCompletionMenu()
+		}
 	}
 
 	if !h.PluginCB("pre"+name, te) {
@@ -569,6 +607,9 @@ func (h *BufPane) execAction(action BufAction, name string, te *tcell.EventMouse
 		success = a(h, te)
 	}
 	success = success && h.PluginCB("on"+name, te)
+	if name == "Backspace" {
+		h.Buf.UpdateCompletionMenu()
+	}
 
 	if _, ok := MultiActions[name]; ok {
 		if recordingMacro {
@@ -639,12 +680,21 @@ func (h *BufPane) DoRuneInsert(r rune) {
 		} else {
 			h.Buf.Insert(c.Loc, string(r))
 		}
+		// dedent the line when the typed text matches the decrease rule of
+		// the indentation rules, for example a closing brace
+		if h.Buf.Settings["autoindent"].(bool) && h.Buf.IndentDecreases(c.Y) {
+			ws := util.GetLeadingWhitespace(h.Buf.LineBytes(c.Y))
+			if indent, ok := h.Buf.SmartIndent(c.Y); ok && len(indent) < len(ws) {
+				h.Buf.SetIndent(c.Y, indent)
+			}
+		}
 		if recordingMacro {
 			curmacro = append(curmacro, r)
 		}
 		h.Relocate()
 		h.PluginCB("onRune", string(r))
 	}
+	h.Buf.UpdateCompletionMenu()
 }
 
 // VSplitIndex opens the given buffer in a vertical split on the given side.
@@ -784,6 +834,8 @@ var BufKeyActions = map[string]BufKeyAction{
 	"DeleteLine":                (*BufPane).DeleteLine,
 	"MoveLinesUp":               (*BufPane).MoveLinesUp,
 	"MoveLinesDown":             (*BufPane).MoveLinesDown,
+	"Reindent":                  (*BufPane).Reindent,
+	"ToggleComment":             (*BufPane).ToggleComment,
 	"IndentSelection":           (*BufPane).IndentSelection,
 	"OutdentSelection":          (*BufPane).OutdentSelection,
 	"Autocomplete":              (*BufPane).Autocomplete,
@@ -848,6 +900,22 @@ var BufKeyActions = map[string]BufKeyAction{
 	"SkipMultiCursorBack":       (*BufPane).SkipMultiCursorBack,
 	"JumpToMatchingBrace":       (*BufPane).JumpToMatchingBrace,
 	"JumpLine":                  (*BufPane).JumpLine,
+	"FindFile":                  (*BufPane).FindFile,
+	"CommandPalette":            (*BufPane).CommandPalette,
+	"PickColorscheme":           (*BufPane).PickColorscheme,
+	"ToggleFileTree":            (*BufPane).ToggleFileTree,
+	"LSPHover":                  (*BufPane).LSPHover,
+	"LSPDefinition":             (*BufPane).LSPDefinition,
+	"LSPReferences":             (*BufPane).LSPReferences,
+	"LSPRename":                 (*BufPane).LSPRename,
+	"LSPFormat":                 (*BufPane).LSPFormat,
+	"ExpandSnippet":             (*BufPane).ExpandSnippet,
+	"NextSnippetStop":           (*BufPane).NextSnippetStop,
+	"PrevSnippetStop":           (*BufPane).PrevSnippetStop,
+	"JumpToTag":                 (*BufPane).JumpToTag,
+	"PopTag":                    (*BufPane).PopTag,
+	"QuickfixNext":              (*BufPane).QuickfixNext,
+	"QuickfixPrev":              (*BufPane).QuickfixPrev,
 	"Deselect":                  (*BufPane).Deselect,
 	"ClearInfo":                 (*BufPane).ClearInfo,
 	"None":                      (*BufPane).None,
@@ -917,6 +985,7 @@ var MultiActions = map[string]bool{
 	"DeleteLine":                true,
 	"MoveLinesUp":               true,
 	"MoveLinesDown":             true,
+	"Reindent":                  true,
 	"IndentSelection":           true,
 	"OutdentSelection":          true,
 	"OutdentLine":               true,
@@ -32,41 +32,51 @@ var commands map[string]Command
 
 func InitCommands() {
 	commands = map[string]Command{
-		"set":         {(*BufPane).SetCmd, OptionValueComplete},
-		"setlocal":    {(*BufPane).SetLocalCmd, OptionValueComplete},
-		"toggle":      {(*BufPane).ToggleCmd, OptionValueComplete},
-		"togglelocal": {(*BufPane).ToggleLocalCmd, OptionValueComplete},
-		"reset":       {(*BufPane).ResetCmd, OptionValueComplete},
-		"show":        {(*BufPane).ShowCmd, OptionComplete},
-		"showkey":     {(*BufPane).ShowKeyCmd, nil},
-		"run":         {(*BufPane).RunCmd, nil},
-		"bind":        {(*BufPane).BindCmd, nil},
-		"unbind":      {(*BufPane).UnbindCmd, nil},
-		"quit":        {(*BufPane).QuitCmd, nil},
-		"goto":        {(*BufPane).GotoCmd, nil},
-		"jump":        {(*BufPane).JumpCmd, nil},
-		"save":        {(*BufPane).SaveCmd, nil},
-		"replace":     {(*BufPane).ReplaceCmd, nil},
-		"replaceall":  {(*BufPane).ReplaceAllCmd, nil},
-		"vsplit":      {(*BufPane).VSplitCmd, buffer.FileComplete},
-		"hsplit":      {(*BufPane).HSplitCmd, buffer.FileComplete},
-		"tab":         {(*BufPane).NewTabCmd, buffer.FileComplete},
-		"help":        {(*BufPane).HelpCmd, HelpComplete},
-		"eval":        {(*BufPane).EvalCmd, nil},
-		"log":         {(*BufPane).ToggleLogCmd, nil},
-		"plugin":      {(*BufPane).PluginCmd, PluginComplete},
-		"reload":      {(*BufPane).ReloadCmd, nil},
-		"reopen":      {(*BufPane).ReopenCmd, nil},
-		"cd":          {(*BufPane).CdCmd, buffer.FileComplete},
-		"pwd":         {(*BufPane).PwdCmd, nil},
-		"open":        {(*BufPane).OpenCmd, buffer.FileComplete},
-		"tabmove":     {(*BufPane).TabMoveCmd, nil},
-		"tabswitch":   {(*BufPane).TabSwitchCmd, nil},
-		"term":        {(*BufPane).TermCmd, nil},
-		"memusage":    {(*BufPane).MemUsageCmd, nil},
-		"retab":       {(*BufPane).RetabCmd, nil},
-		"raw":         {(*BufPane).RawCmd, nil},
-		"textfilter":  {(*BufPane).TextFilterCmd, nil},
+		"set":            {(*BufPane).SetCmd, OptionValueComplete},
+		"setlocal":       {(*BufPane).SetLocalCmd, OptionValueComplete},
+		"toggle":         {(*BufPane).ToggleCmd, OptionValueComplete},
+		"togglelocal":    {(*BufPane).ToggleLocalCmd, OptionValueComplete},
+		"reset":          {(*BufPane).ResetCmd, OptionValueComplete},
+		"show":           {(*BufPane).ShowCmd, OptionComplete},
+		"showkey":        {(*BufPane).ShowKeyCmd, nil},
+		"run":            {(*BufPane).RunCmd, nil},
+		"bind":           {(*BufPane).BindCmd, nil},
+		"unbind":         {(*BufPane).UnbindCmd, nil},
+		"quit":           {(*BufPane).QuitCmd, nil},
+		"goto":           {(*BufPane).GotoCmd, nil},
+		"jump":           {(*BufPane).JumpCmd, nil},
+		"save":           {(*BufPane).SaveCmd, nil},
+		"replace":        {(*BufPane).ReplaceCmd, nil},
+		"replaceall":     {(*BufPane).ReplaceAllCmd, nil},
+		"vsplit":         {(*BufPane).VSplitCmd, buffer.FileComplete},
+		"hsplit":         {(*BufPane).HSplitCmd, buffer.FileComplete},
+		"tab":            {(*BufPane).NewTabCmd, buffer.FileComplete},
+		"help":           {(*BufPane).HelpCmd, HelpComplete},
+		"eval":           {(*BufPane).EvalCmd, nil},
+		"log":            {(*BufPane).ToggleLogCmd, nil},
+		"plugin":         {(*BufPane).PluginCmd, PluginComplete},
+		"reload":         {(*BufPane).ReloadCmd, nil},
+		"reopen":         {(*BufPane).ReopenCmd, nil},
+		"cd":             {(*BufPane).CdCmd, buffer.FileComplete},
+		"pwd":            {(*BufPane).PwdCmd, nil},
+		"open":           {(*BufPane).OpenCmd, buffer.FileComplete},
+		"tabmove":        {(*BufPane).TabMoveCmd, nil},
+		"tabswitch":      {(*BufPane).TabSwitchCmd, nil},
+		"term":           {(*BufPane).TermCmd, nil},
+		"memusage":       {(*BufPane).MemUsageCmd, nil},
+		"retab":          {(*BufPane).RetabCmd, nil},
+		"raw":            {(*BufPane).RawCmd, nil},
+		"textfilter":     {(*BufPane).TextFilterCmd, nil},
+		"git":            {(*BufPane).GitCmd, nil},
+		"grep":           {(*BufPane).GrepCmd, buffer.FileComplete},
+		"quickfix":       {(*BufPane).QuickfixCmd, nil},
+		"replaceinfiles": {(*BufPane).ReplaceInFilesCmd, nil},
+		"find":           {(*BufPane).FindCmd, nil},
+		"palette":        {(*BufPane).PaletteCmd, nil},
+		"colorscheme":    {(*BufPane).ColorschemeCmd, ColorschemeComplete},
+		"filetree":       {(*BufPane).FileTreeCmd, nil},
+		"lsp":            {(*BufPane).LSPCmd, lspComplete},
+		"tag":            {(*BufPane).TagCmd, tagComplete},
 	}
 }
 
@@ -366,11 +376,14 @@ func reloadRuntime(reloadPlugins bool) {
 				continue
 			}
 
-			if _, ok := parsedSettings[k]; ok {
-				err = doSetGlobalOptionNative(k, parsedSettings[k])
-			} else {
-				err = doSetGlobalOptionNative(k, defaultSettings[k])
+			value, ok := parsedSettings[k]
+			if !ok {
+				value = defaultSettings[k]
+			}
+			if k == "colorscheme" {
+				value = config.ResolveColorschemeOption(value)
 			}
+			err = doSetGlobalOptionNative(k, value)
 			if err != nil {
 				screen.TermMessage(err)
 			}
@@ -644,6 +657,11 @@ func SetGlobalOptionNative(option string, nativeValue any, writeToFile bool) err
 	}
 
 	// ...if it's not local continue with the globals...
+	if option == "colorscheme" {
+		// the colorscheme does not follow the background of the terminal
+		// anymore
+		config.ClearColorschemeVariants()
+	}
 	if err := doSetGlobalOptionNative(option, nativeValue); err != nil {
 		return err
 	}
@@ -988,6 +1006,7 @@ func (h *BufPane) ReplaceCmd(args []string) {
 
 	all := false
 	noRegex := false
+	preview := false
 
 	foundSearch := false
 	foundReplace := false
@@ -999,6 +1018,8 @@ func (h *BufPane) ReplaceCmd(args []string) {
 			all = true
 		case "-l":
 			noRegex = true
+		case "-p":
+			preview = true
 		default:
 			if !foundSearch {
 				foundSearch = true
@@ -1013,6 +1034,7 @@ func (h *BufPane) ReplaceCmd(args []string) {
 		}
 	}
 
+	desc := fmt.Sprintf("%q with %q", search, replaceStr)
 	if noRegex {
 		search = regexp.QuoteMeta(search)
 	}
@@ -1042,6 +1064,10 @@ func (h *BufPane) ReplaceCmd(args []string) {
 		end = h.Cursor.CurSelection[1]
 		searchLoc = start // otherwise me might start at the end
 	}
+	if preview {
+		h.replacePreview(desc, start, end, regex, replace, !noRegex)
+		return
+	}
 	if all {
 		nreplaced, _ = h.Buf.ReplaceRegex(start, end, regex, replace, !noRegex)
 	} else {
@@ -1081,17 +1107,28 @@ func (h *BufPane) ReplaceCmd(args []string) {
 			h.GotoLoc(locs[0])
 			h.Buf.LastSearch = search
 			h.Buf.LastSearchRegex = true
+			h.Buf.LastSearchCaseToggled = false
+			h.Buf.LastSearchWholeWord = false
 			h.Buf.HighlightSearch = h.Buf.Settings["hlsearch"].(bool)
 
 			InfoBar.YNPrompt("Perform replacement (y,n,esc)", func(yes, canceled bool) {
 				if !canceled && yes {
-					_, nrunes := h.Buf.ReplaceRegex(locs[0], locs[1], regex, replace, !noRegex)
-
-					searchLoc = locs[0]
-					searchLoc.X += nrunes + locs[0].Diff(locs[1], h.Buf)
-					if end.Y == locs[1].Y {
-						end = end.Move(nrunes, h.Buf)
+					// the text after the match is not modified, so the
+					// locations after it keep the same distance to the end of
+					// their line and to the end of the buffer
+					fromEnd := func(l buffer.Loc) buffer.Loc {
+						return buffer.Loc{util.CharacterCount(h.Buf.LineBytes(l.Y)) - l.X, h.Buf.LinesNum() - l.Y}
+					}
+					toLoc := func(d buffer.Loc) buffer.Loc {
+						y := h.Buf.LinesNum() - d.Y
+						return buffer.Loc{util.CharacterCount(h.Buf.LineBytes(y)) - d.X, y}
 					}
+					matchEnd, rangeEnd := fromEnd(locs[1]), fromEnd(end)
+
+					h.Buf.ReplaceRegex(locs[0], locs[1], regex, replace, !noRegex)
+
+					searchLoc = toLoc(matchEnd)
+					end = toLoc(rangeEnd)
 					h.Cursor.Loc = searchLoc
 					nreplaced++
 				} else if !canceled && !yes {
@@ -33,8 +33,8 @@ var bufdefaults = map[string]string{
 	"OldBackspace":   "Backspace",
 	"Alt-CtrlH":      "DeleteWordLeft",
 	"Alt-Backspace":  "DeleteWordLeft",
-	"Tab":            "Autocomplete|IndentSelection|InsertTab",
-	"Backtab":        "CycleAutocompleteBack|OutdentSelection|OutdentLine",
+	"Tab":            "NextSnippetStop|Autocomplete|IndentSelection|InsertTab",
+	"Backtab":        "PrevSnippetStop|CycleAutocompleteBack|OutdentSelection|OutdentLine",
 	"Ctrl-o":         "OpenFile",
 	"Ctrl-s":         "Save",
 	"Ctrl-f":         "Find",
@@ -66,6 +66,8 @@ var bufdefaults = map[string]string{
 	"ShiftPageDown":  "SelectPageDown",
 	"Ctrl-g":         "ToggleHelp",
 	"Alt-g":          "ToggleKeyMenu",
+	"Alt-/":          "ToggleComment",
+	"CtrlUnderscore": "ToggleComment",
 	"Ctrl-r":         "ToggleRuler",
 	"Ctrl-l":         "command-edit:goto ",
 	"Delete":         "Delete",
@@ -174,6 +176,11 @@ var infodefaults = map[string]string{
 	"Alt-a": "StartOfText",
 	"Alt-e": "EndOfLine",
 
+	// Find prompt options
+	"Alt-r": "ToggleSearchRegex",
+	"Alt-c": "ToggleSearchCase",
+	"Alt-w": "ToggleSearchWholeWord",
+
 	// Integration with file managers
 	"F10": "AbortCommand",
 	"Esc": "AbortCommand",
@@ -36,8 +36,8 @@ var bufdefaults = map[string]string{
 	"OldBackspace":   "Backspace",
 	"Alt-CtrlH":      "DeleteWordLeft",
 	"Alt-Backspace":  "DeleteWordLeft",
-	"Tab":            "Autocomplete|IndentSelection|InsertTab",
-	"Backtab":        "CycleAutocompleteBack|OutdentSelection|OutdentLine",
+	"Tab":            "NextSnippetStop|Autocomplete|IndentSelection|InsertTab",
+	"Backtab":        "PrevSnippetStop|CycleAutocompleteBack|OutdentSelection|OutdentLine",
 	"Ctrl-o":         "OpenFile",
 	"Ctrl-s":         "Save",
 	"Ctrl-f":         "Find",
@@ -69,6 +69,8 @@ var bufdefaults = map[string]string{
 	"ShiftPageDown":  "SelectPageDown",
 	"Ctrl-g":         "ToggleHelp",
 	"Alt-g":          "ToggleKeyMenu",
+	"Alt-/":          "ToggleComment",
+	"CtrlUnderscore": "ToggleComment",
 	"Ctrl-r":         "ToggleRuler",
 	"Ctrl-l":         "command-edit:goto ",
 	"Delete":         "Delete",
@@ -177,6 +179,11 @@ var infodefaults = map[string]string{
 	"Alt-a": "StartOfText",
 	"Alt-e": "EndOfLine",
 
+	// Find prompt options
+	"Alt-r": "ToggleSearchRegex",
+	"Alt-c": "ToggleSearchCase",
+	"Alt-w": "ToggleSearchWholeWord",
+
 	// Integration with file managers
 	"F10": "AbortCommand",
 	"Esc": "AbortCommand",
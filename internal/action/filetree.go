@@ -0,0 +1,522 @@
+package action
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// number of lines before the first entry in the file tree
+const fileTreeHeader = 1
+
+// the delay between two checks of the directories displayed in the file tree
+const fileTreePollInterval = time.Second
+
+// A treeEntry is a file or a directory displayed in the file tree
+type treeEntry struct {
+	// absolute path of the entry
+	path  string
+	depth int
+	dir   bool
+}
+
+// A FileTreePane shows the working directory as a tree docked on the left
+// of a tab. Directories can be expanded and collapsed, files are opened in
+// the neighboring split, and entries can be created, renamed and deleted.
+// The tree is refreshed when the displayed directories change, and the file
+// being edited is highlighted.
+type FileTreePane struct {
+	*ListPane
+
+	root     string
+	expanded map[string]bool
+	hidden   bool
+	entries  []treeEntry
+
+	// absolute path of the file being edited
+	current string
+
+	// modification times of the displayed directories, checked by the
+	// watching goroutine
+	mtimes   map[string]time.Time
+	mtimesMu sync.Mutex
+	stop     chan struct{}
+}
+
+// fileTree returns the file tree of the given tab, or nil if it has none
+func fileTree(t *Tab) *FileTreePane {
+	for _, p := range t.Panes {
+		if ft, ok := p.(*FileTreePane); ok {
+			return ft
+		}
+	}
+	return nil
+}
+
+// FileTreeCmd opens or closes the file tree of the current tab
+func (h *BufPane) FileTreeCmd(args []string) {
+	h.ToggleFileTree()
+}
+
+// ToggleFileTree opens the file tree on the left of the current tab, or
+// closes it if it is already open
+func (h *BufPane) ToggleFileTree() bool {
+	if t := fileTree(h.tab); t != nil {
+		t.Quit()
+		return true
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		InfoBar.Error(err)
+		return false
+	}
+	id := h.tab.DockLeft(util.IntOpt(config.GetGlobalOption("filetreewidth")))
+	if id == 0 {
+		InfoBar.Error("Not enough space for the file tree")
+		return false
+	}
+
+	b := newListBuffer("File tree")
+	b.SetOptionNative("softwrap", false)
+	b.SetOptionNative("ruler", false)
+	b.SetOptionNative("statusformatl", "$(filename)")
+	b.SetOptionNative("statusformatr", "")
+	p := NewBufPaneFromBuf(b, h.tab)
+	p.splitID = id
+
+	t := new(FileTreePane)
+	t.ListPane = h.listPane(p)
+	t.root = root
+	t.expanded = make(map[string]bool)
+	t.stop = make(chan struct{})
+	t.keys['o'] = t.Open
+	t.keys['a'] = t.Create
+	t.keys['r'] = t.Rename
+	t.keys['d'] = t.Delete
+	t.keys['R'] = t.Refresh
+	t.keys['.'] = t.ToggleHidden
+	t.keys['q'] = func() { t.Quit() }
+	t.keys['?'] = func() {
+		InfoBar.Message("Enter/o: open  a: new  r: rename  d: delete  R: refresh  .: hidden files  q: quit")
+	}
+	t.enter = t.Open
+	b.CloseCallback = func() {
+		close(t.stop)
+	}
+
+	h.tab.AddPane(t, 0)
+	h.tab.Resize()
+	h.tab.SetActive(0)
+
+	if h.Buf.Type == buffer.BTDefault && h.Buf.Path != "" {
+		t.current = h.Buf.AbsPath
+		t.reveal(t.current)
+	}
+	t.Refresh()
+	t.selectPath(t.current)
+	go t.watch()
+	return true
+}
+
+// HandleEvent expands or collapses a directory, or opens a file, when its
+// entry is clicked
+func (t *FileTreePane) HandleEvent(event tcell.Event) {
+	if e, ok := event.(*tcell.EventMouse); ok && t.Buf == t.list &&
+		e.Buttons() == tcell.Button1 && e.Modifiers() == 0 && len(t.mousePressed) == 0 {
+		_, my := e.Position()
+		v := t.BufView()
+		if my >= v.Y && my < v.Y+v.Height {
+			t.ListPane.HandleEvent(event)
+			if v.StartLine.Line+my-v.Y < t.list.LinesNum() {
+				t.Open()
+			}
+			return
+		}
+	}
+	t.ListPane.HandleEvent(event)
+}
+
+// Display highlights the file being edited and displays the tree
+func (t *FileTreePane) Display() {
+	p := t.tab.CurPane()
+	if p != nil && p != t.BufPane && p.Buf.Type == buffer.BTDefault && p.Buf.Path != "" &&
+		p.Buf.AbsPath != t.current && t.Buf == t.list {
+		t.current = p.Buf.AbsPath
+		t.reveal(t.current)
+		t.Refresh()
+	}
+	t.BufPane.Display()
+}
+
+// Refresh reads the displayed directories again
+func (t *FileTreePane) Refresh() {
+	var sel string
+	if e := t.entry(); e != nil {
+		sel = e.path
+	}
+
+	mtimes := make(map[string]time.Time)
+	t.entries = t.entries[:0]
+	t.readDir(t.root, 0, mtimes)
+
+	var b strings.Builder
+	b.WriteString(filepath.Base(t.root) + "/")
+	for _, e := range t.entries {
+		b.WriteString("\n" + strings.Repeat("  ", e.depth))
+		name := filepath.Base(e.path)
+		if !e.dir {
+			b.WriteString("  " + name)
+		} else if t.expanded[e.path] {
+			b.WriteString("▾ " + name + "/")
+		} else {
+			b.WriteString("▸ " + name + "/")
+		}
+	}
+	t.SetText(b.String())
+	t.selectPath(sel)
+	t.highlight()
+
+	t.mtimesMu.Lock()
+	t.mtimes = mtimes
+	t.mtimesMu.Unlock()
+}
+
+// readDir adds the entries of the given directory to the tree, followed by
+// the entries of its expanded subdirectories, and records the modification
+// times of the directories read
+func (t *FileTreePane) readDir(dir string, depth int, mtimes map[string]time.Time) {
+	if fi, err := os.Stat(dir); err == nil {
+		mtimes[dir] = fi.ModTime()
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var dirs, files []treeEntry
+	for _, de := range dirEntries {
+		if !t.hidden && strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		e := treeEntry{filepath.Join(dir, de.Name()), depth, de.IsDir()}
+		if de.Type()&fs.ModeSymlink != 0 {
+			if fi, err := os.Stat(e.path); err == nil {
+				e.dir = fi.IsDir()
+			}
+		}
+		if e.dir {
+			dirs = append(dirs, e)
+		} else {
+			files = append(files, e)
+		}
+	}
+
+	for _, e := range dirs {
+		t.entries = append(t.entries, e)
+		if t.expanded[e.path] {
+			t.readDir(e.path, depth+1, mtimes)
+		}
+	}
+	t.entries = append(t.entries, files...)
+}
+
+// watch refreshes the tree when one of the displayed directories is
+// modified, until the tree is closed
+func (t *FileTreePane) watch() {
+	ticker := time.NewTicker(fileTreePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+
+		t.mtimesMu.Lock()
+		mtimes := t.mtimes
+		t.mtimesMu.Unlock()
+
+		changed := false
+		for dir, mtime := range mtimes {
+			fi, err := os.Stat(dir)
+			if err != nil || !fi.ModTime().Equal(mtime) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		select {
+		case <-t.stop:
+			return
+		case shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) {
+				select {
+				case <-t.stop:
+				default:
+					t.Refresh()
+				}
+			},
+		}:
+		}
+	}
+}
+
+// entry returns the entry under the cursor, or nil if there is none
+func (t *FileTreePane) entry() *treeEntry {
+	i := t.Cursor.Y - fileTreeHeader
+	if i < 0 || i >= len(t.entries) {
+		return nil
+	}
+	return &t.entries[i]
+}
+
+// selectPath moves the cursor to the entry of the given path, if it is
+// displayed
+func (t *FileTreePane) selectPath(path string) {
+	for i, e := range t.entries {
+		if e.path == path {
+			t.Cursor.GotoLoc(buffer.Loc{0, i + fileTreeHeader})
+			t.Relocate()
+			return
+		}
+	}
+}
+
+// reveal expands the directories containing the given path
+func (t *FileTreePane) reveal(path string) {
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, t.root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		t.expanded[dir] = true
+	}
+}
+
+// relPath returns the given path relative to the root of the tree
+func (t *FileTreePane) relPath(path string) string {
+	rel, _ := util.MakeRelative(path, t.root)
+	return rel
+}
+
+// highlightGroup returns the highlight group of the file being edited
+func highlightGroup() highlight.Group {
+	if _, ok := config.Colorscheme["filetree.current"]; ok {
+		return highlight.GetGroup("filetree.current")
+	}
+	return highlight.GetGroup("special")
+}
+
+// highlight highlights the entry of the file being edited
+func (t *FileTreePane) highlight() {
+	t.list.ClearMatches()
+	for i, e := range t.entries {
+		if e.path == t.current {
+			y := i + fileTreeHeader
+			t.list.SetMatch(y, highlight.LineMatch{0: highlightGroup()})
+			if y+1 < t.list.LinesNum() {
+				// the highlighting would continue on the next line
+				t.list.SetMatch(y+1, highlight.LineMatch{0: 0})
+			}
+			return
+		}
+	}
+}
+
+// Open opens the file under the cursor in the neighboring split, or
+// expands or collapses the directory under the cursor
+func (t *FileTreePane) Open() {
+	e := t.entry()
+	if e == nil {
+		return
+	}
+	if e.dir {
+		if t.expanded[e.path] {
+			delete(t.expanded, e.path)
+		} else {
+			t.expanded[e.path] = true
+		}
+		t.Refresh()
+		return
+	}
+	t.openPath(e.path)
+}
+
+// openPath opens the file at the given path in the pane the tree was opened
+// from, or in another pane of the tab, or in a new split if the tree is
+// alone in the tab
+func (t *FileTreePane) openPath(path string) {
+	if wd, err := os.Getwd(); err == nil {
+		path, _ = util.MakeRelative(path, wd)
+	}
+	if t.originPane() == nil {
+		t.origin = nil
+		for _, p := range t.tab.Panes {
+			if bp, ok := p.(*BufPane); ok {
+				t.origin = bp
+				break
+			}
+		}
+	}
+	if t.origin == nil {
+		b, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		t.origin = t.VSplitIndex(b, true)
+		return
+	}
+	t.openFile(path, buffer.Loc{-1, -1})
+}
+
+// dirUnderCursor returns the directory under the cursor, or the directory
+// of the file under the cursor
+func (t *FileTreePane) dirUnderCursor() string {
+	e := t.entry()
+	if e == nil {
+		return t.root
+	} else if e.dir {
+		return e.path
+	}
+	return filepath.Dir(e.path)
+}
+
+// Create asks for the path of a new file, which is created in the directory
+// under the cursor by default, and opens it. A directory is created instead
+// if the path ends with a slash.
+func (t *FileTreePane) Create() {
+	dir := ""
+	if d := t.dirUnderCursor(); d != t.root {
+		dir = t.relPath(d) + string(filepath.Separator)
+	}
+	InfoBar.Prompt("New file: ", dir, "FileTree", nil, func(resp string, canceled bool) {
+		if canceled || resp == "" || resp == dir {
+			return
+		}
+		path := filepath.Join(t.root, resp)
+		isDir := strings.HasSuffix(resp, "/") || strings.HasSuffix(resp, string(filepath.Separator))
+
+		var err error
+		if isDir {
+			err = os.MkdirAll(path, os.ModePerm)
+		} else if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err == nil {
+			var f *os.File
+			if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666); err == nil {
+				err = f.Close()
+			}
+		}
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+
+		t.reveal(path)
+		t.Refresh()
+		t.selectPath(path)
+		if !isDir {
+			t.openPath(path)
+		}
+	})
+}
+
+// Rename asks for a new path for the entry under the cursor and moves it.
+// Open buffers editing the moved files are updated accordingly.
+func (t *FileTreePane) Rename() {
+	e := t.entry()
+	if e == nil {
+		return
+	}
+	old := e.path
+	rel := t.relPath(old)
+	InfoBar.Prompt("Rename to: ", rel, "FileTree", nil, func(resp string, canceled bool) {
+		if canceled || resp == "" || resp == rel {
+			return
+		}
+		path := filepath.Join(t.root, resp)
+		if _, err := os.Lstat(path); err == nil {
+			InfoBar.Error(resp, " already exists")
+			return
+		}
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err == nil {
+			err = os.Rename(old, path)
+		}
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+
+		wd, _ := os.Getwd()
+		for _, b := range buffer.OpenBuffers {
+			if b.AbsPath == old || strings.HasPrefix(b.AbsPath, old+string(filepath.Separator)) {
+				b.AbsPath = path + b.AbsPath[len(old):]
+				b.Path, _ = util.MakeRelative(b.AbsPath, wd)
+				b.ReloadSettings(true)
+			}
+		}
+		if t.current == old || strings.HasPrefix(t.current, old+string(filepath.Separator)) {
+			t.current = path + t.current[len(old):]
+		}
+		var moved []string
+		for dir := range t.expanded {
+			if dir == old || strings.HasPrefix(dir, old+string(filepath.Separator)) {
+				moved = append(moved, dir)
+			}
+		}
+		for _, dir := range moved {
+			delete(t.expanded, dir)
+			t.expanded[path+dir[len(old):]] = true
+		}
+
+		t.reveal(path)
+		t.Refresh()
+		t.selectPath(path)
+	})
+}
+
+// Delete deletes the entry under the cursor after asking for confirmation
+func (t *FileTreePane) Delete() {
+	e := t.entry()
+	if e == nil {
+		return
+	}
+	entry := *e
+	msg := fmt.Sprintf("Delete %s? (y,n,esc)", t.relPath(entry.path))
+	if entry.dir {
+		msg = fmt.Sprintf("Delete %s and all its content? (y,n,esc)", t.relPath(entry.path))
+	}
+	InfoBar.YNPrompt(msg, func(yes, canceled bool) {
+		if !yes || canceled {
+			return
+		}
+		var err error
+		if entry.dir {
+			err = os.RemoveAll(entry.path)
+		} else {
+			err = os.Remove(entry.path)
+		}
+		if err != nil {
+			InfoBar.Error(err)
+		}
+		t.Refresh()
+	})
+}
+
+// ToggleHidden shows or hides the hidden files, whose name starts with a dot
+func (t *FileTreePane) ToggleHidden() {
+	t.hidden = !t.hidden
+	t.Refresh()
+}
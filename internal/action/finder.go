@@ -0,0 +1,149 @@
+package action
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/info"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// FindCmd opens the file finder, which lists the files below the working
+// directory and opens the selected one in the current pane, or in a split
+// or a tab with the -hsplit, -vsplit or -tab flag
+func (h *BufPane) FindCmd(args []string) {
+	mode := ""
+	for _, arg := range args {
+		switch arg {
+		case "-hsplit", "-vsplit", "-tab":
+			if mode != "" {
+				InfoBar.Error("Only one of -hsplit, -vsplit and -tab is allowed")
+				return
+			}
+			mode = arg
+		default:
+			InfoBar.Error("Invalid flag: ", arg)
+			return
+		}
+	}
+	h.openFinder(mode)
+}
+
+// FindFile opens the file finder to open a file in the current pane
+func (h *BufPane) FindFile() bool {
+	h.openFinder("")
+	return true
+}
+
+// the number of bytes read from a file to preview it in the finder
+const finderPreviewSize = 4096
+
+// filePreview returns the beginning of the file at the given path
+func filePreview(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	data := make([]byte, finderPreviewSize)
+	n, _ := io.ReadFull(f, data)
+	data = data[:n]
+	if util.IsBinary(data) {
+		return "(binary file)"
+	}
+	return string(data)
+}
+
+// the number of files found before they are added to the finder, and the
+// maximum delay before adding them
+const (
+	finderBatchSize  = 1000
+	finderBatchDelay = 100 * time.Millisecond
+)
+
+// openFoundFile opens the file selected in the file finder in this pane,
+// or in a split or a tab depending on mode
+func (h *BufPane) openFoundFile(path, mode string) {
+	b, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	switch mode {
+	case "-hsplit":
+		h.HSplitBuf(b)
+	case "-vsplit":
+		h.VSplitBuf(b)
+	case "-tab":
+		width, height := screen.Screen.Size()
+		iOffset := config.GetInfoBarOffset()
+		Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+		Tabs.SetActive(len(Tabs.List) - 1)
+	default:
+		h.OpenBuffer(b)
+	}
+}
+
+func (h *BufPane) openFinder(mode string) {
+	stop := make(chan struct{})
+	var p *info.Picker
+	p = Pick("Find file", nil, nil, func(i int) string {
+		return filePreview(p.Items[i])
+	}, func(i int) {
+		if i < 0 {
+			return
+		}
+		path := p.Items[i]
+		if mode == "" && h.Buf.Modified() && !h.Buf.Shared() {
+			h.closePrompt("Save", func() {
+				h.openFoundFile(path, mode)
+			})
+		} else {
+			h.openFoundFile(path, mode)
+		}
+	})
+	p.Loading = true
+	// stop the search however the picker is closed
+	p.OnClose = func() {
+		close(stop)
+	}
+
+	// add the files to the picker from the main loop
+	send := func(files []string, done bool) bool {
+		select {
+		case <-stop:
+			return false
+		case shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) {
+				p.AddItems(files)
+				p.Loading = !done
+			},
+		}:
+			return true
+		}
+	}
+
+	hidden := config.GetGlobalOption("findhidden").(bool)
+	go func() {
+		var files []string
+		last := time.Now()
+		util.WalkFiles(".", hidden, func(path string) bool {
+			files = append(files, path)
+			if len(files) >= finderBatchSize || time.Since(last) > finderBatchDelay {
+				if !send(files, false) {
+					return false
+				}
+				files = nil
+				last = time.Now()
+			}
+			return true
+		})
+		send(files, true)
+	}()
+}
@@ -0,0 +1,231 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/shell"
+)
+
+// number of lines before the first file in the git pane
+const gitPaneHeader = 3
+
+// A GitPane lists the files changed in a git repository and lets the user
+// open, stage, unstage or discard them, and commit the staged changes.
+type GitPane struct {
+	*ListPane
+
+	root    string
+	branch  string
+	entries []shell.GitStatusEntry
+}
+
+// GitCmd opens a pane showing the status of the git repository containing
+// the current file (or the working directory)
+func (h *BufPane) GitCmd(args []string) {
+	dir := "."
+	if h.Buf.AbsPath != "" {
+		dir = filepath.Dir(h.Buf.AbsPath)
+	}
+	root, err := shell.GitRoot(dir)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	g := new(GitPane)
+	g.ListPane = h.newListPane("Git status")
+	g.root = root
+	g.keys['o'] = g.Open
+	g.keys['s'] = g.Stage
+	g.keys['u'] = g.Unstage
+	g.keys['d'] = g.Discard
+	g.keys['c'] = g.Commit
+	g.keys['r'] = g.Refresh
+	g.keys['q'] = func() { g.Quit() }
+	g.enter = g.Open
+	g.setPane(g)
+
+	g.Refresh()
+	g.Cursor.GotoLoc(buffer.Loc{0, gitPaneHeader}.Clamp(g.Buf.Start(), g.Buf.End()))
+	g.Relocate()
+}
+
+// Refresh reloads the status of the repository
+func (g *GitPane) Refresh() {
+	branch, entries, err := shell.GitStatus(g.root)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	g.branch, g.entries = branch, entries
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "On branch %s\n", g.branch)
+	b.WriteString("Enter/o: open  s: stage  u: unstage  d: discard  c: commit  r: refresh  q: quit\n")
+	if len(g.entries) == 0 {
+		b.WriteString("\nNothing to commit, working tree clean")
+	}
+	for _, e := range g.entries {
+		if e.OrigPath != "" {
+			fmt.Fprintf(&b, "\n%c%c %s -> %s", e.Index, e.Worktree, e.OrigPath, e.Path)
+		} else {
+			fmt.Fprintf(&b, "\n%c%c %s", e.Index, e.Worktree, e.Path)
+		}
+	}
+	g.SetText(b.String())
+}
+
+// entry returns the entry under the cursor, or nil if there is none
+func (g *GitPane) entry() *shell.GitStatusEntry {
+	i := g.Cursor.Y - gitPaneHeader
+	if i < 0 || i >= len(g.entries) {
+		return nil
+	}
+	return &g.entries[i]
+}
+
+// Open opens the file under the cursor
+func (g *GitPane) Open() {
+	e := g.entry()
+	if e == nil {
+		return
+	}
+	g.openFile(filepath.Join(g.root, e.Path), buffer.Loc{-1, -1})
+}
+
+// Stage adds the changes to the file under the cursor to the index
+func (g *GitPane) Stage() {
+	e := g.entry()
+	if e == nil {
+		return
+	}
+	if err := shell.GitStage(g.root, e.Path); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	g.Refresh()
+}
+
+// Unstage removes the changes to the file under the cursor from the index
+func (g *GitPane) Unstage() {
+	e := g.entry()
+	if e == nil {
+		return
+	}
+	if !e.Staged() {
+		InfoBar.Error(e.Path, " has no staged changes")
+		return
+	}
+	if err := shell.GitUnstage(g.root, e.Path); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	g.Refresh()
+}
+
+// Discard throws away the unstaged changes to the file under the cursor
+// after asking for confirmation
+func (g *GitPane) Discard() {
+	e := g.entry()
+	if e == nil {
+		return
+	}
+	var msg string
+	if e.Untracked() {
+		msg = fmt.Sprintf("Delete untracked file %s? (y,n,esc)", e.Path)
+	} else if e.Unstaged() {
+		msg = fmt.Sprintf("Discard changes to %s? (y,n,esc)", e.Path)
+	} else {
+		InfoBar.Error(e.Path, " has no unstaged changes")
+		return
+	}
+
+	entry := *e
+	InfoBar.YNPrompt(msg, func(yes, canceled bool) {
+		if !yes || canceled {
+			return
+		}
+		if err := shell.GitDiscard(g.root, entry); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		g.Refresh()
+	})
+}
+
+// gitStatusNames describes the index status codes in the commit message
+// template, like git does
+var gitStatusNames = map[byte]string{
+	'M': "modified",
+	'A': "new file",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'T': "typechange",
+}
+
+// Commit opens a git-commit buffer to write the commit message. The commit
+// is created when the user closes its pane after saving the message, and
+// not when the buffer is closed because micro exits.
+func (g *GitPane) Commit() {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString("# Please enter the commit message for your changes. Lines starting\n")
+	b.WriteString("# with '#' will be ignored, and an empty message aborts the commit.\n")
+	b.WriteString("# Save and close this buffer to commit.\n")
+	b.WriteString("#\n")
+	fmt.Fprintf(&b, "# On branch %s\n", g.branch)
+	b.WriteString("# Changes to be committed:\n")
+	staged := false
+	for _, e := range g.entries {
+		if !e.Staged() {
+			continue
+		}
+		staged = true
+		path := e.Path
+		if e.OrigPath != "" {
+			path = e.OrigPath + " -> " + e.Path
+		}
+		fmt.Fprintf(&b, "#\t%-11s %s\n", gitStatusNames[e.Index]+":", path)
+	}
+	b.WriteString("#\n")
+	if !staged {
+		InfoBar.Error("Nothing staged to commit")
+		return
+	}
+
+	gitDir, err := shell.GitDir(g.root)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	msgFile := filepath.Join(gitDir, "COMMIT_EDITMSG")
+	if err := os.WriteFile(msgFile, []byte(b.String()), 0644); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	buf, err := buffer.NewBufferFromFile(msgFile, buffer.BTDefault)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	p := g.HSplitIndex(buf, false)
+	p.quitCallback = func() {
+		if buf.Modified() {
+			InfoBar.Message("Commit aborted")
+			return
+		}
+		out, err := shell.GitCommit(g.root, msgFile)
+		if err != nil {
+			InfoBar.Error(err)
+		} else {
+			InfoBar.Message(strings.SplitN(strings.TrimSpace(out), "\n", 2)[0])
+		}
+		g.Refresh()
+	}
+}
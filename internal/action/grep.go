@@ -0,0 +1,261 @@
+package action
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A grepMatch is a line matching the pattern of a grep search
+type grepMatch struct {
+	path string
+	loc  buffer.Loc
+	text string
+}
+
+// A GrepPane shows the results of a grep search, grouped by file. The
+// results are added while the search is running in the background.
+type GrepPane struct {
+	*ListPane
+
+	// matches maps the lines of the list to the matches they show. A file
+	// name line is mapped to the first match in that file.
+	matches map[int]grepMatch
+	// the matches in the quickfix list format
+	entries []buffer.QuickfixEntry
+	nfile   int
+	pattern string
+	stop    chan struct{}
+}
+
+// GrepCmd searches the files below a directory (the working directory by
+// default) for a regular expression and lists the matching lines
+func (h *BufPane) GrepCmd(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		InfoBar.Error("usage: grep pattern [path]")
+		return
+	}
+	pattern := args[0]
+	root := "."
+	if len(args) == 2 {
+		root = args[1]
+	}
+	if _, err := os.Stat(root); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	ignorecase := h.Buf.Settings["ignorecase"].(bool)
+	re := pattern
+	if ignorecase {
+		re = "(?i)" + re
+	}
+	r, err := regexp.Compile(re)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	g := new(GrepPane)
+	g.ListPane = h.newListPane("Grep results")
+	g.matches = make(map[int]grepMatch)
+	g.pattern = pattern
+	g.stop = make(chan struct{})
+	g.enter = g.Open
+	g.keys['q'] = func() { g.Quit() }
+	g.setPane(g)
+
+	g.list.SetOptionNative("ignorecase", ignorecase)
+	g.list.LastSearch = pattern
+	g.list.LastSearchRegex = true
+	g.list.LastSearchCaseToggled = false
+	g.list.HighlightSearch = true
+	g.list.CloseCallback = func() {
+		close(g.stop)
+	}
+	g.SetText(fmt.Sprintf("Searching for %q in %s...\n", pattern, root))
+
+	// add the results to the pane from the main loop
+	send := func(matches []grepMatch) bool {
+		select {
+		case <-g.stop:
+			return false
+		case shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) { g.add(matches) },
+		}:
+			return true
+		}
+	}
+	done := func(err error) {
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) { g.done(err) },
+		}
+	}
+
+	go func() {
+		if rg, err := exec.LookPath("rg"); err == nil {
+			done(grepRipgrep(rg, pattern, root, ignorecase, g.stop, send))
+		} else {
+			done(grepFiles(r, root, send))
+		}
+	}()
+}
+
+// grepFiles searches the files below root with a Go regular expression,
+// skipping ignored and binary files. The matches are sent file by file.
+func grepFiles(r *regexp.Regexp, root string, send func([]grepMatch) bool) error {
+	return util.WalkFiles(root, true, func(path string) bool {
+		data, err := os.ReadFile(path)
+		if err != nil || util.IsBinary(data) {
+			return true
+		}
+		var matches []grepMatch
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			m := r.FindStringIndex(line)
+			if m == nil {
+				continue
+			}
+			matches = append(matches, grepMatch{
+				path: path,
+				loc:  buffer.Loc{utf8.RuneCountInString(line[:m[0]]), i},
+				text: line,
+			})
+		}
+		if len(matches) > 0 {
+			return send(matches)
+		}
+		return true
+	})
+}
+
+var rgLineRegex = regexp.MustCompile(`^(.*?):(\d+):(\d+):(.*)$`)
+
+// grepRipgrep searches the files below root with ripgrep, which also skips
+// ignored and binary files
+func grepRipgrep(rg, pattern, root string, ignorecase bool, stop chan struct{}, send func([]grepMatch) bool) error {
+	args := []string{"--with-filename", "--line-number", "--column", "--no-heading",
+		"--color", "never", "--no-messages"}
+	if ignorecase {
+		args = append(args, "--ignore-case")
+	}
+	args = append(args, "-e", pattern, "--", root)
+
+	cmd := exec.Command(rg, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var matches []grepMatch
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, 1024*1024)
+	stopped := false
+	for scanner.Scan() {
+		sm := rgLineRegex.FindStringSubmatch(scanner.Text())
+		if sm == nil {
+			continue
+		}
+		path := sm[1]
+		line, _ := strconv.Atoi(sm[2])
+		col, _ := strconv.Atoi(sm[3])
+		text := strings.TrimSuffix(sm[4], "\r")
+		x := 0
+		if col-1 <= len(text) {
+			x = utf8.RuneCountInString(text[:col-1])
+		}
+
+		// ripgrep prints all the matches of a file together
+		if len(matches) > 0 && matches[0].path != path {
+			if !send(matches) {
+				stopped = true
+				break
+			}
+			matches = nil
+		}
+		matches = append(matches, grepMatch{path, buffer.Loc{x, line - 1}, text})
+	}
+	if stopped {
+		cmd.Process.Kill()
+	} else if len(matches) > 0 {
+		send(matches)
+	}
+
+	err = cmd.Wait()
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		// no match
+		return nil
+	}
+	if stopped {
+		return nil
+	}
+	return err
+}
+
+// add appends the matches found in a file to the list
+func (g *GrepPane) add(matches []grepMatch) {
+	var b strings.Builder
+	y := g.list.LinesNum()
+	fmt.Fprintf(&b, "\n%s", filepath.Clean(matches[0].path))
+	g.matches[y] = matches[0]
+	for i, m := range matches {
+		fmt.Fprintf(&b, "\n  %d:%d: %s", m.loc.Y+1, m.loc.X+1, m.text)
+		g.matches[y+i+1] = m
+		g.entries = append(g.entries, buffer.QuickfixEntry{
+			Path: m.path,
+			Loc:  m.loc,
+			Kind: buffer.MTInfo,
+			Msg:  strings.TrimSpace(m.text),
+		})
+	}
+	g.list.EventHandler.Insert(g.list.End(), b.String())
+
+	if g.nfile == 0 && g.Cursor.Y == 0 {
+		g.Cursor.GotoLoc(buffer.Loc{0, y + 1})
+		g.Relocate()
+	}
+	g.nfile++
+}
+
+// done is called when the search has finished, the results are then put
+// in the quickfix list
+func (g *GrepPane) done(err error) {
+	select {
+	case <-g.stop:
+		// the pane has been closed
+		return
+	default:
+	}
+
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	start := buffer.Loc{0, 0}
+	end := buffer.Loc{util.CharacterCount(g.list.LineBytes(0)), 0}
+	g.list.EventHandler.Replace(start, end, fmt.Sprintf("%d matches in %d files", len(g.entries), g.nfile))
+	SetQuickfix("grep "+g.pattern, g.entries)
+}
+
+// Open opens the file of the result under the cursor at the location of
+// the match
+func (g *GrepPane) Open() {
+	m, ok := g.matches[g.Cursor.Y]
+	if !ok {
+		return
+	}
+	g.openFile(m.path, m.loc)
+}
@@ -58,6 +58,21 @@ func HelpComplete(b *buffer.Buffer) ([]string, []string) {
 	return completions, suggestions
 }
 
+// ColorschemeComplete autocompletes colorscheme names
+func ColorschemeComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	_, suggestions := colorschemeComplete(input)
+
+	sort.Strings(suggestions)
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
 // colorschemeComplete tab-completes names of colorschemes.
 // This is just a heper value for OptionValueComplete
 func colorschemeComplete(input string) (string, []string) {
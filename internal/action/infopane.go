@@ -166,13 +166,23 @@ func (h *InfoPane) DoKeyEvent(e KeyEvent) bool {
 	return more
 }
 
-// HistoryUp cycles history up
+// HistoryUp cycles history up, or selects the previous item of the picker
+// if there is one
 func (h *InfoPane) HistoryUp() {
+	if h.Picker != nil {
+		h.Picker.Move(-1)
+		return
+	}
 	h.UpHistory(h.History[h.PromptType])
 }
 
-// HistoryDown cycles history down
+// HistoryDown cycles history down, or selects the next item of the picker
+// if there is one
 func (h *InfoPane) HistoryDown() {
+	if h.Picker != nil {
+		h.Picker.Move(1)
+		return
+	}
 	h.DownHistory(h.History[h.PromptType])
 }
 
@@ -229,13 +239,43 @@ func (h *InfoPane) AbortCommand() {
 	h.DonePrompt(true)
 }
 
+// ToggleSearchRegex toggles between a regex and a literal search in the
+// find prompt
+func (h *InfoPane) ToggleSearchRegex() {
+	if curFind != nil && h.PromptType == "Find" {
+		curFind.useRegex = !curFind.useRegex
+		curFind.update()
+	}
+}
+
+// ToggleSearchCase toggles the case sensitivity of the search in the find
+// prompt
+func (h *InfoPane) ToggleSearchCase() {
+	if curFind != nil && h.PromptType == "Find" {
+		curFind.matchCase = !curFind.matchCase
+		curFind.caseToggled = true
+		curFind.update()
+	}
+}
+
+// ToggleSearchWholeWord toggles matching only whole words in the find prompt
+func (h *InfoPane) ToggleSearchWholeWord() {
+	if curFind != nil && h.PromptType == "Find" {
+		curFind.wholeWord = !curFind.wholeWord
+		curFind.update()
+	}
+}
+
 // InfoKeyActions contains the list of all possible key actions the infopane could execute
 var InfoKeyActions = map[string]InfoKeyAction{
-	"HistoryUp":         (*InfoPane).HistoryUp,
-	"HistoryDown":       (*InfoPane).HistoryDown,
-	"HistorySearchUp":   (*InfoPane).HistorySearchUp,
-	"HistorySearchDown": (*InfoPane).HistorySearchDown,
-	"CommandComplete":   (*InfoPane).CommandComplete,
-	"ExecuteCommand":    (*InfoPane).ExecuteCommand,
-	"AbortCommand":      (*InfoPane).AbortCommand,
+	"HistoryUp":             (*InfoPane).HistoryUp,
+	"HistoryDown":           (*InfoPane).HistoryDown,
+	"HistorySearchUp":       (*InfoPane).HistorySearchUp,
+	"HistorySearchDown":     (*InfoPane).HistorySearchDown,
+	"CommandComplete":       (*InfoPane).CommandComplete,
+	"ExecuteCommand":        (*InfoPane).ExecuteCommand,
+	"AbortCommand":          (*InfoPane).AbortCommand,
+	"ToggleSearchRegex":     (*InfoPane).ToggleSearchRegex,
+	"ToggleSearchCase":      (*InfoPane).ToggleSearchCase,
+	"ToggleSearchWholeWord": (*InfoPane).ToggleSearchWholeWord,
 }
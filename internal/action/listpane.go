@@ -0,0 +1,134 @@
+package action
+
+import (
+	"path/filepath"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// A ListPane is a pane showing a read-only list of entries, such as the
+// files changed in a git repository. While the list is displayed, Enter
+// and the keys registered by the pane run the pane's own commands, every
+// other event is handled like in a regular BufPane.
+type ListPane struct {
+	*BufPane
+
+	// the buffer holding the list
+	list *buffer.Buffer
+	// the pane which was active when the list was opened
+	origin *BufPane
+
+	keys  map[rune]func()
+	enter func()
+}
+
+// newListPane opens an empty list buffer with the given name in a new
+// horizontal split. The caller must install the final pane in the tab
+// with setPane.
+func (h *BufPane) newListPane(name string) *ListPane {
+	return h.listPane(h.HSplitBuf(newListBuffer(name)))
+}
+
+// newListBuffer returns an empty list buffer with the given name
+func newListBuffer(name string) *buffer.Buffer {
+	b := buffer.NewBufferFromString("", "", buffer.BTList)
+	b.SetName(name)
+	return b
+}
+
+// listPane returns a list pane built on p, which displays a list buffer
+// and has been opened from h
+func (h *BufPane) listPane(p *BufPane) *ListPane {
+	l := new(ListPane)
+	l.BufPane = p
+	l.list = p.Buf
+	l.origin = h
+	l.keys = make(map[rune]func())
+	return l
+}
+
+// setPane replaces the BufPane created for the list by p, which is the
+// special pane built on top of it.
+func (l *ListPane) setPane(p Pane) {
+	l.tab.Panes[l.tab.GetPane(l.ID())] = p
+}
+
+// bufPane returns the BufPane the list pane is built on
+func (l *ListPane) bufPane() *BufPane {
+	return l.BufPane
+}
+
+// HandleEvent runs the list commands for key events and passes every
+// other event to the underlying BufPane.
+func (l *ListPane) HandleEvent(event tcell.Event) {
+	if e, ok := event.(*tcell.EventKey); ok && l.Buf == l.list {
+		switch e.Key() {
+		case tcell.KeyEnter:
+			if l.enter != nil && e.Modifiers() == 0 {
+				l.enter()
+				return
+			}
+		case tcell.KeyRune:
+			if e.Modifiers()&^tcell.ModShift == 0 {
+				if f, ok := l.keys[e.Rune()]; ok {
+					f()
+					return
+				}
+			}
+		}
+	}
+	l.BufPane.HandleEvent(event)
+}
+
+// SetText replaces the content of the list and keeps the cursor on the
+// same line if possible.
+func (l *ListPane) SetText(text string) {
+	y := l.Cursor.Y
+	l.list.EventHandler.Replace(l.list.Start(), l.list.End(), text)
+	l.Cursor.ResetSelection()
+	l.Cursor.GotoLoc(buffer.Loc{0, y}.Clamp(l.list.Start(), l.list.End()))
+	l.Cursor.Start()
+	l.Relocate()
+}
+
+// originPane returns the pane the list was opened from, or nil if it has
+// been closed or is not in the same tab anymore
+func (l *ListPane) originPane() *BufPane {
+	for _, p := range l.tab.Panes {
+		if p == Pane(l.origin) {
+			return l.origin
+		}
+	}
+	return nil
+}
+
+// openFile opens the file at the given path and moves the cursor to loc,
+// unless loc is {-1, -1}. The file is opened in the pane the list was
+// opened from, or in a new split above the list if that pane is gone or
+// has unsaved changes.
+func (l *ListPane) openFile(path string, loc buffer.Loc) {
+	abs, _ := filepath.Abs(path)
+	target := l.originPane()
+	if target == nil || target.Buf.AbsPath != abs {
+		b, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		if target == nil {
+			target = l.HSplitIndex(b, false)
+			l.origin = target
+		} else if target.Buf.Modified() && !target.Buf.Shared() {
+			target = target.HSplitIndex(b, false)
+			l.origin = target
+		} else {
+			target.OpenBuffer(b)
+		}
+	}
+
+	l.tab.SetActive(l.tab.GetPane(target.ID()))
+	if loc.X != -1 && loc.Y != -1 {
+		target.GotoLoc(loc.Clamp(target.Buf.Start(), target.Buf.End()))
+	}
+}
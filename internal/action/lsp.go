@@ -0,0 +1,729 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/lsp"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// delay before the changes of a buffer are sent to its language server, so
+// that several quick changes are sent together
+const lspChangeDelay = 50 * time.Millisecond
+
+// owner of the gutter messages showing the diagnostics
+const lspMsgOwner = "lsp"
+
+// language identifiers of the filetypes whose name is different
+var lspLanguageIDs = map[string]string{
+	"c++":   "cpp",
+	"shell": "shellscript",
+	"objc":  "objective-c",
+}
+
+// lspServers are the running language servers by command
+var lspServers = make(map[string]*lspServer)
+
+// lspDocs are the documents sent to the language servers by buffer
+var lspDocs = make(map[*buffer.SharedBuffer]*lspDoc)
+
+// An lspServer is a language server used by the buffers whose lspserver
+// option is its command
+type lspServer struct {
+	command string
+	// client is nil while the server is starting and after it has failed
+	client *lsp.Client
+	err    error
+	docs   map[*buffer.SharedBuffer]*lspDoc
+}
+
+// An lspDoc is a buffer opened in a language server. Its changes are
+// recorded by the change callback of the buffer and sent after a short
+// delay, or before a request is made.
+type lspDoc struct {
+	server     *lspServer
+	buf        *buffer.Buffer
+	uri        string
+	languageID string
+	version    int
+
+	changes []lsp.TextDocumentContentChangeEvent
+	dirty   bool
+	timer   *time.Timer
+}
+
+// lspRun runs f in the main goroutine
+func lspRun(f func()) {
+	shell.Jobs <- shell.JobFunction{
+		Function: func(string, []any) { f() },
+	}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// lspPosition converts a location of the text to a position, in which the
+// column is counted in UTF-16 code units
+func lspPosition(b *buffer.LineArray, loc buffer.Loc) lsp.Position {
+	line := b.LineBytes(loc.Y)
+	units := 0
+	for x := 0; x < loc.X && len(line) > 0; x++ {
+		r, combc, size := util.DecodeCharacter(line)
+		units += utf16Len(r)
+		for _, c := range combc {
+			units += utf16Len(c)
+		}
+		line = line[size:]
+	}
+	return lsp.Position{Line: loc.Y, Character: units}
+}
+
+// lspLoc converts a position to a location of the text
+func lspLoc(b *buffer.LineArray, pos lsp.Position) buffer.Loc {
+	if pos.Line >= b.LinesNum() {
+		return b.End()
+	}
+	line := b.LineBytes(pos.Line)
+	x, units := 0, 0
+	for units < pos.Character && len(line) > 0 {
+		r, combc, size := util.DecodeCharacter(line)
+		units += utf16Len(r)
+		for _, c := range combc {
+			units += utf16Len(c)
+		}
+		line = line[size:]
+		x++
+	}
+	return buffer.Loc{X: x, Y: pos.Line}
+}
+
+// lspAttach opens the buffer in the language server given by its
+// lspserver option, starting the server if needed
+func lspAttach(b *buffer.Buffer) {
+	if b.Type != buffer.BTDefault || b.Path == "" || lspDocs[b.SharedBuffer] != nil {
+		return
+	}
+	command := b.Settings["lspserver"].(string)
+	if command == "" {
+		return
+	}
+
+	s := lspServers[command]
+	if s == nil {
+		s = startLSPServer(command)
+	}
+
+	languageID := b.Settings["filetype"].(string)
+	if id, ok := lspLanguageIDs[languageID]; ok {
+		languageID = id
+	}
+	doc := &lspDoc{
+		server:     s,
+		buf:        b,
+		uri:        lsp.FileURI(b.AbsPath),
+		languageID: languageID,
+	}
+	s.docs[b.SharedBuffer] = doc
+	lspDocs[b.SharedBuffer] = doc
+	b.ChangeCallback = doc.change
+	b.SaveCallback = doc.save
+	b.ReleaseCallback = doc.release
+
+	if s.client != nil {
+		doc.open()
+	}
+}
+
+// startLSPServer starts a language server in the background. The buffers
+// attached to it are opened once it is initialized.
+func startLSPServer(command string) *lspServer {
+	s := &lspServer{
+		command: command,
+		docs:    make(map[*buffer.SharedBuffer]*lspDoc),
+	}
+	lspServers[command] = s
+
+	args, err := shellquote.Split(command)
+	if err != nil || len(args) == 0 {
+		s.err = fmt.Errorf("Invalid lspserver %q", command)
+		InfoBar.Error(s.err)
+		return s
+	}
+	wd, _ := os.Getwd()
+	go func() {
+		client, err := lsp.NewClient(args[0], args[1:], wd, func(method string, params json.RawMessage) {
+			lspRun(func() { s.notification(method, params) })
+		})
+		lspRun(func() { s.started(client, err) })
+	}()
+	return s
+}
+
+func (s *lspServer) running() bool {
+	return lspServers[s.command] == s
+}
+
+func (s *lspServer) started(client *lsp.Client, err error) {
+	if !s.running() {
+		if client != nil {
+			go client.Shutdown()
+		}
+		return
+	}
+	if err != nil {
+		s.err = fmt.Errorf("Could not start the language server %s: %v", s.command, err)
+		InfoBar.Error(s.err)
+		return
+	}
+
+	s.client = client
+	for _, doc := range s.docs {
+		doc.open()
+	}
+	go func() {
+		<-client.Done()
+		lspRun(func() { s.exited(client) })
+	}()
+}
+
+func (s *lspServer) exited(client *lsp.Client) {
+	if !s.running() || s.client != client {
+		return
+	}
+	s.client = nil
+	s.err = fmt.Errorf("The language server %s stopped: %v", s.command, client.Err())
+	InfoBar.Error(s.err)
+	for _, doc := range s.docs {
+		doc.stopTimer()
+		doc.buf.ClearMessages(lspMsgOwner)
+	}
+}
+
+// doc returns the document with the given URI
+func (s *lspServer) doc(uri string) *lspDoc {
+	for _, doc := range s.docs {
+		if doc.uri == uri {
+			return doc
+		}
+	}
+	return nil
+}
+
+func (s *lspServer) notification(method string, params json.RawMessage) {
+	if !s.running() {
+		return
+	}
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p lsp.PublishDiagnosticsParams
+		if json.Unmarshal(params, &p) != nil {
+			return
+		}
+		if doc := s.doc(p.URI); doc != nil {
+			doc.setDiagnostics(p.Diagnostics)
+		}
+	case "window/showMessage":
+		var p lsp.ShowMessageParams
+		if json.Unmarshal(params, &p) != nil {
+			return
+		}
+		if p.Type == lsp.MessageError {
+			InfoBar.Error(p.Message)
+		} else {
+			InfoBar.Message(p.Message)
+		}
+	}
+}
+
+func (d *lspDoc) open() {
+	d.changes, d.dirty = nil, false
+	d.version = 1
+	d.server.client.DidOpen(d.uri, d.languageID, d.version, string(d.buf.Bytes()))
+}
+
+// change records a change of the buffer, which is about to be made
+func (d *lspDoc) change(start, end buffer.Loc, text []byte) {
+	client := d.server.client
+	if client == nil || client.SyncKind() == lsp.SyncNone {
+		return
+	}
+	if client.SyncKind() == lsp.SyncFull {
+		d.dirty = true
+	} else {
+		d.changes = append(d.changes, lsp.TextDocumentContentChangeEvent{
+			Range: &lsp.Range{
+				Start: lspPosition(d.buf.LineArray, start),
+				End:   lspPosition(d.buf.LineArray, end),
+			},
+			Text: string(text),
+		})
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(lspChangeDelay, func() {
+			lspRun(d.flush)
+		})
+	}
+}
+
+func (d *lspDoc) stopTimer() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+// flush sends the pending changes to the server
+func (d *lspDoc) flush() {
+	d.stopTimer()
+	client := d.server.client
+	if client == nil || len(d.changes) == 0 && !d.dirty {
+		return
+	}
+
+	d.version++
+	if d.dirty {
+		d.changes = []lsp.TextDocumentContentChangeEvent{{Text: string(d.buf.Bytes())}}
+	}
+	client.DidChange(d.uri, d.version, d.changes)
+	d.changes, d.dirty = nil, false
+}
+
+func (d *lspDoc) save() {
+	client := d.server.client
+	if client == nil {
+		return
+	}
+	d.flush()
+	if uri := lsp.FileURI(d.buf.AbsPath); uri != d.uri {
+		// the buffer has been saved to a new file
+		client.DidClose(d.uri)
+		d.buf.ClearMessages(lspMsgOwner)
+		d.uri = uri
+		d.open()
+		return
+	}
+	client.DidSave(d.uri, string(d.buf.Bytes()))
+}
+
+// release closes the document when its last buffer is closed
+func (d *lspDoc) release() {
+	d.detach()
+	if client := d.server.client; client != nil {
+		d.flush()
+		client.DidClose(d.uri)
+	}
+}
+
+func (d *lspDoc) detach() {
+	d.stopTimer()
+	d.buf.ChangeCallback = nil
+	d.buf.SaveCallback = nil
+	d.buf.ReleaseCallback = nil
+	delete(d.server.docs, d.buf.SharedBuffer)
+	delete(lspDocs, d.buf.SharedBuffer)
+}
+
+// setDiagnostics shows the diagnostics in the gutter of the buffer
+func (d *lspDoc) setDiagnostics(diagnostics []lsp.Diagnostic) {
+	d.buf.ClearMessages(lspMsgOwner)
+	for _, diag := range diagnostics {
+		kind := buffer.MsgType(buffer.MTInfo)
+		switch diag.Severity {
+		case lsp.SeverityError:
+			kind = buffer.MTError
+		case lsp.SeverityWarning:
+			kind = buffer.MTWarning
+		}
+		msg := diag.Message
+		if diag.Source != "" {
+			msg = diag.Source + ": " + msg
+		}
+		start := lspLoc(d.buf.LineArray, diag.Range.Start)
+		end := lspLoc(d.buf.LineArray, diag.Range.End)
+		d.buf.AddMessage(buffer.NewMessage(lspMsgOwner, msg, start, end, kind))
+	}
+}
+
+// applyEdits makes the edits of a language server in the buffer, as a
+// single undo event
+func applyEdits(b *buffer.Buffer, edits []lsp.TextEdit) error {
+	lsp.SortEdits(edits)
+	matches := make([]buffer.ReplaceMatch, 0, len(edits))
+	for _, e := range edits {
+		start := lspLoc(b.LineArray, e.Range.Start)
+		end := lspLoc(b.LineArray, e.Range.End)
+		if len(matches) > 0 && start.LessThan(matches[len(matches)-1].End) {
+			return errors.New("The language server returned overlapping edits")
+		}
+		matches = append(matches, buffer.ReplaceMatch{
+			Start: start,
+			End:   end,
+			Old:   b.Substr(start, end),
+			New:   []byte(e.NewText),
+		})
+	}
+	if err := b.ApplyReplacements(matches); err != nil {
+		return err
+	}
+	b.RelocateCursors()
+	return nil
+}
+
+// stopLSPServers detaches the buffers from the language servers and
+// returns the servers to shut down
+func stopLSPServers() []*lsp.Client {
+	var clients []*lsp.Client
+	for _, s := range lspServers {
+		for _, doc := range s.docs {
+			doc.detach()
+			doc.buf.ClearMessages(lspMsgOwner)
+		}
+		if s.client != nil {
+			clients = append(clients, s.client)
+		}
+	}
+	lspServers = make(map[string]*lspServer)
+	return clients
+}
+
+func shutdownClients(clients []*lsp.Client) {
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *lsp.Client) {
+			c.Shutdown()
+			wg.Done()
+		}(c)
+	}
+	wg.Wait()
+}
+
+// ShutdownLSP stops all the language servers
+func ShutdownLSP() {
+	shutdownClients(stopLSPServers())
+}
+
+// lspDocument returns the document of the buffer of the pane after sending
+// its pending changes, or nil if it has no running language server
+func (h *BufPane) lspDocument() *lspDoc {
+	lspAttach(h.Buf)
+	doc := lspDocs[h.Buf.SharedBuffer]
+	if doc == nil {
+		InfoBar.Error("No language server for this buffer, see the lspserver option")
+		return nil
+	}
+	if doc.server.client == nil {
+		if doc.server.err != nil {
+			InfoBar.Error(doc.server.err)
+		} else {
+			InfoBar.Message("The language server is starting...")
+		}
+		return nil
+	}
+	doc.flush()
+	return doc
+}
+
+// lspLocations converts the locations returned by a language server to
+// quickfix entries showing the line of each location
+func lspLocations(locs []lsp.Location) []buffer.QuickfixEntry {
+	wd, _ := os.Getwd()
+	files := make(map[string]*buffer.LineArray)
+	entries := make([]buffer.QuickfixEntry, 0, len(locs))
+	for _, l := range locs {
+		path := lsp.URIPath(l.URI)
+		b, ok := files[path]
+		if !ok {
+			if ob := openBuffer(path); ob != nil {
+				b = ob.LineArray
+			} else if data, err := os.ReadFile(path); err == nil {
+				b = buffer.NewLineArray(uint64(len(data)), buffer.FFAuto, bytes.NewReader(data))
+			}
+			files[path] = b
+		}
+
+		e := buffer.QuickfixEntry{Path: path, Loc: buffer.Loc{X: 0, Y: l.Range.Start.Line}, Kind: buffer.MTInfo}
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			e.Path = rel
+		}
+		if b != nil {
+			e.Loc = lspLoc(b, l.Range.Start)
+			if e.Loc.Y < b.LinesNum() {
+				e.Msg = strings.TrimSpace(string(b.LineBytes(e.Loc.Y)))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// LSPHover shows the documentation of the symbol under the cursor, in the
+// infobar if it has a single line or in a split otherwise
+func (h *BufPane) LSPHover() bool {
+	doc := h.lspDocument()
+	if doc == nil {
+		return false
+	}
+	client, uri, pos := doc.server.client, doc.uri, lspPosition(h.Buf.LineArray, h.Cursor.Loc)
+	go func() {
+		text, err := client.Hover(uri, pos)
+		lspRun(func() {
+			text = strings.TrimSpace(text)
+			if err != nil {
+				InfoBar.Error(err)
+			} else if text == "" {
+				InfoBar.Message("No information")
+			} else if !strings.Contains(text, "\n") {
+				InfoBar.Message(text)
+			} else if bp := MainTab().CurPane(); bp != nil {
+				b := buffer.NewBufferFromString(text, "", buffer.BTHelp)
+				b.SetName("Hover")
+				b.SetOptionNative("filetype", "markdown")
+				if bp.Buf.Type == buffer.BTHelp {
+					bp.OpenBuffer(b)
+				} else {
+					bp.HSplitBuf(b)
+				}
+			}
+		})
+	}()
+	return true
+}
+
+// lspJump jumps to the location if there is only one, or puts them in the
+// quickfix list
+func lspJump(title string, locs []lsp.Location, err error) {
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if len(locs) == 0 {
+		InfoBar.Message("No " + strings.ToLower(title) + " found")
+		return
+	}
+	entries := lspLocations(locs)
+	bp := MainTab().CurPane()
+	if bp == nil {
+		return
+	}
+	if len(entries) == 1 {
+		bp.jumpToFile(entries[0].Path, entries[0].Loc)
+		return
+	}
+	SetQuickfix(title, entries)
+	InfoBar.Message(fmt.Sprintf("%s: %d entries", title, len(entries)))
+	if QuickfixPane == nil {
+		bp.OpenQuickfix()
+	}
+}
+
+// LSPDefinition jumps to the definition of the symbol under the cursor
+func (h *BufPane) LSPDefinition() bool {
+	doc := h.lspDocument()
+	if doc == nil {
+		return false
+	}
+	client, uri, pos := doc.server.client, doc.uri, lspPosition(h.Buf.LineArray, h.Cursor.Loc)
+	go func() {
+		locs, err := client.Definition(uri, pos)
+		lspRun(func() { lspJump("Definitions", locs, err) })
+	}()
+	return true
+}
+
+// LSPReferences puts the uses of the symbol under the cursor in the
+// quickfix list
+func (h *BufPane) LSPReferences() bool {
+	doc := h.lspDocument()
+	if doc == nil {
+		return false
+	}
+	client, uri, pos := doc.server.client, doc.uri, lspPosition(h.Buf.LineArray, h.Cursor.Loc)
+	go func() {
+		locs, err := client.References(uri, pos)
+		lspRun(func() { lspJump("References", locs, err) })
+	}()
+	return true
+}
+
+// LSPRename asks for a new name for the symbol under the cursor and renames
+// it in all the files
+func (h *BufPane) LSPRename() bool {
+	if h.lspDocument() == nil {
+		return false
+	}
+	word := string(h.Buf.WordAt(h.Cursor.Loc))
+	InfoBar.Prompt("Rename to: ", word, "LSPRename", nil, func(resp string, canceled bool) {
+		if !canceled && resp != "" {
+			h.lspRename(resp)
+		}
+	})
+	return true
+}
+
+func (h *BufPane) lspRename(name string) {
+	doc := h.lspDocument()
+	if doc == nil {
+		return
+	}
+	client, uri, pos := doc.server.client, doc.uri, lspPosition(h.Buf.LineArray, h.Cursor.Loc)
+	version := doc.version
+	go func() {
+		edits, err := client.Rename(uri, pos, name)
+		lspRun(func() {
+			if err != nil {
+				InfoBar.Error(err)
+				return
+			}
+			if doc.version != version || doc.dirty || len(doc.changes) > 0 {
+				InfoBar.Error("The buffer has been modified during the rename")
+				return
+			}
+			if len(edits) == 0 {
+				InfoBar.Message("Nothing to rename")
+				return
+			}
+			lspApplyWorkspaceEdits(edits)
+		})
+	}()
+}
+
+// lspApplyWorkspaceEdits makes the edits in the buffers of the files, which
+// are opened in new tabs if needed, so that they can be undone in each file
+func lspApplyWorkspaceEdits(edits map[string][]lsp.TextEdit) {
+	width, height := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+
+	uris := make([]string, 0, len(edits))
+	for uri := range edits {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	nedit, nfile := 0, 0
+	for _, uri := range uris {
+		path := lsp.URIPath(uri)
+		b := openBuffer(path)
+		if b == nil {
+			var err error
+			b, err = buffer.NewBufferFromFile(path, buffer.BTDefault)
+			if err != nil {
+				InfoBar.Error(err)
+				continue
+			}
+			Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+		}
+		if err := applyEdits(b, edits[uri]); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		nedit += len(edits[uri])
+		nfile++
+	}
+	InfoBar.Message(fmt.Sprintf("Renamed %d occurrences in %d files", nedit, nfile))
+}
+
+// LSPFormat formats the buffer with its language server
+func (h *BufPane) LSPFormat() bool {
+	doc := h.lspDocument()
+	if doc == nil {
+		return false
+	}
+	client, uri, version := doc.server.client, doc.uri, doc.version
+	tabSize := int(h.Buf.Settings["tabsize"].(float64))
+	insertSpaces := h.Buf.Settings["tabstospaces"].(bool)
+	go func() {
+		edits, err := client.Formatting(uri, tabSize, insertSpaces)
+		lspRun(func() {
+			if err != nil {
+				InfoBar.Error(err)
+				return
+			}
+			if lspDocs[doc.buf.SharedBuffer] != doc || doc.version != version || doc.dirty || len(doc.changes) > 0 {
+				InfoBar.Error("The buffer has been modified during the formatting")
+				return
+			}
+			if err := applyEdits(doc.buf, edits); err != nil {
+				InfoBar.Error(err)
+			}
+		})
+	}()
+	return true
+}
+
+// LSPRestart restarts the language servers and reopens the buffers in them
+func LSPRestart() {
+	go shutdownClients(stopLSPServers())
+	for _, b := range buffer.OpenBuffers {
+		lspAttach(b)
+	}
+}
+
+var lspSubcommands = []string{"definition", "format", "hover", "references", "rename", "restart"}
+
+// LSPCmd runs an action of the language server of the buffer
+func (h *BufPane) LSPCmd(args []string) {
+	if len(args) == 0 {
+		InfoBar.Error("Usage: lsp " + strings.Join(lspSubcommands, "|"))
+		return
+	}
+	switch args[0] {
+	case "hover":
+		h.LSPHover()
+	case "definition":
+		h.LSPDefinition()
+	case "references":
+		h.LSPReferences()
+	case "rename":
+		if len(args) > 1 {
+			h.lspRename(args[1])
+		} else {
+			h.LSPRename()
+		}
+	case "format":
+		h.LSPFormat()
+	case "restart":
+		LSPRestart()
+	default:
+		InfoBar.Error("Unknown lsp command: ", args[0])
+	}
+}
+
+// lspComplete autocompletes the subcommands of the lsp command
+func lspComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+	if strings.Count(string(util.SliceStart(b.LineBytes(c.Y), c.X)), " ") > 1 {
+		return nil, nil
+	}
+
+	var suggestions []string
+	for _, cmd := range lspSubcommands {
+		if strings.HasPrefix(cmd, input) {
+			suggestions = append(suggestions, cmd)
+		}
+	}
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
@@ -0,0 +1,25 @@
+package action
+
+import (
+	"os"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	ulua "github.com/micro-editor/micro/v2/internal/lua"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// TestMain sets up the lua state, the runtime files and the settings which
+// the buffers and the panes of all the tests of the package need
+func TestMain(m *testing.M) {
+	ulua.L = lua.NewState()
+	config.InitRuntimeFiles(false)
+	config.InitGlobalSettings()
+	config.GlobalSettings["backup"] = false
+	config.GlobalSettings["fastdirty"] = true
+
+	retval := m.Run()
+	ulua.L.Close()
+
+	os.Exit(retval)
+}
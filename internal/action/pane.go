@@ -15,3 +15,10 @@ type Pane interface {
 	SetTab(t *Tab)
 	Tab() *Tab
 }
+
+// A bufPaneWrapper is a special pane built on top of a BufPane. Actions
+// and commands run from such a pane are executed by its BufPane.
+type bufPaneWrapper interface {
+	Pane
+	bufPane() *BufPane
+}
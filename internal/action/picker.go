@@ -0,0 +1,165 @@
+package action
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/info"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// paletteActions are the actions listed in the command palette, that is
+// BufKeyActions, to which CommandPalette cannot refer without an
+// initialization cycle since it is one of them. It is set in the init
+// function of bufpane.go.
+var paletteActions map[string]BufKeyAction
+
+// Pick opens a picker listing the given items above a prompt. The items are
+// filtered and ranked with fuzzy matching as the user types, and Up and Down
+// select an item. details are optional texts displayed next to the items,
+// and preview, if not nil, returns the text previewed for the selected
+// item. done is called with the index of the chosen item, or -1 if the
+// picker was canceled or no item matched. The returned picker can be used
+// to add items later.
+func Pick(title string, items, details []string, preview func(int) string, done func(int)) *info.Picker {
+	p := info.NewPicker(title, items)
+	p.Details = details
+	p.Preview = preview
+
+	InfoBar.Prompt(title+": ", "", title, func(resp string) {
+		p.Filter(resp)
+	}, func(resp string, canceled bool) {
+		i, ok := p.Selection()
+		if canceled || !ok {
+			i = -1
+		}
+		if done != nil {
+			done(i)
+		}
+	})
+	InfoBar.Picker = p
+	return p
+}
+
+// bindingNames returns the names of the actions and commands run by each
+// binding of a bufpane, with the keys they are bound to. Commands are
+// prefixed with "> ".
+func bindingNames() map[string][]string {
+	keys := make(map[string][]string)
+	for k, action := range config.Bindings["buffer"] {
+		for action != "" {
+			a := action
+			if idx := util.IndexAnyUnquoted(action, "&|,"); idx >= 0 {
+				a = action[:idx]
+				action = action[idx+1:]
+			} else {
+				action = ""
+			}
+
+			if strings.HasPrefix(a, "command:") || strings.HasPrefix(a, "command-edit:") {
+				if cmd := strings.Fields(strings.SplitN(a, ":", 2)[1]); len(cmd) > 0 {
+					a = "> " + cmd[0]
+				}
+			}
+			keys[a] = append(keys[a], k)
+		}
+	}
+	for _, k := range keys {
+		sort.Strings(k)
+	}
+	return keys
+}
+
+// CommandPalette opens a picker listing all the actions and commands with
+// their key bindings. The chosen action is executed, and a command bar is
+// opened for the chosen command.
+func (h *BufPane) CommandPalette() bool {
+	keys := bindingNames()
+
+	var actions, cmds []string
+	for name := range paletteActions {
+		actions = append(actions, name)
+	}
+	for name := range commands {
+		cmds = append(cmds, name)
+	}
+	sort.Strings(actions)
+	sort.Strings(cmds)
+
+	var items, details []string
+	for _, name := range actions {
+		items = append(items, name)
+		details = append(details, strings.Join(keys[name], ", "))
+	}
+	for _, name := range cmds {
+		items = append(items, "> "+name)
+		details = append(details, strings.Join(keys["> "+name], ", "))
+	}
+
+	Pick("Command palette", items, details, nil, func(i int) {
+		if i < 0 {
+			return
+		}
+		if i < len(actions) {
+			name := actions[i]
+			h.execAction(paletteActions[name], name, nil)
+		} else {
+			CommandEditAction(cmds[i-len(actions)] + " ")(h)
+		}
+	})
+	return true
+}
+
+// PaletteCmd opens the command palette
+func (h *BufPane) PaletteCmd(args []string) {
+	h.CommandPalette()
+}
+
+// PickColorscheme opens a picker listing the colorschemes. The selected
+// colorscheme is previewed, and the chosen one becomes the value of the
+// colorscheme option.
+func (h *BufPane) PickColorscheme() bool {
+	var names []string
+	for _, f := range config.ListRuntimeFiles(config.RTColorscheme) {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+
+	current := config.GetGlobalOption("colorscheme").(string)
+	p := Pick("Colorschemes", names, nil, nil, func(i int) {
+		if i < 0 {
+			// restore the colorscheme of the option
+			if err := config.SetColorscheme(current); err != nil {
+				InfoBar.Error(err)
+			}
+			return
+		}
+		if err := SetGlobalOptionNative("colorscheme", names[i], true); err != nil {
+			InfoBar.Error(err)
+		}
+	})
+	p.OnSelect = func(i int) {
+		// an invalid colorscheme is not previewed, and the error is
+		// reported if it is chosen
+		config.SetColorscheme(names[i])
+	}
+	for i, name := range names {
+		if name == current {
+			p.Select(i)
+		}
+	}
+	return true
+}
+
+// ColorschemeCmd sets the colorscheme, or opens the colorscheme picker if
+// no colorscheme is given
+func (h *BufPane) ColorschemeCmd(args []string) {
+	if len(args) == 0 {
+		h.PickColorscheme()
+		return
+	}
+	if err := SetGlobalOptionNative("colorscheme", args[0], true); err != nil {
+		InfoBar.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+package action
+
+import (
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingNames(t *testing.T) {
+	bindings := config.Bindings["buffer"]
+	defer func() { config.Bindings["buffer"] = bindings }()
+	config.Bindings["buffer"] = map[string]string{
+		"Ctrl-s": "Save",
+		"Alt-s":  "Save",
+		"Ctrl-e": "CommandMode",
+		"Ctrl-f": "Find|FindNext,Center",
+		"Alt-g":  "command:grep foo",
+		"Alt-f":  "command-edit:find ",
+	}
+
+	keys := bindingNames()
+	// the keys of an action are sorted, and the actions chained with other
+	// ones are listed too
+	assert.Equal(t, []string{"Alt-s", "Ctrl-s"}, keys["Save"])
+	assert.Equal(t, []string{"Ctrl-f"}, keys["Find"])
+	assert.Equal(t, []string{"Ctrl-f"}, keys["FindNext"])
+	assert.Equal(t, []string{"Ctrl-f"}, keys["Center"])
+	// the commands are prefixed with "> "
+	assert.Equal(t, []string{"Alt-g"}, keys["> grep"])
+	assert.Equal(t, []string{"Alt-f"}, keys["> find"])
+	assert.Nil(t, keys["Quit"])
+}
+
+func TestPickClose(t *testing.T) {
+	_, err := screen.InitSimScreen()
+	if !assert.NoError(t, err) {
+		return
+	}
+	InfoBar = NewInfoBar()
+	defer func() {
+		screen.Screen.Fini()
+		screen.Screen, InfoBar = nil, nil
+	}()
+
+	closed, chosen := 0, 0
+	p := Pick("Test", []string{"a", "b"}, nil, nil, func(i int) {
+		chosen = i
+	})
+	p.OnClose = func() { closed++ }
+
+	// the picker is closed when its prompt is replaced by another one
+	InfoBar.Prompt("> ", "", "Command", nil, nil)
+	assert.Equal(t, 1, closed)
+	assert.Equal(t, -1, chosen)
+	assert.Nil(t, InfoBar.Picker)
+	InfoBar.DonePrompt(true)
+	assert.Equal(t, 1, closed)
+
+	// and when the info bar is reset
+	p = Pick("Test", []string{"a", "b"}, nil, nil, nil)
+	p.OnClose = func() { closed++ }
+	InfoBar.Reset()
+	assert.Equal(t, 2, closed)
+	assert.Nil(t, InfoBar.Picker)
+}
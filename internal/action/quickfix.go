@@ -0,0 +1,227 @@
+package action
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/shell"
+)
+
+// number of lines before the first entry in the quickfix pane
+const quickfixPaneHeader = 2
+
+// A QuickfixList is a list of locations, such as compiler errors or search
+// results, which can be browsed with the QuickfixNext and QuickfixPrev
+// actions or in the quickfix pane
+type QuickfixList struct {
+	Title   string
+	Entries []buffer.QuickfixEntry
+
+	// index of the current entry, -1 if no entry has been visited yet
+	cur int
+}
+
+// Quickfix is the global quickfix list
+var Quickfix = &QuickfixList{cur: -1}
+
+// QuickfixPane is the pane showing the quickfix list, if it is open
+var QuickfixPane *ListPane
+
+// SetQuickfix replaces the content of the quickfix list
+func SetQuickfix(title string, entries []buffer.QuickfixEntry) {
+	Quickfix.Title = title
+	Quickfix.Entries = entries
+	Quickfix.cur = -1
+	refreshQuickfixPane()
+}
+
+// AddQuickfix appends an entry to the quickfix list
+func AddQuickfix(e *buffer.QuickfixEntry) {
+	Quickfix.Entries = append(Quickfix.Entries, *e)
+	refreshQuickfixPane()
+}
+
+// ClearQuickfix empties the quickfix list and gives it a new title
+func ClearQuickfix(title string) {
+	SetQuickfix(title, nil)
+}
+
+// GetQuickfix returns the global quickfix list
+func GetQuickfix() *QuickfixList {
+	return Quickfix
+}
+
+func kindName(kind buffer.MsgType) string {
+	switch kind {
+	case buffer.MTError:
+		return "error"
+	case buffer.MTWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+// quickfixLine returns the text describing the given entry in the quickfix pane
+func quickfixLine(e buffer.QuickfixEntry) string {
+	if e.Loc.X < 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", e.Path, e.Loc.Y+1, kindName(e.Kind), e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.Path, e.Loc.Y+1, e.Loc.X+1, kindName(e.Kind), e.Msg)
+}
+
+func refreshQuickfixPane() {
+	if QuickfixPane == nil {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d entries)\n", Quickfix.Title, len(Quickfix.Entries))
+	for _, e := range Quickfix.Entries {
+		b.WriteString("\n")
+		b.WriteString(quickfixLine(e))
+	}
+	QuickfixPane.SetText(b.String())
+	if Quickfix.cur >= 0 {
+		QuickfixPane.Cursor.GotoLoc(buffer.Loc{0, Quickfix.cur + quickfixPaneHeader})
+		QuickfixPane.Relocate()
+	}
+}
+
+// OpenQuickfix opens the quickfix pane below the current pane, or activates
+// it if it is already open
+func (h *BufPane) OpenQuickfix() {
+	if QuickfixPane != nil {
+		for i, p := range h.tab.Panes {
+			if p == Pane(QuickfixPane) {
+				h.tab.SetActive(i)
+				return
+			}
+		}
+		QuickfixPane.Quit()
+	}
+
+	l := h.newListPane("Quickfix")
+	l.enter = func() {
+		i := l.Cursor.Y - quickfixPaneHeader
+		if i >= 0 && i < len(Quickfix.Entries) {
+			l.quickfixJump(i)
+		}
+	}
+	l.keys['q'] = func() { l.Quit() }
+	l.list.CloseCallback = func() {
+		if QuickfixPane == l {
+			QuickfixPane = nil
+		}
+	}
+	l.setPane(l)
+
+	QuickfixPane = l
+	refreshQuickfixPane()
+	l.Cursor.GotoLoc(buffer.Loc{0, quickfixPaneHeader}.Clamp(l.Buf.Start(), l.Buf.End()))
+	l.Relocate()
+}
+
+// QuickfixCmd opens the quickfix pane. If a command is given, it is run in
+// the background and its output, parsed with the errorformat option, fills
+// the quickfix list.
+func (h *BufPane) QuickfixCmd(args []string) {
+	if len(args) == 0 {
+		h.OpenQuickfix()
+		return
+	}
+
+	errorformat := h.Buf.Settings["errorformat"].(string)
+	if _, err := buffer.CompileErrorFormat(errorformat); err != nil {
+		InfoBar.Error("Invalid errorformat: ", err)
+		return
+	}
+	title := shellquote.Join(args...)
+	InfoBar.Message("Running ", title, "...")
+	shell.JobSpawn(args[0], args[1:], nil, nil, func(out string, _ []any) {
+		entries, _ := buffer.ParseErrorFormat(out, errorformat, buffer.MTError)
+		SetQuickfix(title, entries)
+		InfoBar.Message(fmt.Sprintf("%s: %d entries", title, len(entries)))
+		if len(entries) > 0 && QuickfixPane == nil {
+			if bp := MainTab().CurPane(); bp != nil {
+				bp.OpenQuickfix()
+			}
+		}
+	})
+}
+
+// quickfixJump opens the file of the i-th entry of the quickfix list at
+// the location of the entry
+func (h *BufPane) quickfixJump(i int) bool {
+	e := Quickfix.Entries[i]
+	Quickfix.cur = i
+	loc := e.Loc
+	if loc.X < 0 {
+		loc.X = 0
+	}
+
+	if QuickfixPane != nil && QuickfixPane.BufPane == h {
+		QuickfixPane.openFile(e.Path, loc)
+	} else if !h.jumpToFile(e.Path, loc) {
+		return false
+	}
+
+	if QuickfixPane != nil {
+		QuickfixPane.Cursor.GotoLoc(buffer.Loc{0, i + quickfixPaneHeader})
+		QuickfixPane.Relocate()
+	}
+	InfoBar.Message(fmt.Sprintf("(%d of %d) %s", i+1, len(Quickfix.Entries), e.Msg))
+	return true
+}
+
+// jumpToFile moves the cursor to the given location of a file. The file is
+// opened in the pane, or in a split above it if the buffer of the pane has
+// unsaved changes.
+func (h *BufPane) jumpToFile(path string, loc buffer.Loc) bool {
+	abs, _ := filepath.Abs(path)
+	if h.Buf.AbsPath == abs {
+		h.GotoLoc(loc.Clamp(h.Buf.Start(), h.Buf.End()))
+		return true
+	}
+
+	b, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+	if err != nil {
+		InfoBar.Error(err)
+		return false
+	}
+	if h.Buf.Modified() && !h.Buf.Shared() {
+		h.HSplitIndex(b, false).GotoLoc(loc.Clamp(b.Start(), b.End()))
+	} else {
+		h.OpenBuffer(b)
+		h.GotoLoc(loc.Clamp(b.Start(), b.End()))
+	}
+	return true
+}
+
+// QuickfixNext jumps to the next entry of the quickfix list
+func (h *BufPane) QuickfixNext() bool {
+	if len(Quickfix.Entries) == 0 {
+		InfoBar.Error("The quickfix list is empty")
+		return false
+	}
+	if Quickfix.cur+1 >= len(Quickfix.Entries) {
+		InfoBar.Message("No more items")
+		return false
+	}
+	return h.quickfixJump(Quickfix.cur + 1)
+}
+
+// QuickfixPrev jumps to the previous entry of the quickfix list
+func (h *BufPane) QuickfixPrev() bool {
+	if len(Quickfix.Entries) == 0 {
+		InfoBar.Error("The quickfix list is empty")
+		return false
+	}
+	if Quickfix.cur <= 0 {
+		InfoBar.Message("No more items")
+		return false
+	}
+	return h.quickfixJump(Quickfix.cur - 1)
+}
@@ -0,0 +1,382 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/zyedidia/glob"
+)
+
+// number of lines before the first file in the replace in files pane
+const replaceFilesPaneHeader = 3
+
+// A replaceFile is a file containing matches of a replaceinfiles search
+type replaceFile struct {
+	path string
+	// location of the first match
+	loc     buffer.Loc
+	count   int
+	enabled bool
+}
+
+// A ReplaceFilesPane lists the files in which replaceinfiles found matches
+// so that the user can choose the files in which the replacement is made.
+// The files are searched in the background.
+type ReplaceFilesPane struct {
+	*ListPane
+
+	regex   *regexp.Regexp
+	replace []byte
+	// description of the search and the replacement
+	desc  string
+	files []replaceFile
+	stop  chan struct{}
+	// whether the files are still being searched
+	searching bool
+}
+
+// openBuffer returns the open buffer of the file at the given path, if any
+func openBuffer(path string) *buffer.Buffer {
+	abs, _ := filepath.Abs(path)
+	for _, b := range buffer.OpenBuffers {
+		if b.AbsPath == abs && b.Type == buffer.BTDefault {
+			return b
+		}
+	}
+	return nil
+}
+
+// compileFileGlob compiles a glob matching the paths of the files relative
+// to the directory where the search is made. As in the glob sections of the
+// settings, `*` also matches slashes, and `**/` matches any number of
+// directories. A glob without a slash is matched against the base name of
+// the files.
+func compileFileGlob(pattern string) (*glob.Glob, error) {
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return glob.Compile(strings.ReplaceAll(pattern, "**/", "{*/,}"))
+}
+
+// matchGlob returns whether the file at the given path, relative to the
+// directory where the search is made, matches g
+func matchGlob(g *glob.Glob, path string) bool {
+	return g.MatchString(filepath.ToSlash(path))
+}
+
+// readReplacements returns the replacements of regex in the file at the
+// given path. The matches are found as in a buffer so that they are the
+// same as the ones which are replaced. It returns false if the file cannot
+// be read or is binary.
+func readReplacements(path string, regex *regexp.Regexp, replace []byte, settings map[string]any) ([]buffer.ReplaceMatch, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || util.IsBinary(data) {
+		return nil, false
+	}
+	return buffer.FindTextReplacements(data, settings, regex, replace, true), true
+}
+
+// fileReplacements is readReplacements, searching the buffer of the file
+// instead if it is open
+func fileReplacements(path string, regex *regexp.Regexp, replace []byte, settings map[string]any) ([]buffer.ReplaceMatch, bool) {
+	if b := openBuffer(path); b != nil {
+		return b.FindReplacements(b.Start(), b.End(), regex, replace, true), true
+	}
+	return readReplacements(path, regex, replace, settings)
+}
+
+// findReplaceFiles returns the files below root, only those matching g if
+// it is not nil, which contain matches of regex. It runs in the
+// background, so the files whose absolute path is in open are not searched
+// since their buffers may be modified meanwhile: they are returned with no
+// matches, and must be searched with fileReplacements. The search stops
+// when stop is closed.
+func findReplaceFiles(root string, g *glob.Glob, regex *regexp.Regexp, replace []byte, settings map[string]any, open map[string]bool, stop chan struct{}) ([]replaceFile, error) {
+	var files []replaceFile
+	err := util.WalkFiles(root, true, func(path string) bool {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && g != nil && !matchGlob(g, rel) {
+			return true
+		}
+		if abs, _ := filepath.Abs(path); open[abs] {
+			files = append(files, replaceFile{path: filepath.Clean(path), enabled: true})
+		} else if m, ok := readReplacements(path, regex, replace, settings); ok && len(m) > 0 {
+			files = append(files, replaceFile{filepath.Clean(path), m[0].Start, len(m), true})
+		}
+		return true
+	})
+	return files, err
+}
+
+// ReplaceInFilesCmd replaces a regular expression in all the files below
+// the working directory, optionally only in the files matching a glob. The
+// files containing matches are listed first for confirmation.
+func (h *BufPane) ReplaceInFilesCmd(args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		InfoBar.Error("usage: replaceinfiles pattern replacement [glob]")
+		return
+	}
+	var g *glob.Glob
+	if len(args) == 3 {
+		var err error
+		if g, err = compileFileGlob(args[2]); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+	}
+
+	search := "(?m)" + args[0]
+	if h.Buf.Settings["ignorecase"].(bool) {
+		search = "(?im)" + args[0]
+	}
+	regex, err := regexp.Compile(search)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	// the settings and the open buffers are only read from the main loop
+	settings := make(map[string]any, len(h.Buf.Settings))
+	for k, v := range h.Buf.Settings {
+		settings[k] = v
+	}
+	open := make(map[string]bool)
+	for _, b := range buffer.OpenBuffers {
+		if b.Type == buffer.BTDefault {
+			open[b.AbsPath] = true
+		}
+	}
+
+	r := new(ReplaceFilesPane)
+	r.ListPane = h.newListPane("Replace in files")
+	r.regex = regex
+	r.replace = []byte(args[1])
+	r.desc = fmt.Sprintf("%q with %q", args[0], args[1])
+	r.stop = make(chan struct{})
+	r.searching = true
+	r.enter = r.Open
+	r.keys[' '] = r.Toggle
+	r.keys['a'] = func() { r.setAll(true) }
+	r.keys['n'] = func() { r.setAll(false) }
+	r.keys['r'] = r.Apply
+	r.keys['q'] = func() { r.Quit() }
+	r.setPane(r)
+
+	r.list.CloseCallback = func() {
+		close(r.stop)
+	}
+	r.SetText(fmt.Sprintf("Searching for %q...\n", args[0]))
+
+	go func() {
+		files, err := findReplaceFiles(".", g, regex, r.replace, settings, open, r.stop)
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) { r.done(files, settings, err) },
+		}
+	}()
+}
+
+// done is called when the search has finished. The files which were open
+// are then searched in their buffers.
+func (r *ReplaceFilesPane) done(files []replaceFile, settings map[string]any, err error) {
+	select {
+	case <-r.stop:
+		// the pane has been closed
+		return
+	default:
+	}
+
+	r.searching = false
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	for _, f := range files {
+		if f.count == 0 {
+			m, ok := fileReplacements(f.path, r.regex, r.replace, settings)
+			if !ok || len(m) == 0 {
+				continue
+			}
+			f.loc, f.count = m[0].Start, len(m)
+		}
+		r.files = append(r.files, f)
+	}
+	if len(r.files) == 0 {
+		InfoBar.Message("Nothing matched")
+	}
+
+	r.refresh()
+	r.Cursor.GotoLoc(buffer.Loc{0, replaceFilesPaneHeader})
+	r.Relocate()
+}
+
+func (r *ReplaceFilesPane) refresh() {
+	nfile, nmatch := 0, 0
+	for _, f := range r.files {
+		if f.enabled {
+			nfile++
+			nmatch += f.count
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Replace %s: %d matches in %d of %d files selected\n", r.desc, nmatch, nfile, len(r.files))
+	b.WriteString("Space: toggle  a: select all  n: select none  Enter: open  r: replace  q: cancel\n")
+	for _, f := range r.files {
+		check := "[ ]"
+		if f.enabled {
+			check = "[x]"
+		}
+		fmt.Fprintf(&b, "\n%s %s (%d)", check, f.path, f.count)
+	}
+	r.SetText(b.String())
+}
+
+// checkSearched returns whether the search is finished, and tells the user
+// to wait otherwise
+func (r *ReplaceFilesPane) checkSearched() bool {
+	if r.searching {
+		InfoBar.Message("Search not finished")
+		return false
+	}
+	return true
+}
+
+// file returns the file under the cursor, or nil
+func (r *ReplaceFilesPane) file() *replaceFile {
+	i := r.Cursor.Y - replaceFilesPaneHeader
+	if i < 0 || i >= len(r.files) {
+		return nil
+	}
+	return &r.files[i]
+}
+
+// Toggle includes or excludes the file under the cursor and moves to the
+// next file
+func (r *ReplaceFilesPane) Toggle() {
+	if !r.checkSearched() {
+		return
+	}
+	f := r.file()
+	if f == nil {
+		return
+	}
+	f.enabled = !f.enabled
+	r.refresh()
+	r.Cursor.Down()
+	r.Relocate()
+}
+
+func (r *ReplaceFilesPane) setAll(enabled bool) {
+	if !r.checkSearched() {
+		return
+	}
+	for i := range r.files {
+		r.files[i].enabled = enabled
+	}
+	r.refresh()
+}
+
+// Open opens the file under the cursor at its first match
+func (r *ReplaceFilesPane) Open() {
+	f := r.file()
+	if f == nil {
+		return
+	}
+	r.openFile(f.path, f.loc)
+}
+
+// Apply makes the replacement in the selected files and then proposes to
+// save them. The replacement is made in the buffers of the files, which
+// are opened in new tabs if needed, so that it can be undone in each file.
+// The buffers which were already modified are not saved.
+func (r *ReplaceFilesPane) Apply() {
+	if !r.checkSearched() {
+		return
+	}
+	width, height := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+
+	// bufs are the buffers to save, modified those which had unsaved
+	// changes before the replacement
+	var bufs, modified []*buffer.Buffer
+	nmatch := 0
+	for _, f := range r.files {
+		if !f.enabled {
+			continue
+		}
+		b := openBuffer(f.path)
+		if b == nil {
+			var err error
+			b, err = buffer.NewBufferFromFile(f.path, buffer.BTDefault)
+			if err != nil {
+				InfoBar.Error(err)
+				continue
+			}
+			Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+		}
+
+		matches := b.FindReplacements(b.Start(), b.End(), r.regex, r.replace, true)
+		if len(matches) == 0 {
+			continue
+		}
+		wasModified := b.Modified()
+		if err := b.ApplyReplacements(matches); err != nil {
+			InfoBar.Error(err)
+			continue
+		}
+		b.RelocateCursors()
+		if wasModified {
+			modified = append(modified, b)
+		} else {
+			bufs = append(bufs, b)
+		}
+		nmatch += len(matches)
+	}
+	r.Quit()
+
+	nfile := len(bufs) + len(modified)
+	if nfile == 0 {
+		return
+	}
+	msg := fmt.Sprintf("Replaced %d occurrences in %d files.", nmatch, nfile)
+	if len(modified) > 0 {
+		paths := make([]string, len(modified))
+		for i, b := range modified {
+			paths[i] = b.Path
+		}
+		msg += " Not saving the files with other unsaved changes: " + strings.Join(paths, ", ") + "."
+	}
+	if len(bufs) == 0 {
+		InfoBar.Message(msg)
+		return
+	}
+	if len(modified) > 0 {
+		msg += fmt.Sprintf(" Save the %d other files? (y,n,esc)", len(bufs))
+	} else {
+		msg += " Save all modified files? (y,n,esc)"
+	}
+	InfoBar.YNPrompt(msg, func(yes, canceled bool) {
+		if !yes || canceled {
+			return
+		}
+		for _, b := range bufs {
+			if err := b.Save(); err != nil {
+				InfoBar.Error(err)
+				return
+			}
+		}
+		InfoBar.Message(fmt.Sprintf("Saved %d files", len(bufs)))
+	})
+}
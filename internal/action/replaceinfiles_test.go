@@ -0,0 +1,90 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", filepath.Join("internal", "main.go"), true},
+		{"*.go", "main.c", false},
+		{"main.go", filepath.Join("cmd", "main.go"), true},
+		{"main.go", "amain.go", false},
+		{"src/*.c", filepath.Join("src", "a.c"), true},
+		{"src/*.c", filepath.Join("lib", "src", "a.c"), false},
+		{"src/*.c", "a.c", false},
+		{"src/**/*.go", filepath.Join("src", "a", "b", "c.go"), true},
+		{"src/**/*.go", filepath.Join("src", "a.go"), true},
+		{"src/**/*.go", filepath.Join("lib", "a", "b.go"), false},
+		{"src/**/test/*.go", filepath.Join("src", "a", "b", "test", "c.go"), true},
+		{"src/**/test/*.go", filepath.Join("src", "a", "test.go"), false},
+		{"*.{c,h}", filepath.Join("src", "a.h"), true},
+	}
+	for _, test := range tests {
+		g, err := compileFileGlob(test.glob)
+		if assert.NoError(t, err, test.glob) {
+			assert.Equal(t, test.match, matchGlob(g, test.path), test.glob+" "+test.path)
+		}
+	}
+}
+
+func TestFindReplaceFiles(t *testing.T) {
+	settings := config.DefaultCommonSettings()
+
+	root := t.TempDir()
+	write := func(name, text string) {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(text), 0644))
+	}
+	// the line endings and the multi-line matches are handled as in the
+	// buffers where the replacement is made
+	write("a.go", "foo\r\nbar foo\r\n")
+	write(filepath.Join("sub", "b.go"), "foo\nbar\nfoo\n")
+	write("c.txt", "foo\n")
+	write("d.go", "bar\n")
+
+	regex := regexp.MustCompile(`(?m)o$|o\nb`)
+	replace := []byte("x")
+	g, err := compileFileGlob("*.go")
+	assert.NoError(t, err)
+	files, err := findReplaceFiles(root, g, regex, replace, settings, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	for _, f := range files {
+		b, err := buffer.NewBufferFromFile(f.path, buffer.BTDefault)
+		assert.NoError(t, err)
+		matches := b.FindReplacements(b.Start(), b.End(), regex, replace, true)
+		assert.Equal(t, len(matches), f.count, f.path)
+		assert.Equal(t, matches[0].Start, f.loc, f.path)
+		assert.NoError(t, b.ApplyReplacements(matches))
+		b.Close()
+	}
+	assert.Equal(t, filepath.Join(root, "a.go"), files[0].path)
+	assert.Equal(t, 2, files[0].count)
+	assert.Equal(t, filepath.Join(root, "sub", "b.go"), files[1].path)
+	assert.Equal(t, 2, files[1].count)
+
+	// the open files are left to be searched in their buffers
+	open := map[string]bool{filepath.Join(root, "a.go"): true, filepath.Join(root, "d.go"): true}
+	files, err = findReplaceFiles(root, g, regex, replace, settings, open, nil)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Equal(t, filepath.Join(root, "a.go"), files[0].path)
+	assert.Equal(t, 0, files[0].count)
+	assert.Equal(t, filepath.Join(root, "d.go"), files[1].path)
+	assert.Equal(t, 0, files[1].count)
+}
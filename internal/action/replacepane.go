@@ -0,0 +1,175 @@
+package action
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// number of lines before the first match in the replace pane
+const replacePaneHeader = 3
+
+// A ReplacePane previews the replacements made by the replace command. Each
+// match is shown before and after the replacement and can be toggled on or
+// off before the selected replacements are applied.
+type ReplacePane struct {
+	*ListPane
+
+	// the pane containing the buffer in which the replacements are made
+	target *BufPane
+	buf    *buffer.Buffer
+	// description of the search and the replacement
+	desc    string
+	matches []buffer.ReplaceMatch
+	enabled []bool
+}
+
+// replacePreview opens a pane previewing the replacements of regex in the
+// given area of the buffer
+func (h *BufPane) replacePreview(desc string, start, end buffer.Loc, regex *regexp.Regexp, replace []byte, captureGroups bool) {
+	matches := h.Buf.FindReplacements(start, end, regex, replace, captureGroups)
+	if len(matches) == 0 {
+		InfoBar.Message("Nothing matched")
+		return
+	}
+
+	r := new(ReplacePane)
+	r.ListPane = h.newListPane("Replace preview")
+	r.target = h
+	r.buf = h.Buf
+	r.desc = desc
+	r.matches = matches
+	r.enabled = make([]bool, len(matches))
+	for i := range r.enabled {
+		r.enabled[i] = true
+	}
+	r.enter = r.Show
+	r.keys[' '] = r.Toggle
+	r.keys['a'] = func() { r.setAll(true) }
+	r.keys['n'] = func() { r.setAll(false) }
+	r.keys['r'] = r.Apply
+	r.keys['q'] = func() { r.Quit() }
+	r.setPane(r)
+
+	r.refresh()
+	r.Cursor.GotoLoc(buffer.Loc{0, replacePaneHeader})
+	r.Relocate()
+}
+
+// replaceLine returns the line of the match m, with the text of the match
+// replaced by text
+func (r *ReplacePane) replaceLine(m buffer.ReplaceMatch, text []byte) string {
+	b := r.buf
+	start := string(b.LineBytes(m.Start.Y))
+	end := string(b.LineBytes(m.End.Y))
+	line := util.SliceStartStr(start, m.Start.X) + string(text) + util.SliceEndStr(end, m.End.X)
+	return strings.ReplaceAll(line, "\n", `\n`)
+}
+
+func (r *ReplacePane) refresh() {
+	n := 0
+	for _, e := range r.enabled {
+		if e {
+			n++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Replace %s: %d of %d matches selected\n", r.desc, n, len(r.matches))
+	b.WriteString("Space: toggle  a: select all  n: select none  Enter: show  r: replace  q: cancel\n")
+	for i, m := range r.matches {
+		check := "[ ]"
+		if r.enabled[i] {
+			check = "[x]"
+		}
+		pos := fmt.Sprintf("%d:%d", m.Start.Y+1, m.Start.X+1)
+		fmt.Fprintf(&b, "\n%s %-8s - %s", check, pos, r.replaceLine(m, m.Old))
+		fmt.Fprintf(&b, "\n             + %s", r.replaceLine(m, m.New))
+	}
+	r.SetText(b.String())
+}
+
+// match returns the index of the match under the cursor, or -1
+func (r *ReplacePane) match() int {
+	if r.Cursor.Y < replacePaneHeader {
+		return -1
+	}
+	i := (r.Cursor.Y - replacePaneHeader) / 2
+	if i >= len(r.matches) {
+		return -1
+	}
+	return i
+}
+
+// Toggle enables or disables the replacement of the match under the cursor
+// and moves to the next match
+func (r *ReplacePane) Toggle() {
+	i := r.match()
+	if i < 0 {
+		return
+	}
+	r.enabled[i] = !r.enabled[i]
+	r.refresh()
+	if i+1 < len(r.matches) {
+		r.Cursor.GotoLoc(buffer.Loc{0, replacePaneHeader + 2*(i+1)})
+		r.Relocate()
+	}
+}
+
+func (r *ReplacePane) setAll(enabled bool) {
+	for i := range r.enabled {
+		r.enabled[i] = enabled
+	}
+	r.refresh()
+}
+
+// targetOpen returns whether the buffer is still shown in the target pane
+func (r *ReplacePane) targetOpen() bool {
+	return r.originPane() != nil && r.target.Buf == r.buf
+}
+
+// Show selects the match under the cursor in the target pane
+func (r *ReplacePane) Show() {
+	i := r.match()
+	if i < 0 || !r.targetOpen() {
+		return
+	}
+	m := r.matches[i]
+	r.target.Cursor.SetSelectionStart(m.Start)
+	r.target.Cursor.SetSelectionEnd(m.End)
+	r.target.GotoLoc(m.Start)
+}
+
+// Apply performs the selected replacements as a single undo event and
+// closes the pane
+func (r *ReplacePane) Apply() {
+	if !r.targetOpen() {
+		InfoBar.Error("The buffer is not open anymore")
+		return
+	}
+
+	var matches []buffer.ReplaceMatch
+	for i, m := range r.matches {
+		if r.enabled[i] {
+			matches = append(matches, m)
+		}
+	}
+	if err := r.buf.ApplyReplacements(matches); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	r.target.Cursor.ResetSelection()
+	r.target.Buf.RelocateCursors()
+	r.target.Relocate()
+
+	r.Quit()
+	r.tab.SetActive(r.tab.GetPane(r.target.ID()))
+	if len(matches) == 1 {
+		InfoBar.Message("Replaced 1 occurrence")
+	} else {
+		InfoBar.Message(fmt.Sprintf("Replaced %d occurrences", len(matches)))
+	}
+}
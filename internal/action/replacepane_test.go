@@ -0,0 +1,96 @@
+package action
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/stretchr/testify/assert"
+)
+
+// openReplacePreview opens the replace preview of regex in a tab showing a
+// buffer with the given text
+func openReplacePreview(t *testing.T, text string, regex *regexp.Regexp, replace string) (*BufPane, *ReplacePane) {
+	b := buffer.NewBufferFromString(text, "", buffer.BTDefault)
+	t.Cleanup(b.Close)
+	tab := NewTabFromBuffer(0, 0, 80, 24, b)
+	h := tab.Panes[0].(*BufPane)
+	h.replacePreview("'o' with '0'", b.Start(), b.End(), regex, []byte(replace), true)
+	if !assert.Len(t, tab.Panes, 2) {
+		t.FailNow()
+	}
+	return h, tab.Panes[1].(*ReplacePane)
+}
+
+func TestReplacePreview(t *testing.T) {
+	h, r := openReplacePreview(t, "foo\nbar\nbaz boo", regexp.MustCompile("o"), "0")
+	assert.Equal(t, "Replace 'o' with '0': 4 of 4 matches selected\n"+
+		"Space: toggle  a: select all  n: select none  Enter: show  r: replace  q: cancel\n"+
+		"\n[x] 1:2      - foo"+
+		"\n             + f0o"+
+		"\n[x] 1:3      - foo"+
+		"\n             + fo0"+
+		"\n[x] 3:6      - baz boo"+
+		"\n             + baz b0o"+
+		"\n[x] 3:7      - baz boo"+
+		"\n             + baz bo0", string(r.list.Bytes()))
+
+	// the matches are toggled from their two lines
+	assert.Equal(t, replacePaneHeader, r.Cursor.Y)
+	assert.Equal(t, 0, r.match())
+	r.Toggle()
+	assert.Equal(t, []bool{false, true, true, true}, r.enabled)
+	assert.Equal(t, 1, r.match())
+	r.Cursor.Y++
+	assert.Equal(t, 1, r.match())
+	r.Toggle()
+	assert.Equal(t, []bool{false, false, true, true}, r.enabled)
+	assert.Equal(t, "Replace 'o' with '0': 2 of 4 matches selected", r.list.Line(0))
+
+	r.Cursor.Y = 0
+	assert.Equal(t, -1, r.match())
+
+	// the match under the cursor is selected in the buffer
+	r.Cursor.Y = replacePaneHeader + 4
+	r.Show()
+	assert.Equal(t, [2]buffer.Loc{{5, 2}, {6, 2}}, h.Cursor.CurSelection)
+
+	r.setAll(false)
+	assert.Equal(t, []bool{false, false, false, false}, r.enabled)
+	r.setAll(true)
+	r.Cursor.Y = replacePaneHeader + 6
+	r.Toggle()
+	assert.Equal(t, []bool{true, true, true, false}, r.enabled)
+}
+
+func TestReplacePreviewMultiLine(t *testing.T) {
+	_, r := openReplacePreview(t, "foo\nbar", regexp.MustCompile(`o\nb`), "-")
+
+	// the matches spanning several lines are shown on one line
+	assert.Equal(t, "[x] 1:3      - foo\\nbar", r.list.Line(3))
+	assert.Equal(t, "             + fo-ar", r.list.Line(4))
+}
+
+func TestReplacePreviewApply(t *testing.T) {
+	_, err := screen.InitSimScreen()
+	if !assert.NoError(t, err) {
+		return
+	}
+	InfoBar = NewInfoBar()
+	defer func() {
+		screen.Screen.Fini()
+		screen.Screen, InfoBar = nil, nil
+	}()
+
+	h, r := openReplacePreview(t, "foo\nbar\nbaz boo", regexp.MustCompile("o"), "0")
+	r.Toggle()
+	r.Apply()
+
+	// only the selected matches are replaced, in one undo event
+	assert.Equal(t, "fo0\nbar\nbaz b00", string(h.Buf.Bytes()))
+	assert.Equal(t, "Replaced 3 occurrences", InfoBar.Msg)
+	assert.Len(t, h.tab.Panes, 1)
+	h.Buf.Undo()
+	assert.Equal(t, "foo\nbar\nbaz boo", string(h.Buf.Bytes()))
+}
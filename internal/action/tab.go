@@ -181,8 +181,11 @@ func (t *TabList) ResetMouse() {
 		tab.release = true
 
 		for _, p := range tab.Panes {
-			if bp, ok := p.(*BufPane); ok {
+			switch bp := p.(type) {
+			case *BufPane:
 				bp.resetMouse()
+			case bufPaneWrapper:
+				bp.bufPane().resetMouse()
 			}
 		}
 	}
@@ -392,10 +395,14 @@ func (t *Tab) Resize() {
 }
 
 // CurPane returns the currently active pane
+// Special panes built on top of a BufPane, such as list panes, return
+// their underlying BufPane
 func (t *Tab) CurPane() *BufPane {
-	p, ok := t.Panes[t.active].(*BufPane)
-	if !ok {
-		return nil
+	switch p := t.Panes[t.active].(type) {
+	case *BufPane:
+		return p
+	case bufPaneWrapper:
+		return p.bufPane()
 	}
-	return p
+	return nil
 }
@@ -0,0 +1,142 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A tagStackEntry is a location from which a tag was jumped to
+type tagStackEntry struct {
+	path string
+	loc  buffer.Loc
+}
+
+// tagStack holds the locations to go back to with PopTag
+var tagStack []tagStackEntry
+
+// JumpToTag jumps to the definition of the word under the cursor found in
+// the tags file of the buffer
+func (h *BufPane) JumpToTag() bool {
+	name := string(h.Buf.WordAt(h.Cursor.Loc))
+	if name == "" {
+		InfoBar.Error("No word under the cursor")
+		return false
+	}
+	return h.jumpToTag(name)
+}
+
+// PopTag goes back to the location from which the last tag was jumped to
+func (h *BufPane) PopTag() bool {
+	if len(tagStack) == 0 {
+		InfoBar.Error("The tag stack is empty")
+		return false
+	}
+	e := tagStack[len(tagStack)-1]
+	tagStack = tagStack[:len(tagStack)-1]
+	return h.jumpToFile(e.path, e.loc)
+}
+
+// TagCmd jumps to the definition of the given symbol, or of the word under
+// the cursor if no symbol is given
+func (h *BufPane) TagCmd(args []string) {
+	if len(args) > 1 {
+		InfoBar.Error("Usage: tag ['name']")
+		return
+	}
+	if len(args) == 0 {
+		h.JumpToTag()
+		return
+	}
+	h.jumpToTag(args[0])
+}
+
+// jumpToTag jumps to the tag with the given name, or lets the user pick one
+// if there are several
+func (h *BufPane) jumpToTag(name string) bool {
+	index, err := h.Buf.Tags()
+	if err != nil {
+		InfoBar.Error(err)
+		return false
+	}
+	tags := index.Lookup(name)
+	if len(tags) == 0 {
+		InfoBar.Error("Tag not found: ", name)
+		return false
+	}
+	if len(tags) == 1 {
+		return h.gotoTag(tags[0])
+	}
+
+	wd, _ := os.Getwd()
+	items := make([]string, len(tags))
+	details := make([]string, len(tags))
+	for i, t := range tags {
+		path := t.Path
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		if t.Pattern != "" {
+			items[i] = path + ": " + strings.TrimSpace(t.Pattern)
+		} else {
+			items[i] = path + ":" + strconv.Itoa(t.Line)
+		}
+		details[i] = t.Kind
+	}
+	Pick(name, items, details, nil, func(i int) {
+		if i >= 0 {
+			h.gotoTag(tags[i])
+		}
+	})
+	return true
+}
+
+// gotoTag opens the file of the given tag at its location and pushes the
+// current location on the tag stack
+func (h *BufPane) gotoTag(t buffer.Tag) bool {
+	from := tagStackEntry{h.Buf.AbsPath, h.Cursor.Loc}
+	if !h.jumpToFile(t.Path, buffer.Loc{0, 0}) {
+		return false
+	}
+	if from.path != "" {
+		tagStack = append(tagStack, from)
+	}
+	bp := MainTab().CurPane()
+	if bp != nil {
+		bp.GotoLoc(t.Locate(bp.Buf))
+	}
+	return true
+}
+
+// tagComplete autocompletes the names of the tags of the current buffer
+func tagComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	bp := MainTab().CurPane()
+	if bp == nil {
+		return nil, nil
+	}
+	index, err := bp.Buf.Tags()
+	if err != nil {
+		return nil, nil
+	}
+
+	var suggestions []string
+	for i := sort.SearchStrings(index.Names, input); i < len(index.Names); i++ {
+		if !strings.HasPrefix(index.Names[i], input) {
+			break
+		}
+		suggestions = append(suggestions, index.Names[i])
+	}
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	luar "layeh.com/gopher-luar"
@@ -61,6 +62,9 @@ var (
 	// BTStdout is a buffer that only writes to stdout
 	// when closed
 	BTStdout = BufType{6, false, true, true}
+	// BTList is a read-only buffer listing entries (files, search results...)
+	// that a special pane lets the user act on
+	BTList = BufType{7, true, true, false}
 )
 
 // SharedBuffer is a struct containing info that is shared among buffers
@@ -93,6 +97,10 @@ type SharedBuffer struct {
 	CurSuggestion int
 
 	Messages []*Message
+	// decorations are the highlighted ranges added by plugins
+	decorations []*Decoration
+	// virtualTexts are the texts displayed in the buffer by plugins
+	virtualTexts []*VirtualText
 
 	updateDiffTimer   *time.Timer
 	diffBase          []byte
@@ -117,13 +125,42 @@ type SharedBuffer struct {
 	// This stores the highlighting rules and filetype detection info
 	SyntaxDef *highlight.Def
 
+	// The lines from hlStart are not highlighted yet, or hlStart is -1 when
+	// the highlighting is up to date. The highlighting is done when the
+	// state of a line after hlEnd does not change. hlLines is the number of
+	// lines when hlEnd was set, and hlRunning is whether the background
+	// highlighter is running. Except for hlStart, which is read when
+	// displaying the buffer, they are protected by the lock of the
+	// LineArray.
+	hlStart   atomic.Int64
+	hlEnd     int
+	hlLines   int
+	hlRunning bool
+
 	ModifiedThisFrame bool
+	// changes is incremented at each modification of the text, so that the
+	// data computed from the whole text can be cached
+	changes int
 
 	// Hash of the original buffer -- empty if fastdirty is on
 	origHash [md5.Size]byte
+
+	// ChangeCallback is called before each change of the text, with the
+	// removed range and the inserted text. Insertions have an empty range
+	// and removals have no text. The LSP client uses it to send the changes
+	// to the language server.
+	ChangeCallback func(start, end Loc, text []byte)
+	// SaveCallback is called after the buffer is saved
+	SaveCallback func()
+	// ReleaseCallback is called when the last buffer using this shared
+	// buffer is closed
+	ReleaseCallback func()
 }
 
 func (b *SharedBuffer) insert(pos Loc, value []byte) {
+	if b.ChangeCallback != nil {
+		b.ChangeCallback(pos, pos, value)
+	}
 	b.HasSuggestions = false
 	b.LineArray.insert(pos, value)
 	b.setModified()
@@ -133,6 +170,9 @@ func (b *SharedBuffer) insert(pos Loc, value []byte) {
 }
 
 func (b *SharedBuffer) remove(start, end Loc) []byte {
+	if b.ChangeCallback != nil {
+		b.ChangeCallback(start, end, nil)
+	}
 	b.HasSuggestions = false
 	defer b.setModified()
 	defer b.MarkModified(start.Y, end.Y)
@@ -184,19 +224,20 @@ func (b *SharedBuffer) calcHash(out *[md5.Size]byte) {
 // and performs rehighlighting if syntax highlighting is enabled
 func (b *SharedBuffer) MarkModified(start, end int) {
 	b.ModifiedThisFrame = true
+	b.changes++
 
 	start = util.Clamp(start, 0, len(b.lines)-1)
 	end = util.Clamp(end, 0, len(b.lines)-1)
 
 	if b.Settings["syntax"].(bool) && b.SyntaxDef != nil {
-		l := -1
-		for i := start; i <= end; i++ {
-			l = util.Max(b.Highlighter.ReHighlightStates(b, i), l)
-		}
-		b.Highlighter.HighlightMatches(b, start, l)
+		b.invalidateHighlighting(start, end)
 	}
 
-	for i := start; i <= end; i++ {
+	n := 0
+	if b.LineArray.multilineSearch {
+		n = b.searchLines() - 1
+	}
+	for i := util.Max(start-n, 0); i <= util.Min(end+n, len(b.lines)-1); i++ {
 		b.LineArray.invalidateSearchMatches(i)
 	}
 }
@@ -256,11 +297,33 @@ type Buffer struct {
 	// from buffer to display, but it would require rewriting a lot of code.
 	GetVisualX func(loc Loc) int
 
+	// CloseCallback is called when the buffer is closed. It allows a feature
+	// that opened the buffer to release its resources, for example the grep
+	// pane uses it to stop the search.
+	CloseCallback func()
+
+	// Menu is the autocompletion menu shown at the cursor, or nil
+	Menu *CompletionMenu
+
 	// Last search stores the last successful search
 	LastSearch      string
 	LastSearchRegex bool
+	// LastSearchMatchCase makes the last search case-sensitive if
+	// LastSearchCaseToggled is set, that is if its case sensitivity was
+	// toggled in the find prompt. Otherwise the last search follows the
+	// ignorecase option.
+	LastSearchMatchCase   bool
+	LastSearchCaseToggled bool
+	// LastSearchWholeWord restricts the last search to whole words
+	LastSearchWholeWord bool
 	// HighlightSearch enables highlighting all instances of the last successful search
 	HighlightSearch bool
+	// lastMatches are the first matches of the last search in the whole
+	// buffer, used to count them
+	lastMatches searchMatches
+	// lastWindow are the matches of the last search around the lines
+	// displayed last, when it can match several lines
+	lastWindow searchWindow
 
 	// OverwriteMode indicates that we are in overwrite mode (toggled by
 	// Insert key by default) i.e. that typing a character shall replace the
@@ -374,8 +437,11 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 	}
 
 	hasBackup := false
+	// settings from the .editorconfig files and the modelines of the file
+	var fileSettings map[string]any
 	if !found {
 		b.SharedBuffer = new(SharedBuffer)
+		b.hlStart.Store(-1)
 		b.Type = btype
 
 		b.AbsPath = absPath
@@ -391,6 +457,13 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 		}
 		config.UpdatePathGlobLocals(b.Settings, absPath)
 
+		if b.Settings["editorconfig"].(bool) && len(absPath) > 0 {
+			fileSettings = config.EditorConfigSettings(absPath)
+		}
+		for k, v := range fileSettings {
+			b.Settings[k] = v
+		}
+
 		b.encoding, err = htmlindex.Get(b.Settings["encoding"].(string))
 		if err != nil {
 			b.encoding = unicode.UTF8
@@ -426,6 +499,16 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 		}
 		b.EventHandler = NewEventHandler(b.SharedBuffer, b.cursors)
 
+		if fileSettings == nil {
+			fileSettings = make(map[string]any)
+		}
+		for k, v := range b.modelineSettings() {
+			fileSettings[k] = v
+		}
+		if ft, ok := fileSettings["filetype"]; ok {
+			b.Settings["filetype"] = ft
+		}
+
 		// The last time this file was modified
 		b.UpdateModTime()
 	}
@@ -442,8 +525,25 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 	}
 
 	b.UpdateRules()
-	// we know the filetype now, so update per-filetype settings
-	config.UpdateFileTypeLocals(b.Settings, b.Settings["filetype"].(string))
+	if !found {
+		// we know the filetype now, so update per-filetype settings. The
+		// settings of a file which is already open are shared and may have
+		// been overridden, so they are kept.
+		config.UpdateFileTypeLocals(b.Settings, b.Settings["filetype"].(string))
+	}
+
+	// EditorConfig properties and modelines take priority over the settings
+	// and are kept when the settings are reloaded
+	for k, v := range fileSettings {
+		b.Settings[k] = v
+		b.LocalSettings[k] = true
+	}
+	switch fileSettings["fileformat"] {
+	case "unix":
+		b.Endings = FFUnix
+	case "dos":
+		b.Endings = FFDos
+	}
 
 	if _, err := os.Stat(filepath.Join(config.ConfigDir, "buffers")); errors.Is(err, fs.ErrNotExist) {
 		os.Mkdir(filepath.Join(config.ConfigDir, "buffers"), os.ModePerm)
@@ -474,6 +574,8 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 			}
 			b.LastSearch = cmd.SearchRegex
 			b.LastSearchRegex = true
+			b.LastSearchCaseToggled = false
+			b.LastSearchWholeWord = false
 			b.HighlightSearch = b.Settings["hlsearch"].(bool)
 		}
 	}
@@ -516,6 +618,9 @@ func (b *Buffer) Close() {
 			copy(OpenBuffers[i:], OpenBuffers[i+1:])
 			OpenBuffers[len(OpenBuffers)-1] = nil
 			OpenBuffers = OpenBuffers[:len(OpenBuffers)-1]
+			if b.ReleaseCallback != nil && !b.Shared() {
+				b.ReleaseCallback()
+			}
 			return
 		}
 	}
@@ -532,6 +637,10 @@ func (b *Buffer) Fini() {
 	if b.Type == BTStdout {
 		fmt.Fprint(util.Stdout, string(b.Bytes()))
 	}
+
+	if b.CloseCallback != nil {
+		b.CloseCallback()
+	}
 }
 
 // GetName returns the name that should be displayed in the statusline
@@ -773,7 +882,13 @@ func findRuntimeSyntaxDef(name string, header *highlight.Header) *highlight.Def
 	return nil
 }
 
-func resolveIncludes(syndef *highlight.Def) {
+// resolveIncludes resolves the syntax files included by syndef. The errors
+// of the syntax files are passed to report, or ignored if it is nil.
+func resolveIncludes(syndef *highlight.Def, report func(msg ...any)) {
+	if report == nil {
+		report = func(...any) {}
+	}
+
 	includes := highlight.GetIncludes(syndef)
 	if len(includes) == 0 {
 		return
@@ -783,13 +898,13 @@ func resolveIncludes(syndef *highlight.Def) {
 	for _, f := range config.ListRuntimeFiles(config.RTSyntax) {
 		data, err := f.Data()
 		if err != nil {
-			screen.TermMessage("Error loading syntax file " + f.Name() + ": " + err.Error())
+			report("Error loading syntax file " + f.Name() + ": " + err.Error())
 			continue
 		}
 
 		header, err := highlight.MakeHeaderYaml(data)
 		if err != nil {
-			screen.TermMessage("Error parsing syntax file " + f.Name() + ": " + err.Error())
+			report("Error parsing syntax file " + f.Name() + ": " + err.Error())
 			continue
 		}
 
@@ -797,7 +912,7 @@ func resolveIncludes(syndef *highlight.Def) {
 			if header.FileType == i {
 				file, err := highlight.ParseFile(data)
 				if err != nil {
-					screen.TermMessage("Error parsing syntax file " + f.Name() + ": " + err.Error())
+					report("Error parsing syntax file " + f.Name() + ": " + err.Error())
 					continue
 				}
 				files = append(files, file)
@@ -812,6 +927,92 @@ func resolveIncludes(syndef *highlight.Def) {
 	highlight.ResolveIncludes(syndef, files)
 }
 
+func init() {
+	highlight.FindInjectedDef = findInjectedDef
+}
+
+// injectedSyntax is the syntax file of a language included in a region
+type injectedSyntax struct {
+	header *highlight.Header
+	file   *highlight.File
+}
+
+var (
+	// injectedSyntaxes caches the syntax files of the languages included in
+	// regions with a capture, by language name
+	injectedSyntaxes     = make(map[string]*injectedSyntax)
+	injectedSyntaxesLock sync.Mutex
+)
+
+// injectedLanguages maps the names of the languages of Markdown code blocks
+// to the filetypes of micro when they differ, in addition to the names of
+// the filetypes of Vim and Emacs used in modelines
+var injectedLanguages = map[string]string{
+	"c#":      "csharp",
+	"console": "shell",
+	"docker":  "dockerfile",
+	"golang":  "go",
+	"jsx":     "javascript",
+	"tsx":     "typescript",
+}
+
+// injectedFiletype returns the filetype of micro for the name of a language
+// included in a region
+func injectedFiletype(name string) string {
+	if ft, ok := injectedLanguages[strings.ToLower(name)]; ok {
+		return ft
+	}
+	return modelineFiletype(name)
+}
+
+// findInjectedDef returns a new syntax definition for the language with the
+// given name, such as the language of a fenced code block in Markdown. The
+// name is either a filetype, an alias of a filetype, or a file extension.
+// It is called by the highlighter, possibly in the background, so it returns
+// nil instead of reporting an error for a syntax file which cannot be parsed.
+func findInjectedDef(name string) *highlight.Def {
+	injectedSyntaxesLock.Lock()
+	syntax, ok := injectedSyntaxes[name]
+	if !ok {
+		ft := injectedFiletype(name)
+		var byExt *injectedSyntax
+		for _, f := range config.ListRuntimeFiles(config.RTSyntax) {
+			data, err := f.Data()
+			if err != nil {
+				continue
+			}
+			header, err := highlight.MakeHeaderYaml(data)
+			if err != nil || (header.FileType != ft && (byExt != nil || !header.MatchFileName("file."+name))) {
+				continue
+			}
+			file, err := highlight.ParseFile(data)
+			if err != nil {
+				continue
+			}
+			if header.FileType == ft {
+				syntax = &injectedSyntax{header, file}
+				break
+			}
+			byExt = &injectedSyntax{header, file}
+		}
+		if syntax == nil {
+			syntax = byExt
+		}
+		injectedSyntaxes[name] = syntax
+	}
+	injectedSyntaxesLock.Unlock()
+
+	if syntax == nil {
+		return nil
+	}
+	def, err := highlight.ParseDef(syntax.file, syntax.header)
+	if err != nil {
+		return nil
+	}
+	resolveIncludes(def, nil)
+	return def
+}
+
 // UpdateRules updates the syntax rules and filetype for this buffer
 // This is called when the colorscheme changes
 func (b *Buffer) UpdateRules() {
@@ -995,23 +1196,27 @@ func (b *Buffer) UpdateRules() {
 	}
 
 	if b.SyntaxDef != nil {
-		resolveIncludes(b.SyntaxDef)
+		resolveIncludes(b.SyntaxDef, screen.TermMessage)
 	}
 
 	if b.SyntaxDef != nil {
+		b.Lock()
 		b.Highlighter = highlight.NewHighlighter(b.SyntaxDef)
+		b.Unlock()
 		if b.Settings["syntax"].(bool) {
-			go func() {
-				b.Highlighter.HighlightStates(b)
-				b.Highlighter.HighlightMatches(b, 0, b.End().Y)
-				screen.Redraw()
-			}()
+			b.ClearMatches()
+			b.invalidateHighlighting(0, b.LinesNum()-1)
 		}
 	}
 }
 
 // ClearMatches clears all of the syntax highlighting for the buffer
 func (b *Buffer) ClearMatches() {
+	b.Lock()
+	defer b.Unlock()
+
+	// stop the background highlighter
+	b.hlStart.Store(-1)
 	for i := range b.lines {
 		b.SetMatch(i, nil)
 		b.SetState(i, nil)
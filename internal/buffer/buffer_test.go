@@ -2,6 +2,8 @@ package buffer
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -96,6 +98,111 @@ func check(t *testing.T, before []string, operations []operation, after []string
 	b.Close()
 }
 
+func TestChangeCallback(t *testing.T) {
+	b := NewBufferFromString("foo\nbär 📚\nbaz", "", BTDefault)
+
+	// replay the changes on a copy of the text
+	text := [][]rune{[]rune("foo"), []rune("bär 📚"), []rune("baz")}
+	b.ChangeCallback = func(start, end Loc, value []byte) {
+		before := append([]rune{}, text[start.Y][:start.X]...)
+		after := append([]rune{}, text[end.Y][end.X:]...)
+		lines := strings.Split(string(value), "\n")
+		inserted := make([][]rune, len(lines))
+		for i, l := range lines {
+			inserted[i] = []rune(l)
+		}
+		inserted[0] = append(before, inserted[0]...)
+		inserted[len(inserted)-1] = append(inserted[len(inserted)-1], after...)
+		text = append(text[:start.Y], append(inserted, text[end.Y+1:]...)...)
+	}
+	released := false
+	b.ReleaseCallback = func() {
+		released = true
+	}
+
+	b.Insert(Loc{5, 1}, "x\ny")
+	b.Remove(Loc{1, 0}, Loc{2, 1})
+	b.Replace(Loc{0, 1}, Loc{1, 2}, "qu")
+	b.Undo()
+	b.Redo()
+	b.Insert(b.End(), "\n")
+
+	lines := make([]string, len(text))
+	for i, l := range text {
+		lines[i] = string(l)
+	}
+	assert.Equal(t, string(b.Bytes()), strings.Join(lines, "\n"))
+
+	b.Close()
+	assert.True(t, released)
+}
+
+func TestEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n"+
+		"[*.txt]\nindent_style = space\nindent_size = 2\nend_of_line = crlf\n"), 0644)
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("a\nb\n"), 0644)
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+	assert.Equal(t, true, b.Settings["tabstospaces"])
+	assert.Equal(t, "dos", b.Settings["fileformat"])
+	assert.Equal(t, FileFormat(FFDos), b.Endings)
+
+	// the properties are kept when the settings are reloaded, but setlocal
+	// overrides them
+	b.ReloadSettings(true)
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+	b.SetOptionNative("tabsize", float64(8))
+	assert.Equal(t, float64(8), b.Settings["tabsize"])
+}
+
+// waitBackups waits until the backup goroutine has handled the requests
+// which have been sent, since it reads the configuration directory
+func waitBackups() {
+	// the request sent when closing the buffer is received after the
+	// previous ones have been handled
+	NewBufferFromString("", "", BTDefault).Close()
+}
+
+func TestReopenKeepsSettings(t *testing.T) {
+	dir := t.TempDir()
+	configDir := config.ConfigDir
+	waitBackups()
+	config.ConfigDir = dir
+	defer func() {
+		waitBackups()
+		config.ConfigDir = configDir
+		config.ReadSettings()
+	}()
+	os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{"ft:go": {"tabsize": 8}}`), 0644)
+	assert.NoError(t, config.ReadSettings())
+
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n[*.go]\nindent_size = 2\n"), 0644)
+	path := filepath.Join(dir, "a.go")
+	os.WriteFile(path, []byte("package a\n"), 0644)
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+	b.SetOptionNative("filetype", "go")
+	b.SetOptionNative("tabstospaces", true)
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+
+	// opening the same file again does not apply the filetype settings to
+	// the shared settings
+	b2, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b2.Close()
+	assert.Equal(t, float64(2), b2.Settings["tabsize"])
+	assert.Equal(t, true, b2.Settings["tabstospaces"])
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+}
+
 const maxLineLength = 200
 
 var alphabet = []rune(" abcdeäم📚")
@@ -0,0 +1,200 @@
+package buffer
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+)
+
+// CommentAt returns the comment strings of the syntax of the buffer at
+// loc, which are the ones of the embedded language if loc is in a region
+// including another syntax. It returns nil if the syntax has none.
+func (b *Buffer) CommentAt(loc Loc) *highlight.Comment {
+	if b.SyntaxDef == nil {
+		return nil
+	}
+	var state highlight.State
+	if b.Highlighter != nil && b.Settings["syntax"].(bool) {
+		state = b.Highlighter.StateAt(b, loc.Y, loc.X)
+	}
+	return b.SyntaxDef.CommentIn(state)
+}
+
+// commentDelimiters returns the strings inserted before and after the text
+// of a line to comment it at loc. The `commentstring` option takes priority
+// over the comment strings of the syntax, and block comments are used when
+// there is no line comment. Lines are commented with `#` if the syntax has
+// no comment strings.
+func (b *Buffer) commentDelimiters(loc Loc) (string, string) {
+	if format, ok := b.Settings["commentstring"].(string); ok {
+		if i := strings.Index(format, "%s"); i >= 0 {
+			return format[:i], format[i+2:]
+		}
+	}
+
+	c := b.CommentAt(loc)
+	switch {
+	case c == nil:
+		return "# ", ""
+	case c.Line != "":
+		return c.Line + " ", ""
+	case c.BlockStart != "":
+		return c.BlockStart + " ", " " + c.BlockEnd
+	}
+	return "# ", ""
+}
+
+// isCommented returns whether line is commented with prefix and suffix. A
+// line starting and ending with a block comment is not commented if the
+// comment ends before the end of the line, like `/* a */ b /* c */`.
+func isCommented(line []byte, prefix, suffix string) bool {
+	line = bytes.TrimSpace(line)
+	p, s := []byte(strings.TrimSpace(prefix)), []byte(strings.TrimSpace(suffix))
+	if len(line) < len(p)+len(s) || !bytes.HasPrefix(line, p) || !bytes.HasSuffix(line, s) {
+		return false
+	}
+	return len(s) == 0 || !bytes.Contains(line[len(p):len(line)-len(s)], s)
+}
+
+// uncommentDeltas returns the changes removing prefix and suffix from the
+// commented line y, as well as the spaces between them and the text
+func (b *Buffer) uncommentDeltas(y int, prefix, suffix string) []Delta {
+	line := b.LineBytes(y)
+	p, s := strings.TrimSpace(prefix), strings.TrimSpace(suffix)
+
+	// byte offsets of the comment strings in the line
+	start := len(util.GetLeadingWhitespace(line))
+	after := start + len(p)
+	if strings.HasSuffix(prefix, " ") && after < len(line) && line[after] == ' ' {
+		after++
+	}
+	end := len(bytes.TrimRight(line, " \t"))
+	before := end - len(s)
+	if strings.HasPrefix(suffix, " ") && before > after && line[before-1] == ' ' {
+		before--
+	}
+
+	charPos := func(i int) Loc {
+		return Loc{util.CharacterCount(line[:i]), y}
+	}
+	var deltas []Delta
+	if s != "" && before >= after {
+		deltas = append(deltas, Delta{nil, charPos(before), charPos(end)})
+	}
+	return append(deltas, Delta{nil, charPos(start), charPos(after)})
+}
+
+// commentDeltas returns the changes commenting or uncommenting the lines
+// from start to end (inclusive) as described in ToggleComment, from the
+// last one to the first one so that they can be made one after the other.
+// It returns false if there are no lines to toggle.
+func (b *Buffer) commentDeltas(start, end int) ([]Delta, bool) {
+	var lines []int
+	for y := start; y <= end && y < b.LinesNum(); y++ {
+		if !util.IsSpacesOrTabs(b.LineBytes(y)) {
+			lines = append(lines, y)
+		}
+	}
+	if len(lines) == 0 {
+		for y := start; y <= end && y < b.LinesNum(); y++ {
+			lines = append(lines, y)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, false
+	}
+
+	indent := -1
+	for _, y := range lines {
+		ws := util.CharacterCount(util.GetLeadingWhitespace(b.LineBytes(y)))
+		if indent < 0 || ws < indent {
+			indent = ws
+		}
+	}
+	prefix, suffix := b.commentDelimiters(Loc{indent, lines[0]})
+
+	commented := true
+	for _, y := range lines {
+		if !isCommented(b.LineBytes(y), prefix, suffix) {
+			commented = false
+			break
+		}
+	}
+
+	var deltas []Delta
+	for i := len(lines) - 1; i >= 0; i-- {
+		y := lines[i]
+		if commented {
+			deltas = append(deltas, b.uncommentDeltas(y, prefix, suffix)...)
+			continue
+		}
+		if suffix != "" {
+			eol := Loc{util.CharacterCount(b.LineBytes(y)), y}
+			deltas = append(deltas, Delta{[]byte(suffix), eol, eol})
+		}
+		deltas = append(deltas, Delta{[]byte(prefix), Loc{indent, y}, Loc{indent, y}})
+	}
+	return deltas, true
+}
+
+// ToggleComment comments the lines from start to end (inclusive) with the
+// comment strings at the start of the first line, or uncomments them if
+// they are all commented. Blank lines are left as they are, unless all
+// lines are blank. The comments are inserted at the smallest indentation of
+// the lines. It returns false if there are no lines to toggle.
+func (b *Buffer) ToggleComment(start, end int) bool {
+	return b.ToggleComments([][2]int{{start, end}})
+}
+
+// ToggleComments toggles the comments of several ranges of lines, which
+// must be sorted and must not overlap, as ToggleComment does for each of
+// them. The changes are made in one event, so that they are undone at
+// once, and the buffer is left unchanged if a range has no lines to
+// toggle. It returns false in this case.
+func (b *Buffer) ToggleComments(ranges [][2]int) bool {
+	var deltas []Delta
+	for i := len(ranges) - 1; i >= 0; i-- {
+		d, ok := b.commentDeltas(ranges[i][0], ranges[i][1])
+		if !ok {
+			return false
+		}
+		deltas = append(deltas, d...)
+	}
+
+	// the cursors are not moved by an event with several changes, so their
+	// new locations are computed before the deltas are modified by it
+	move := func(loc Loc) Loc {
+		for _, d := range deltas {
+			if d.Start.Y != loc.Y {
+				continue
+			}
+			if loc.GreaterEqual(d.End) {
+				loc.X += util.CharacterCount(d.Text) - (d.End.X - d.Start.X)
+			} else if loc.GreaterThan(d.Start) {
+				loc.X = d.Start.X
+			}
+		}
+		return loc
+	}
+	cursors := b.GetCursors()
+	locs := make([][5]Loc, len(cursors))
+	for i, c := range cursors {
+		locs[i] = [5]Loc{
+			move(c.Loc),
+			move(c.CurSelection[0]), move(c.CurSelection[1]),
+			move(c.OrigSelection[0]), move(c.OrigSelection[1]),
+		}
+	}
+
+	b.MultipleReplace(deltas)
+
+	for i, c := range cursors {
+		c.Loc = locs[i][0]
+		c.CurSelection = [2]Loc{locs[i][1], locs[i][2]}
+		c.OrigSelection = [2]Loc{locs[i][3], locs[i][4]}
+		c.StoreVisualX()
+	}
+	return true
+}
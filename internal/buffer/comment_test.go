@@ -0,0 +1,98 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToggleComment(t *testing.T) {
+	b := NewBufferFromString("func f() {\n\tx := 1\n\n\t\ty := 2\n}", "", BTDefault)
+	defer b.Close()
+
+	// lines are commented with # without syntax
+	assert.True(t, b.ToggleComment(0, 0))
+	assert.Equal(t, "# func f() {", b.Line(0))
+	b.Undo()
+
+	setSyntax(t, b, "go")
+	assert.True(t, b.ToggleComment(1, 3))
+	assert.Equal(t, "func f() {\n\t// x := 1\n\n\t// \ty := 2\n}", string(b.Bytes()))
+	assert.True(t, b.ToggleComment(1, 3))
+	assert.Equal(t, "func f() {\n\tx := 1\n\n\t\ty := 2\n}", string(b.Bytes()))
+
+	// the lines are uncommented only if they are all commented
+	b.ToggleComment(1, 1)
+	b.ToggleComment(1, 3)
+	assert.Equal(t, "func f() {\n\t// // x := 1\n\n\t// \ty := 2\n}", string(b.Bytes()))
+}
+
+func TestToggleComments(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc\nd", "", BTDefault)
+	defer b.Close()
+	b.Settings["commentstring"] = "# %s"
+
+	assert.True(t, b.ToggleComments([][2]int{{0, 0}, {2, 3}}))
+	assert.Equal(t, "# a\nb\n# c\n# d", string(b.Bytes()))
+	// a toggle is a single event, which is undone at once
+	assert.Equal(t, 1, b.UndoStack.Len())
+
+	// the cursors follow the text
+	b.GetActiveCursor().GotoLoc(Loc{2, 3})
+	assert.True(t, b.ToggleComments([][2]int{{0, 0}, {2, 3}}))
+	assert.Equal(t, "a\nb\nc\nd", string(b.Bytes()))
+	assert.Equal(t, Loc{0, 3}, b.GetActiveCursor().Loc)
+
+	// nothing is changed if a range cannot be toggled
+	assert.False(t, b.ToggleComments([][2]int{{0, 0}, {10, 10}}))
+	assert.Equal(t, "a\nb\nc\nd", string(b.Bytes()))
+}
+
+func TestToggleBlockComment(t *testing.T) {
+	b := NewBufferFromString("a {\n  color: red;\n}", "", BTDefault)
+	defer b.Close()
+	setSyntax(t, b, "css")
+
+	assert.True(t, b.ToggleComment(1, 1))
+	assert.Equal(t, "a {\n  /* color: red; */\n}", string(b.Bytes()))
+	assert.True(t, b.ToggleComment(1, 1))
+	assert.Equal(t, "a {\n  color: red;\n}", string(b.Bytes()))
+
+	// a line starting and ending with different comments is not commented
+	assert.False(t, isCommented([]byte("/* a */ b /* c */"), "/* ", " */"))
+	assert.True(t, isCommented([]byte("  /* a b c */"), "/* ", " */"))
+	assert.False(t, isCommented([]byte("/*/"), "/* ", " */"))
+	b.Replace(Loc{2, 1}, Loc{13, 1}, "/* a */ b /* c */")
+	assert.True(t, b.ToggleComment(1, 1))
+	assert.Equal(t, "a {\n  /* /* a */ b /* c */ */\n}", string(b.Bytes()))
+	b.Undo()
+	b.Replace(Loc{2, 1}, Loc{19, 1}, "color: red;")
+
+	// the commentstring option takes priority
+	b.Settings["commentstring"] = "## %s"
+	b.ToggleComment(0, 0)
+	assert.Equal(t, "## a {\n  color: red;\n}", string(b.Bytes()))
+}
+
+func TestToggleEmbeddedComment(t *testing.T) {
+	b := NewBufferFromString("<div>\n<script>\nlet x = 1;\n</script>\n</div>", "", BTDefault)
+	defer b.Close()
+	setSyntax(t, b, "html")
+	var files []*highlight.File
+	for _, ft := range []string{"javascript", "css"} {
+		data, err := config.FindRuntimeFile(config.RTSyntax, ft).Data()
+		assert.NoError(t, err)
+		file, err := highlight.ParseFile(data)
+		assert.NoError(t, err)
+		files = append(files, file)
+	}
+	highlight.ResolveIncludes(b.SyntaxDef, files)
+	b.Highlighter = highlight.NewHighlighter(b.SyntaxDef)
+	b.Highlighter.HighlightStates(b)
+
+	assert.True(t, b.ToggleComment(2, 2))
+	assert.True(t, b.ToggleComment(4, 4))
+	assert.Equal(t, "<div>\n<script>\n// let x = 1;\n</script>\n<!-- </div> -->", string(b.Bytes()))
+}
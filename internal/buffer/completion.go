@@ -0,0 +1,453 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A Suggestion is an item of the autocompletion menu
+type Suggestion struct {
+	// Text replaces the word before the cursor when the suggestion is chosen
+	Text string
+	// Detail is displayed next to the text, for example the source of the
+	// suggestion
+	Detail string
+	// Line is the line of the buffer where the suggestion was found, or -1.
+	// Suggestions found close to the cursor are ranked higher.
+	Line int
+	// Snippet is inserted instead of the text if it is not nil
+	Snippet *Snippet
+
+	score int
+}
+
+// A CompletionSource returns the suggestions for the word before the
+// cursor. The suggestions do not have to match the word, since they are
+// filtered by the autocompletion menu.
+type CompletionSource func(b *Buffer, word string) []Suggestion
+
+// completionSources are the built-in sources, enabled with the
+// completesources option
+var completionSources = map[string]CompletionSource{
+	"buffer":     bufferSource,
+	"buffers":    buffersSource,
+	"files":      filesSource,
+	"dictionary": dictionarySource,
+	"snippets":   snippetsSource,
+}
+
+type pluginSource struct {
+	name string
+	src  CompletionSource
+}
+
+// pluginSources are the sources registered by plugins, which are always
+// enabled
+var pluginSources []pluginSource
+
+// RegisterCompletionSource adds a source of suggestions for the
+// autocompletion menu, or replaces the source with the same name
+func RegisterCompletionSource(name string, src CompletionSource) {
+	for i, s := range pluginSources {
+		if s.name == name {
+			pluginSources[i].src = src
+			return
+		}
+	}
+	pluginSources = append(pluginSources, pluginSource{name, src})
+}
+
+// RegisterCompleter adds a source of suggestions for the autocompletion
+// menu defined by a plugin. The function returns the texts of the
+// suggestions and optionally their details.
+func RegisterCompleter(name string, f func(b *Buffer, word string) ([]string, []string)) {
+	RegisterCompletionSource(name, func(b *Buffer, word string) []Suggestion {
+		texts, details := f(b, word)
+		suggestions := make([]Suggestion, len(texts))
+		for i, t := range texts {
+			suggestions[i] = Suggestion{Text: t, Detail: name, Line: -1}
+			if i < len(details) && details[i] != "" {
+				suggestions[i].Detail = details[i]
+			}
+		}
+		return suggestions
+	})
+}
+
+// Scores added to the fuzzy matching score of the suggestions
+const (
+	// bonus of a word found on the line of the cursor, decreasing with the
+	// distance
+	proximityBonus = 16
+	// bonus of the last chosen suggestion, decreasing for the older ones
+	recencyBonus = 32
+)
+
+// completionHistory stores when each word was last chosen in the
+// autocompletion menu
+var completionHistory = make(map[string]int)
+var completionClock int
+
+func proximityScore(line, cursor int) int {
+	if line < 0 {
+		return 0
+	}
+	d := util.Abs(line - cursor)
+	score := proximityBonus
+	for d > 0 && score > 0 {
+		d /= 2
+		score -= 2
+	}
+	return score
+}
+
+func recencyScore(word string) int {
+	if used, ok := completionHistory[word]; ok {
+		return util.Max(recencyBonus-(completionClock-used), 0)
+	}
+	return 0
+}
+
+// A CompletionMenu is the autocompletion menu shown at the cursor. The
+// suggestions are filtered with fuzzy matching as the word before the
+// cursor changes.
+type CompletionMenu struct {
+	// Start is the beginning of the word being completed
+	Start Loc
+	// Word is the word being completed
+	Word string
+	// Items are the suggestions matching the word, best first
+	Items []Suggestion
+	// Selected is the index of the selected item
+	Selected int
+	// Top is the index of the first item displayed
+	Top int
+
+	// the suggestions of the sources for origWord, which are filtered
+	// again while the word only grows
+	all      []Suggestion
+	origWord string
+}
+
+// completionWord returns the word before the cursor and its start. The
+// word is empty after a non-word character, and ok is false after a
+// whitespace or at the beginning of a line.
+func (b *Buffer) completionWord() (string, Loc, bool) {
+	c := b.GetActiveCursor()
+	word, x := b.GetWord()
+	if x < 0 {
+		return "", c.Loc, false
+	}
+	return string(word), Loc{x, c.Y}, true
+}
+
+// suggestions returns the suggestions of all the enabled sources, without
+// duplicates
+func (b *Buffer) suggestions(word string) []Suggestion {
+	var sources []CompletionSource
+	for _, name := range strings.Split(b.Settings["completesources"].(string), ",") {
+		if src, ok := completionSources[strings.TrimSpace(name)]; ok {
+			sources = append(sources, src)
+		}
+	}
+	for _, s := range pluginSources {
+		sources = append(sources, s.src)
+	}
+
+	var all []Suggestion
+	seen := make(map[string]int)
+	for _, src := range sources {
+		for _, s := range src(b, word) {
+			if s.Text == "" {
+				continue
+			}
+			if i, ok := seen[s.Text]; ok {
+				// keep the closest occurrence
+				if s.Line >= 0 && (all[i].Line < 0 ||
+					util.Abs(s.Line-b.GetActiveCursor().Y) < util.Abs(all[i].Line-b.GetActiveCursor().Y)) {
+					all[i].Line = s.Line
+				}
+				// a snippet takes precedence over a word with the same name
+				if s.Snippet != nil && all[i].Snippet == nil {
+					all[i].Snippet, all[i].Detail = s.Snippet, s.Detail
+				}
+				continue
+			}
+			seen[s.Text] = len(all)
+			all = append(all, s)
+		}
+	}
+	return all
+}
+
+// filter keeps the suggestions matching the word, ranked by their fuzzy
+// matching score, proximity and how recently they were chosen
+func (m *CompletionMenu) filter(cursorY int) {
+	m.Items = m.Items[:0]
+	for _, s := range m.all {
+		if s.Text == m.Word && s.Snippet == nil {
+			continue
+		}
+		score, ok := util.FuzzyScore(m.Word, s.Text)
+		if !ok {
+			continue
+		}
+		s.score = score + proximityScore(s.Line, cursorY) + recencyScore(s.Text)
+		m.Items = append(m.Items, s)
+	}
+	sort.SliceStable(m.Items, func(i, j int) bool {
+		a, b := m.Items[i], m.Items[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+	m.Selected = 0
+	m.Top = 0
+}
+
+// OpenCompletionMenu opens the autocompletion menu for the word before the
+// cursor. It returns false if there is no suggestion.
+func (b *Buffer) OpenCompletionMenu() bool {
+	b.Menu = nil
+	word, start, ok := b.completionWord()
+	if !ok {
+		return false
+	}
+	m := &CompletionMenu{
+		Start:    start,
+		Word:     word,
+		origWord: word,
+		all:      b.suggestions(word),
+	}
+	m.filter(start.Y)
+	if len(m.Items) == 0 {
+		return false
+	}
+	b.Menu = m
+	return true
+}
+
+// UpdateCompletionMenu filters the suggestions of the autocompletion menu
+// again after the word before the cursor has changed. The menu is closed
+// if the cursor has left the word or if no suggestion matches anymore.
+func (b *Buffer) UpdateCompletionMenu() {
+	m := b.Menu
+	if m == nil {
+		return
+	}
+	word, start, ok := b.completionWord()
+	if !ok || start != m.Start {
+		b.CloseCompletionMenu()
+		return
+	}
+	m.Word = word
+	if !strings.HasPrefix(word, m.origWord) {
+		m.origWord = word
+		m.all = b.suggestions(word)
+	}
+	m.filter(start.Y)
+	if len(m.Items) == 0 {
+		b.CloseCompletionMenu()
+	}
+}
+
+// CloseCompletionMenu closes the autocompletion menu
+func (b *Buffer) CloseCompletionMenu() {
+	b.Menu = nil
+}
+
+// Move moves the selection of the menu by n items, wrapping around
+func (m *CompletionMenu) Move(n int) {
+	if len(m.Items) == 0 {
+		return
+	}
+	m.Selected = ((m.Selected+n)%len(m.Items) + len(m.Items)) % len(m.Items)
+}
+
+// AcceptCompletion replaces the word before the cursor by the selected
+// suggestion of the autocompletion menu and closes it
+func (b *Buffer) AcceptCompletion() bool {
+	m := b.Menu
+	b.CloseCompletionMenu()
+	if m == nil || m.Selected >= len(m.Items) {
+		return false
+	}
+	s := m.Items[m.Selected]
+	text := s.Text
+	if s.Snippet != nil {
+		b.InsertSnippet(s.Snippet, m.Start, b.GetActiveCursor().Loc)
+	} else {
+		b.Replace(m.Start, b.GetActiveCursor().Loc, text)
+	}
+	completionClock++
+	completionHistory[text] = completionClock
+	return true
+}
+
+// wordSuggestions returns the words of the given lines which are longer
+// than the word being completed. If cursorY is not negative, the line of
+// each suggestion is the line of the occurrence of the word which is the
+// closest to the line cursorY.
+func wordSuggestions(la *LineArray, word string, detail string, cursorY int) []Suggestion {
+	if word == "" {
+		return nil
+	}
+	var suggestions []Suggestion
+	seen := make(map[string]int)
+	for i := 0; i < la.LinesNum(); i++ {
+		for _, w := range bytes.FieldsFunc(la.LineBytes(i), util.IsNonWordChar) {
+			if len(w) <= len(word) {
+				continue
+			}
+			if j, ok := seen[string(w)]; ok {
+				if cursorY >= 0 && util.Abs(i-cursorY) < util.Abs(suggestions[j].Line-cursorY) {
+					suggestions[j].Line = i
+				}
+				continue
+			}
+			seen[string(w)] = len(suggestions)
+			s := Suggestion{Text: string(w), Detail: detail, Line: -1}
+			if cursorY >= 0 {
+				s.Line = i
+			}
+			suggestions = append(suggestions, s)
+		}
+	}
+	return suggestions
+}
+
+// bufferSource suggests the words of the buffer
+func bufferSource(b *Buffer, word string) []Suggestion {
+	return wordSuggestions(b.LineArray, word, "buffer", b.GetActiveCursor().Y)
+}
+
+// buffersSource suggests the words of the other open buffers
+func buffersSource(b *Buffer, word string) []Suggestion {
+	var suggestions []Suggestion
+	seen := map[*SharedBuffer]bool{b.SharedBuffer: true}
+	for _, ob := range OpenBuffers {
+		if seen[ob.SharedBuffer] || ob.Type != BTDefault {
+			continue
+		}
+		seen[ob.SharedBuffer] = true
+		suggestions = append(suggestions, wordSuggestions(ob.LineArray, word, ob.GetName(), -1)...)
+	}
+	return suggestions
+}
+
+// filesSource suggests the files of the directory typed before the cursor,
+// if the text before the cursor is a path containing a slash. Directories
+// made only of slashes are ignored, so that `//` comments are not completed
+// with the files of the root directory.
+func filesSource(b *Buffer, word string) []Suggestion {
+	c := b.GetActiveCursor()
+	before := string(util.SliceStart(b.LineBytes(c.Y), c.X))
+	if i := strings.LastIndexAny(before, " \t\"'`()[]{}<>=,;"); i >= 0 {
+		before = before[i+1:]
+	}
+	if !strings.HasSuffix(before, word) {
+		return nil
+	}
+	dir := strings.TrimSuffix(before, word)
+	if !strings.HasSuffix(dir, "/") && !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		return nil
+	}
+	if strings.Trim(dir, "/"+string(os.PathSeparator)) == "" {
+		return nil
+	}
+
+	// relative paths are relative to the working directory, as in the
+	// command bar
+	path, _ := util.ReplaceHome(dir)
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	suggestions := make([]Suggestion, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		detail := "file"
+		if e.IsDir() {
+			name += "/"
+			detail = "dir"
+		}
+		suggestions = append(suggestions, Suggestion{Text: name, Detail: detail, Line: -1})
+	}
+	return suggestions
+}
+
+type dictionary struct {
+	modTime time.Time
+	words   []string
+}
+
+var dictionaries = make(map[string]*dictionary)
+var dictionariesLock sync.Mutex
+
+// dictionaryWords returns the words of a dictionary file, with one word
+// per line. The file is only read again when it is modified.
+func dictionaryWords(path string) []string {
+	path, _ = util.ReplaceHome(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	dictionariesLock.Lock()
+	defer dictionariesLock.Unlock()
+	if d, ok := dictionaries[path]; ok && d.modTime.Equal(info.ModTime()) {
+		return d.words
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			words = append(words, w)
+		}
+	}
+	dictionaries[path] = &dictionary{info.ModTime(), words}
+	return words
+}
+
+// dictionarySource suggests the words of the files in the dictionary
+// option
+func dictionarySource(b *Buffer, word string) []Suggestion {
+	if word == "" {
+		return nil
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	first = unicode.ToLower(first)
+	var suggestions []Suggestion
+	for _, path := range strings.Split(b.Settings["dictionary"].(string), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		for _, w := range dictionaryWords(path) {
+			// the first letter must match, since dictionaries are large
+			r, _ := utf8.DecodeRuneInString(w)
+			if len(w) > len(word) && unicode.ToLower(r) == first {
+				suggestions = append(suggestions, Suggestion{Text: w, Detail: "dict", Line: -1})
+			}
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,114 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func menuTexts(b *Buffer) []string {
+	var texts []string
+	for _, s := range b.Menu.Items {
+		texts = append(texts, s.Text)
+	}
+	return texts
+}
+
+func TestCompletionMenu(t *testing.T) {
+	b := NewBufferFromString("for_each\nformat\n\n\n\n\n\n\n\n\nfoo\nfmt_result\nf", "", BTDefault)
+	defer b.Close()
+	b.GetActiveCursor().GotoLoc(b.End())
+
+	assert.True(t, b.OpenCompletionMenu())
+	assert.Equal(t, Loc{0, 12}, b.Menu.Start)
+	// the closest words come first
+	assert.Equal(t, []string{"fmt_result", "foo", "format", "for_each"}, menuTexts(b))
+
+	// the suggestions are filtered with fuzzy matching while typing
+	b.Insert(b.End(), "m")
+	b.UpdateCompletionMenu()
+	assert.Equal(t, []string{"fmt_result", "format"}, menuTexts(b))
+	b.Insert(b.End(), "r")
+	b.UpdateCompletionMenu()
+	assert.Equal(t, []string{"fmt_result"}, menuTexts(b))
+
+	b.Remove(Loc{1, 12}, b.End())
+	b.UpdateCompletionMenu()
+	assert.Len(t, b.Menu.Items, 4)
+
+	b.Menu.Move(-1)
+	assert.Equal(t, 3, b.Menu.Selected)
+	assert.True(t, b.AcceptCompletion())
+	assert.Nil(t, b.Menu)
+	assert.Equal(t, "for_each", b.Line(12))
+
+	// the chosen word is ranked first next time
+	b.Insert(b.End(), "\nf")
+	assert.True(t, b.OpenCompletionMenu())
+	assert.Equal(t, "for_each", b.Menu.Items[0].Text)
+
+	// the menu is closed when the cursor leaves the word
+	b.Insert(b.End(), " ")
+	b.UpdateCompletionMenu()
+	assert.Nil(t, b.Menu)
+	assert.False(t, b.OpenCompletionMenu())
+}
+
+func TestCompletionProximity(t *testing.T) {
+	b := NewBufferFromString("alphabet\nalpine\n"+strings.Repeat("x\n", 2000)+"alphabet\nal", "", BTDefault)
+	defer b.Close()
+	b.GetActiveCursor().GotoLoc(b.End())
+
+	// the closest occurrence of a word is used to rank it
+	assert.True(t, b.OpenCompletionMenu())
+	assert.Equal(t, []string{"alphabet", "alpine"}, menuTexts(b))
+}
+
+func TestCompletionSources(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "subdir"), 0755)
+	os.WriteFile(filepath.Join(dir, "file.txt"), nil, 0644)
+	dict := filepath.Join(dir, "dict")
+	os.WriteFile(dict, []byte("apple\napricot\nbanana\n"), 0644)
+
+	other := NewBufferFromString("apply", "", BTDefault)
+	defer other.Close()
+
+	b := NewBufferFromString(dir+"/", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("dictionary", dict)
+	RegisterCompleter("test", func(b *Buffer, word string) ([]string, []string) {
+		return []string{"apex"}, []string{"plugin"}
+	})
+	defer func() { pluginSources = nil }()
+
+	b.GetActiveCursor().GotoLoc(b.End())
+	assert.True(t, b.OpenCompletionMenu())
+	assert.ElementsMatch(t, []string{"dict", "file.txt", "subdir/", "apex"}, menuTexts(b))
+
+	// a directory made only of slashes is not completed
+	b.Insert(b.End(), "\n//fi")
+	assert.Empty(t, filesSource(b, "fi"))
+	b.Insert(b.End(), "\n/fi")
+	assert.Empty(t, filesSource(b, "fi"))
+	b.Insert(b.End(), "\n"+dir+"/fi")
+	assert.Len(t, filesSource(b, "fi"), 3)
+
+	b.Insert(b.End(), "\nap")
+	assert.True(t, b.OpenCompletionMenu())
+	assert.ElementsMatch(t, []string{"apply", "apple", "apricot", "apex"}, menuTexts(b))
+	for _, s := range b.Menu.Items {
+		if s.Text == "apex" {
+			assert.Equal(t, "plugin", s.Detail)
+		} else if s.Text == "apple" {
+			assert.Equal(t, "dict", s.Detail)
+		}
+	}
+
+	b.SetOptionNative("completesources", "dictionary")
+	assert.True(t, b.OpenCompletionMenu())
+	assert.ElementsMatch(t, []string{"apple", "apricot", "apex"}, menuTexts(b))
+}
@@ -0,0 +1,140 @@
+package buffer
+
+import (
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// A Decoration is a range of a buffer displayed with a style of the
+// colorscheme on top of the syntax highlighting. Plugins use decorations to
+// highlight semantic tokens, matched words, spelling errors, etc. They move
+// with the modifications of the text.
+type Decoration struct {
+	// Namespace is the name used to clear the decorations of a plugin
+	// together
+	Namespace string
+	// Start and End locations of the range (End is excluded)
+	Start, End Loc
+	// Group is the colorscheme group of the style, such as "error" or
+	// "identifier.var"
+	Group string
+}
+
+// Contains returns whether loc is in the range of the decoration
+func (d *Decoration) Contains(loc Loc) bool {
+	return loc.GreaterEqual(d.Start) && loc.LessThan(d.End)
+}
+
+// Apply returns style with the style of the decoration on top of it. The
+// colors of the decoration replace the ones of style unless they are the
+// default colors, and its attributes are added to the ones of style.
+func (d *Decoration) Apply(style tcell.Style) tcell.Style {
+	fg, bg, attr := config.GetColor(d.Group).Decompose()
+	defFg, defBg, _ := config.DefStyle.Decompose()
+	if fg != defFg {
+		style = style.Foreground(fg)
+	}
+	if bg != defBg {
+		style = style.Background(bg)
+	}
+	_, _, styleAttr := style.Decompose()
+	attr |= styleAttr
+	return style.Bold(attr&tcell.AttrBold != 0).
+		Blink(attr&tcell.AttrBlink != 0).
+		Dim(attr&tcell.AttrDim != 0).
+		Italic(attr&tcell.AttrItalic != 0).
+		Reverse(attr&tcell.AttrReverse != 0).
+		Underline(attr&tcell.AttrUnderline != 0).
+		StrikeThrough(attr&tcell.AttrStrikeThrough != 0)
+}
+
+// setRange sets the range of d from start to end in either order, clamped
+// to the buffer
+func (d *Decoration) setRange(start, end Loc, la *LineArray) {
+	if end.LessThan(start) {
+		start, end = end, start
+	}
+	d.Start, d.End = clamp(start, la), clamp(end, la)
+}
+
+// AddDecoration highlights the range from start to end with the style of
+// the given colorscheme group, and returns the decoration so that it can be
+// updated or removed
+func (b *SharedBuffer) AddDecoration(namespace string, start, end Loc, group string) *Decoration {
+	d := &Decoration{Namespace: namespace, Group: group}
+	d.setRange(start, end, b.LineArray)
+	b.decorations = append(b.decorations, d)
+	return d
+}
+
+// UpdateDecoration changes the range and the group of a decoration
+func (b *SharedBuffer) UpdateDecoration(d *Decoration, start, end Loc, group string) {
+	d.setRange(start, end, b.LineArray)
+	d.Group = group
+}
+
+// RemoveDecoration removes a decoration from the buffer
+func (b *SharedBuffer) RemoveDecoration(d *Decoration) {
+	for i, dec := range b.decorations {
+		if dec == d {
+			b.decorations = append(b.decorations[:i], b.decorations[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearDecorations removes all the decorations in the given namespace
+func (b *SharedBuffer) ClearDecorations(namespace string) {
+	decorations := b.decorations[:0]
+	for _, d := range b.decorations {
+		if d.Namespace != namespace {
+			decorations = append(decorations, d)
+		}
+	}
+	for i := len(decorations); i < len(b.decorations); i++ {
+		b.decorations[i] = nil
+	}
+	b.decorations = decorations
+}
+
+// Decorations returns the decorations in the given namespace, in the order
+// they were added
+func (b *SharedBuffer) Decorations(namespace string) []*Decoration {
+	var decorations []*Decoration
+	for _, d := range b.decorations {
+		if d.Namespace == namespace {
+			decorations = append(decorations, d)
+		}
+	}
+	return decorations
+}
+
+// DecorationsInLine returns the decorations overlapping line y. The last
+// ones are displayed on top of the others.
+func (b *SharedBuffer) DecorationsInLine(y int) []*Decoration {
+	var decorations []*Decoration
+	for _, d := range b.decorations {
+		if d.Start.Y <= y && d.End.Y >= y {
+			decorations = append(decorations, d)
+		}
+	}
+	return decorations
+}
+
+// moveDecorations moves the decorations with the given function after a
+// modification of the text. The text inserted at the boundaries of a
+// decoration is not part of it, and the decorations whose text is removed
+// are removed too.
+func (b *SharedBuffer) moveDecorations(move func(loc Loc, stay bool) Loc) {
+	decorations := b.decorations[:0]
+	for _, d := range b.decorations {
+		d.Start, d.End = move(d.Start, false), move(d.End, true)
+		if d.Start.LessThan(d.End) {
+			decorations = append(decorations, d)
+		}
+	}
+	for i := len(decorations); i < len(b.decorations); i++ {
+		b.decorations[i] = nil
+	}
+	b.decorations = decorations
+}
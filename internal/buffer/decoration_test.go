@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecorations(t *testing.T) {
+	b := NewBufferFromString("foo bar baz\nqux", "", BTDefault)
+	defer b.Close()
+
+	d := b.AddDecoration("test", Loc{7, 0}, Loc{4, 0}, "error")
+	other := b.AddDecoration("other", Loc{0, 1}, Loc{10, 1}, "todo")
+	assert.Equal(t, Loc{4, 0}, d.Start)
+	assert.Equal(t, Loc{3, 1}, other.End)
+	assert.Equal(t, []*Decoration{d}, b.DecorationsInLine(0))
+	assert.Equal(t, []*Decoration{other}, b.Decorations("other"))
+	assert.True(t, d.Contains(Loc{6, 0}))
+	assert.False(t, d.Contains(Loc{7, 0}))
+
+	// the text inserted at the boundaries is not decorated
+	b.Insert(Loc{4, 0}, "big ")
+	assert.Equal(t, Loc{8, 0}, d.Start)
+	assert.Equal(t, Loc{11, 0}, d.End)
+	b.Insert(Loc{11, 0}, "!")
+	assert.Equal(t, Loc{11, 0}, d.End)
+	b.Insert(Loc{9, 0}, "\n")
+	assert.Equal(t, Loc{8, 0}, d.Start)
+	assert.Equal(t, Loc{2, 1}, d.End)
+	assert.Equal(t, Loc{0, 2}, other.Start)
+
+	b.UpdateDecoration(d, Loc{0, 0}, Loc{3, 0}, "todo")
+	assert.Equal(t, "todo", d.Group)
+	assert.Equal(t, Loc{3, 0}, d.End)
+
+	// removing the text of a decoration removes it
+	b.Remove(Loc{0, 0}, Loc{4, 0})
+	assert.Empty(t, b.Decorations("test"))
+
+	b.AddDecoration("test", Loc{0, 0}, Loc{1, 0}, "error")
+	b.ClearDecorations("test")
+	assert.Empty(t, b.Decorations("test"))
+	assert.Equal(t, []*Decoration{other}, b.Decorations("other"))
+	b.RemoveDecoration(other)
+	assert.Empty(t, b.DecorationsInLine(2))
+}
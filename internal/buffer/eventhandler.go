@@ -75,38 +75,55 @@ func (eh *EventHandler) DoTextEvent(t *TextEvent, useUndo bool) {
 	}
 	end := t.Deltas[0].End
 
-	for _, c := range eh.cursors {
-		move := func(loc Loc) Loc {
-			if t.EventType == TextEventInsert {
-				if start.Y != loc.Y && loc.GreaterThan(start) {
-					loc.Y += end.Y - start.Y
-				} else if loc.Y == start.Y && loc.GreaterEqual(start) {
-					loc.Y += end.Y - start.Y
-					if lastnl >= 0 {
-						loc.X += textX - start.X
-					} else {
-						loc.X += textX
-					}
-				}
-				return loc
-			} else {
-				if loc.Y != end.Y && loc.GreaterThan(end) {
-					loc.Y -= end.Y - start.Y
-				} else if loc.Y == end.Y && loc.GreaterEqual(end) {
-					loc = loc.MoveLA(-DiffLA(start, end, eh.buf.LineArray), eh.buf.LineArray)
+	// move returns the new position of loc after the event. If stay is
+	// true, a location at the start of an insertion does not move.
+	move := func(loc Loc, stay bool) Loc {
+		if t.EventType == TextEventInsert {
+			if start.Y != loc.Y && loc.GreaterThan(start) {
+				loc.Y += end.Y - start.Y
+			} else if loc.Y == start.Y && (loc.GreaterThan(start) || loc == start && !stay) {
+				loc.Y += end.Y - start.Y
+				if lastnl >= 0 {
+					loc.X += textX - start.X
+				} else {
+					loc.X += textX
 				}
-				return loc
 			}
+			return loc
+		} else {
+			if loc.Y != end.Y && loc.GreaterThan(end) {
+				loc.Y -= end.Y - start.Y
+			} else if loc.Y == end.Y && loc.GreaterEqual(end) {
+				loc = loc.MoveLA(-DiffLA(start, end, eh.buf.LineArray), eh.buf.LineArray)
+			}
+			return loc
 		}
-		c.Loc = move(c.Loc)
-		c.CurSelection[0] = move(c.CurSelection[0])
-		c.CurSelection[1] = move(c.CurSelection[1])
-		c.OrigSelection[0] = move(c.OrigSelection[0])
-		c.OrigSelection[1] = move(c.OrigSelection[1])
+	}
+
+	for _, c := range eh.cursors {
+		c.Loc = move(c.Loc, false)
+		c.CurSelection[0] = move(c.CurSelection[0], false)
+		c.CurSelection[1] = move(c.CurSelection[1], false)
+		c.OrigSelection[0] = move(c.OrigSelection[0], false)
+		c.OrigSelection[1] = move(c.OrigSelection[1], false)
 		c.Relocate()
 		c.StoreVisualX()
 	}
 
+	// The anchors of decorations and virtual texts, and the locations of
+	// the snippet, in the removed text move to its start
+	moveAnchor := func(loc Loc, stay bool) Loc {
+		if t.EventType == TextEventRemove && loc.GreaterThan(start) && loc.LessThan(end) {
+			return start
+		}
+		return move(loc, stay)
+	}
+	eh.buf.moveDecorations(moveAnchor)
+	eh.buf.moveVirtualTexts(moveAnchor)
+	if eh.snippet != nil {
+		eh.snippet.move(moveAnchor)
+	}
+
 	if useUndo {
 		eh.updateTrailingWs(t)
 	}
@@ -148,6 +165,10 @@ type EventHandler struct {
 	active    int
 	UndoStack *TEStack
 	RedoStack *TEStack
+
+	// snippet is the snippet being filled in, whose locations are moved
+	// with the changes of the text like the cursors
+	snippet *activeSnippet
 }
 
 // NewEventHandler returns a new EventHandler
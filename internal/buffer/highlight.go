@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+)
+
+const (
+	// syncHighlightLines is the number of lines whose highlighting state is
+	// updated right away after a modification. Most modifications do not
+	// change the state of the following lines, so that the highlighting is
+	// up to date without waiting for the background highlighter.
+	syncHighlightLines = 100
+	// highlightChunk is the number of lines whose highlighting state is
+	// updated by the background highlighter before it releases the lock of
+	// the buffer
+	highlightChunk = 1000
+)
+
+// invalidateHighlighting marks the lines from start to end (inclusive) as
+// modified, updates the highlighting states of a few lines and starts the
+// background highlighter to update the other ones if needed
+func (b *SharedBuffer) invalidateHighlighting(start, end int) {
+	b.LineArray.Lock()
+	defer b.LineArray.Unlock()
+
+	n := len(b.lines)
+	hlStart := int(b.hlStart.Load())
+	if hlStart < 0 {
+		b.hlEnd = end
+		hlStart = start
+	} else {
+		if b.hlEnd >= start {
+			// the lines which are not highlighted yet have moved
+			b.hlEnd += n - b.hlLines
+		}
+		b.hlEnd = util.Max(b.hlEnd, end)
+		hlStart = util.Min(hlStart, start)
+	}
+	b.hlLines = n
+	b.hlEnd = util.Clamp(b.hlEnd, 0, n-1)
+	b.hlStart.Store(int64(util.Clamp(hlStart, 0, n-1)))
+
+	if !b.highlightStates(syncHighlightLines) && !b.hlRunning {
+		b.hlRunning = true
+		go b.highlightInBackground()
+	}
+}
+
+// highlightStates updates the highlighting states of at most max lines
+// from the first line which is not highlighted yet, and returns whether the
+// highlighting is up to date. The matches of these lines are computed again
+// when they are displayed.
+// The buffer must be locked
+func (b *SharedBuffer) highlightStates(max int) bool {
+	for i := 0; i < max; i++ {
+		y := int(b.hlStart.Load())
+		if y < 0 {
+			return true
+		}
+		if b.Highlighter == nil || y >= len(b.lines) {
+			b.hlStart.Store(-1)
+			return true
+		}
+
+		state := b.Highlighter.HighlightLineState(b, y)
+		changed := state != b.State(y)
+		b.SetState(y, state)
+		b.SetMatch(y, nil)
+
+		// the following lines are highlighted correctly if the state of
+		// this line has not changed and it is after the modified lines
+		if (!changed && y >= b.hlEnd) || y+1 >= len(b.lines) {
+			b.hlStart.Store(-1)
+			return true
+		}
+		b.hlStart.Store(int64(y + 1))
+	}
+	return false
+}
+
+// highlightInBackground updates the highlighting states of the lines which
+// are not highlighted yet, one chunk at a time, and redraws the screen
+// after each chunk so that these lines are displayed when they are ready
+func (b *SharedBuffer) highlightInBackground() {
+	for {
+		b.LineArray.Lock()
+		done := b.highlightStates(highlightChunk)
+		if done {
+			b.hlRunning = false
+		}
+		b.LineArray.Unlock()
+
+		screen.Redraw()
+		if done {
+			return
+		}
+	}
+}
+
+// Match returns the syntax highlighting matches of the given line, which
+// are computed when the line is displayed for the first time since it was
+// highlighted. The states of the lines which are not highlighted yet are
+// updated up to the given line, so that the displayed lines are highlighted
+// before the following ones, unless there are more than a chunk of them.
+// It returns nil for the lines which are still not highlighted, so that
+// they are displayed as plain text.
+func (b *SharedBuffer) Match(lineN int) highlight.LineMatch {
+	m := b.LineArray.Match(lineN)
+	if m != nil || b.SyntaxDef == nil || b.Highlighter == nil || !b.Settings["syntax"].(bool) {
+		return m
+	}
+
+	b.LineArray.Lock()
+	defer b.LineArray.Unlock()
+	if start := int(b.hlStart.Load()); start >= 0 && lineN >= start {
+		if lineN-start >= highlightChunk {
+			return nil
+		}
+		b.highlightStates(lineN - start + 1)
+		if start := int(b.hlStart.Load()); start >= 0 && lineN >= start {
+			return nil
+		}
+	}
+	m = b.Highlighter.HighlightLineMatch(b, lineN)
+	b.SetMatch(lineN, m)
+	return m
+}
+
+// Highlighted returns whether the highlighting of the buffer is up to date
+func (b *SharedBuffer) Highlighted() bool {
+	return b.hlStart.Load() < 0
+}
@@ -0,0 +1,85 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitHighlighted waits for the background highlighter to finish
+func waitHighlighted(t *testing.T, b *Buffer) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.Highlighted() {
+		if time.Now().After(deadline) {
+			t.Fatal("the highlighting is not finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBackgroundHighlighting(t *testing.T) {
+	n := 5000
+	b := NewBufferFromString(strings.Repeat("x := 1\n", n), "", BTDefault)
+	defer b.Close()
+	waitHighlighted(t, b)
+	setSyntax(t, b, "go")
+	b.Highlighter = highlight.NewHighlighter(b.SyntaxDef)
+	b.invalidateHighlighting(0, b.LinesNum()-1)
+	waitHighlighted(t, b)
+	assert.Nil(t, b.State(n-1))
+	assert.Equal(t, "constant.number", b.Match(n - 1)[5].String())
+
+	// opening a comment changes the state of all the following lines
+	b.Insert(Loc{0, 0}, "/*")
+	waitHighlighted(t, b)
+	assert.NotNil(t, b.State(n-1))
+	assert.Equal(t, "comment", b.Match(n - 1)[0].String())
+
+	b.Insert(Loc{0, 1}, "*/")
+	waitHighlighted(t, b)
+	assert.Nil(t, b.State(n-1))
+	assert.Equal(t, "constant.number", b.Match(n - 1)[5].String())
+
+	// the lines which are not highlighted yet are displayed as plain text
+	b.Lock()
+	b.hlStart.Store(int64(n / 2))
+	b.SetMatch(n-1, nil)
+	b.SetMatch(0, nil)
+	b.Unlock()
+	assert.Nil(t, b.Match(n-1))
+	assert.NotNil(t, b.Match(0))
+
+	// but the displayed lines are highlighted first if they are close to
+	// the highlighted ones
+	b.SetMatch(n/2+10, nil)
+	assert.Equal(t, "constant.number", b.Match(n/2 + 10)[5].String())
+	// the states have not changed, so that the following lines are
+	// highlighted too
+	assert.True(t, b.Highlighted())
+}
+
+func TestInjectedLanguage(t *testing.T) {
+	assert.Nil(t, findInjectedDef("nosuchlanguage"))
+	def := findInjectedDef("golang")
+	if assert.NotNil(t, def) {
+		assert.Equal(t, "go", def.FileType)
+	}
+	if def := findInjectedDef("rs"); assert.NotNil(t, def) {
+		assert.Equal(t, "rust", def.FileType)
+	}
+
+	b := NewBufferFromString("# Title\n\n```go\n// x\nvar x = 1\n```\n\n```\n// y\n```", "", BTDefault)
+	defer b.Close()
+	setSyntax(t, b, "markdown")
+	b.Highlighter = highlight.NewHighlighter(b.SyntaxDef)
+	b.Highlighter.HighlightStates(b)
+
+	comment := highlight.Groups["comment"]
+	assert.Equal(t, comment, b.Highlighter.HighlightLineMatch(b, 3)[0])
+	assert.NotEqual(t, comment, b.Highlighter.HighlightLineMatch(b, 8)[0])
+	assert.Equal(t, "//", b.CommentAt(Loc{0, 4}).Line)
+	assert.Equal(t, "<!--", b.CommentAt(Loc{0, 6}).BlockStart)
+}
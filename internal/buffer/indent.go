@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+)
+
+// indentRules returns the indentation rules of the syntax of the buffer,
+// or nil if there are none
+func (b *Buffer) indentRules() *highlight.IndentRules {
+	if b.SyntaxDef == nil {
+		return nil
+	}
+	return b.SyntaxDef.Indent
+}
+
+// HasIndentRules returns whether the syntax of the buffer defines
+// indentation rules
+func (b *Buffer) HasIndentRules() bool {
+	return b.indentRules() != nil
+}
+
+// dedent removes one level of indentation from the end of indent
+func (b *Buffer) dedent(indent string) string {
+	if strings.HasSuffix(indent, "\t") {
+		return indent[:len(indent)-1]
+	}
+	n := 0
+	for n < util.IntOpt(b.Settings["tabsize"]) && strings.HasSuffix(indent[:len(indent)-n], " ") {
+		n++
+	}
+	return indent[:len(indent)-n]
+}
+
+// SmartIndent returns the indentation of line y computed with the
+// indentation rules of the syntax of the buffer: the indentation of the
+// previous non-blank line, increased by one level if this line matches the
+// increase rule, and decreased by one level if line y matches the decrease
+// rule. It returns false if the syntax has no indentation rules.
+func (b *Buffer) SmartIndent(y int) (string, bool) {
+	rules := b.indentRules()
+	if rules == nil {
+		return "", false
+	}
+
+	indent := ""
+	for p := y - 1; p >= 0; p-- {
+		line := b.LineBytes(p)
+		if util.IsSpacesOrTabs(line) {
+			continue
+		}
+		indent = string(util.GetLeadingWhitespace(line))
+		if rules.Increase != nil && rules.Increase.Match(line) {
+			indent += b.IndentString(util.IntOpt(b.Settings["tabsize"]))
+		}
+		break
+	}
+	if rules.Decrease != nil && rules.Decrease.Match(b.LineBytes(y)) {
+		indent = b.dedent(indent)
+	}
+	return indent, true
+}
+
+// IndentIncreases returns whether the indentation increases after line y
+func (b *Buffer) IndentIncreases(y int) bool {
+	rules := b.indentRules()
+	return rules != nil && rules.Increase != nil && rules.Increase.Match(b.LineBytes(y))
+}
+
+// IndentDecreases returns whether line y is indented one level less than
+// the previous line
+func (b *Buffer) IndentDecreases(y int) bool {
+	rules := b.indentRules()
+	return rules != nil && rules.Decrease != nil && rules.Decrease.Match(b.LineBytes(y))
+}
+
+// SetIndent replaces the leading whitespace of line y with indent
+func (b *Buffer) SetIndent(y int, indent string) {
+	ws := util.GetLeadingWhitespace(b.LineBytes(y))
+	if string(ws) == indent {
+		return
+	}
+	if len(ws) == 0 {
+		b.Insert(Loc{0, y}, indent)
+		return
+	}
+	b.Replace(Loc{0, y}, Loc{util.CharacterCount(ws), y}, indent)
+}
+
+// Reindent indents the non-blank lines from start to end (inclusive) with
+// the indentation rules of the syntax of the buffer. A line which matches
+// the decrease rule, or follows a line which matches the increase rule, gets
+// the indentation returned by SmartIndent. The other lines keep their
+// indentation relative to the previous non-blank line, or their indentation
+// if they are less indented than it, since a dedent which matches no rule
+// may be meaningful (for example in Python). It returns false if the syntax
+// has no indentation rules.
+func (b *Buffer) Reindent(start, end int) bool {
+	if !b.HasIndentRules() {
+		return false
+	}
+
+	// the previous non-blank line, and its indentation before and after
+	// it was reindented
+	prev := -1
+	prevOld, prevNew := "", ""
+	for p := start - 1; p >= 0; p-- {
+		if line := b.LineBytes(p); !util.IsSpacesOrTabs(line) {
+			prev = p
+			prevOld = string(util.GetLeadingWhitespace(line))
+			prevNew = prevOld
+			break
+		}
+	}
+
+	for y := start; y <= end && y < b.LinesNum(); y++ {
+		line := b.LineBytes(y)
+		if util.IsSpacesOrTabs(line) {
+			continue
+		}
+		old := string(util.GetLeadingWhitespace(line))
+		indent := old
+		if b.IndentDecreases(y) || prev >= 0 && b.IndentIncreases(prev) {
+			indent, _ = b.SmartIndent(y)
+		} else if strings.HasPrefix(old, prevOld) {
+			indent = prevNew + old[len(prevOld):]
+		}
+		b.SetIndent(y, indent)
+		prev, prevOld, prevNew = y, old, indent
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+// setSyntax sets the syntax of the buffer to the built-in syntax file of
+// the given filetype
+func setSyntax(t *testing.T, b *Buffer, ft string) {
+	data, err := config.FindRuntimeFile(config.RTSyntax, ft).Data()
+	assert.NoError(t, err)
+	header, err := highlight.MakeHeaderYaml(data)
+	assert.NoError(t, err)
+	file, err := highlight.ParseFile(data)
+	assert.NoError(t, err)
+	b.SyntaxDef, err = highlight.ParseDef(file, header)
+	assert.NoError(t, err)
+}
+
+func TestSmartIndent(t *testing.T) {
+	b := NewBufferFromString("func f() {\n\tif x {\n\n\t\t}\n\n}", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabstospaces", false)
+
+	_, ok := b.SmartIndent(2)
+	assert.False(t, ok)
+
+	setSyntax(t, b, "go")
+	indent, ok := b.SmartIndent(2)
+	assert.True(t, ok)
+	assert.Equal(t, "\t\t", indent)
+	// the closing brace is dedented, and blank lines are skipped
+	indent, _ = b.SmartIndent(3)
+	assert.Equal(t, "\t", indent)
+	assert.True(t, b.IndentIncreases(1))
+	assert.True(t, b.IndentDecreases(3))
+	assert.False(t, b.IndentDecreases(2))
+
+	assert.True(t, b.Reindent(0, b.LinesNum()-1))
+	assert.Equal(t, "func f() {\n\tif x {\n\n\t}\n\n}", string(b.Bytes()))
+}
+
+func TestReindentPython(t *testing.T) {
+	b := NewBufferFromString("def f(x):\nif x:\nreturn 1\n  else:\nreturn [\n1,\n]", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabstospaces", true)
+	b.SetOptionNative("tabsize", float64(4))
+	setSyntax(t, b, "python3")
+
+	assert.True(t, b.Reindent(1, b.LinesNum()-1))
+	assert.Equal(t, "def f(x):\n    if x:\n        return 1\n    else:\n        return [\n            1,\n        ]", string(b.Bytes()))
+}
+
+func TestReindentPythonDedent(t *testing.T) {
+	b := NewBufferFromString("if x:\n    a()\nb()\nif y:\nc()\nd()", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabstospaces", true)
+	b.SetOptionNative("tabsize", float64(4))
+	setSyntax(t, b, "python3")
+
+	// b() is not moved into the body of the if statement, but d() follows
+	// c() which was indented
+	assert.True(t, b.Reindent(0, b.LinesNum()-1))
+	assert.Equal(t, "if x:\n    a()\nb()\nif y:\n    c()\n    d()", string(b.Bytes()))
+}
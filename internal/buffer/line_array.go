@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"regexp"
 	"sync"
 
 	"github.com/micro-editor/micro/v2/internal/util"
@@ -34,11 +35,11 @@ func runeToByteIndex(n int, txt []byte) int {
 
 // A searchState contains the search match info for a single line
 type searchState struct {
-	search     string
-	useRegex   bool
-	ignorecase bool
-	match      [][2]int
-	done       bool
+	search      string
+	useRegex    bool
+	searchLines int
+	match       [][2]int
+	done        bool
 }
 
 // A Line contains the data in bytes as well as a highlight state, match
@@ -75,6 +76,11 @@ type LineArray struct {
 	Endings  FileFormat
 	initsize uint64
 	lock     sync.Mutex
+
+	// multilineSearch is set when a multi-line search has been made, so
+	// that the search matches of the neighbouring lines (as many as the
+	// searchlines option) must also be updated when a line is modified
+	multilineSearch bool
 }
 
 // Append efficiently appends lines together
@@ -385,7 +391,19 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 		return false
 	}
 
-	lineN := pos.Y
+	for _, m := range la.searchMatches(b, pos.Y) {
+		if pos.X >= m[0] && pos.X < m[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// searchMatches returns the start and end columns of the matches of the
+// last search for the buffer `b` in the given line, using the previously
+// found matches if the line has not been modified since.
+func (la *LineArray) searchMatches(b *Buffer, lineN int) [][2]int {
+	search, useRegex := b.LastSearchPattern()
 	if la.lines[lineN].search == nil {
 		la.lines[lineN].search = make(map[*Buffer]*searchState)
 	}
@@ -398,20 +416,25 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 		s = new(searchState)
 		la.lines[lineN].search[b] = s
 	}
-	if !ok || s.search != b.LastSearch || s.useRegex != b.LastSearchRegex ||
-		s.ignorecase != b.Settings["ignorecase"].(bool) {
-		s.search = b.LastSearch
-		s.useRegex = b.LastSearchRegex
-		s.ignorecase = b.Settings["ignorecase"].(bool)
+	if !ok || s.search != search || s.useRegex != useRegex || s.searchLines != b.searchLines() {
+		s.search = search
+		s.useRegex = useRegex
+		s.searchLines = b.searchLines()
 		s.done = false
 	}
 
 	if !s.done {
 		s.match = nil
+		if r, err := b.searchRegexp(search, useRegex); err == nil && multilineRegexp(r) {
+			s.match = la.multilineMatches(b, r, search, lineN)
+			s.done = true
+			return s.match
+		}
+
 		start := Loc{0, lineN}
 		end := Loc{util.CharacterCount(la.lines[lineN].data), lineN}
 		for start.X < end.X {
-			m, found, _ := b.FindNext(b.LastSearch, start, end, start, true, b.LastSearchRegex)
+			m, found, _ := b.FindNext(search, start, end, start, true, useRegex)
 			if !found {
 				break
 			}
@@ -426,12 +449,56 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 		s.done = true
 	}
 
-	for _, m := range s.match {
-		if pos.X >= m[0] && pos.X < m[1] {
-			return true
+	return s.match
+}
+
+// multilineMatches returns the start and end columns of the parts of the
+// matches of the multi-line regexp r, compiled from search, which are in the
+// given line. A match starting on a previous line has a start column of -1,
+// and a match ending on a next line has an end column after the end of the
+// line. The matches of a line are searched searchlines lines around it, so
+// the matches are searched in a window which is kept for the next lines
+// until the search or the text changes.
+func (la *LineArray) multilineMatches(b *Buffer, r *regexp.Regexp, search string, lineN int) [][2]int {
+	la.multilineSearch = true
+	n := b.searchLines()
+
+	w := &b.lastWindow
+	if !w.done || w.search != search || w.searchLines != n || w.changes != b.changes ||
+		lineN < w.from || lineN > w.to {
+		first := util.Max(lineN-n+1, 0)
+		last := util.Min(lineN+3*n-1, len(la.lines)-1)
+		from := Loc{0, first}
+		to := Loc{util.CharacterCount(la.lines[last].data), last}
+
+		w.search = search
+		w.searchLines = n
+		w.changes = b.changes
+		w.matches = b.findAll(r, from, to)
+		w.done = true
+		// the lines whose matches are all in the window
+		w.from, w.to = lineN, lineN+2*n
+		if first == 0 {
+			w.from = 0
 		}
 	}
-	return false
+
+	nchars := util.CharacterCount(la.lines[lineN].data)
+	var matches [][2]int
+	for _, m := range w.matches {
+		if m[0].Y > lineN || m[1].Y < lineN || (m[0].Y < lineN && m[1] == Loc{0, lineN}) {
+			continue
+		}
+		x0, x1 := m[0].X, m[1].X
+		if m[0].Y < lineN {
+			x0 = -1
+		}
+		if m[1].Y > lineN {
+			x1 = nchars + 1
+		}
+		matches = append(matches, [2]int{x0, x1})
+	}
+	return matches
 }
 
 // invalidateSearchMatches marks search matches for the given line as outdated.
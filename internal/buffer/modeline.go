@@ -0,0 +1,233 @@
+package buffer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vim?|Vim|ex):\s*(.*)`)
+	vimSet        = regexp.MustCompile(`^set?\s+((?:[^:\\]|\\.)*):`)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(.*?)\s*-\*-`)
+)
+
+// modelineFiletypes maps the names of the filetypes and modes of Vim and
+// Emacs to the filetypes of micro when they differ
+var modelineFiletypes = map[string]string{
+	"bash":         "shell",
+	"cpp":          "c++",
+	"cs":           "csharp",
+	"diff":         "patch",
+	"dosbatch":     "batch",
+	"elisp":        "lisp",
+	"emacs-lisp":   "lisp",
+	"gitcommit":    "git-commit",
+	"gitconfig":    "git-config",
+	"js":           "javascript",
+	"latex":        "tex",
+	"make":         "makefile",
+	"md":           "markdown",
+	"nroff":        "groff",
+	"objc":         "objective-c",
+	"ps1":          "powershell",
+	"py":           "python",
+	"python3":      "python",
+	"rb":           "ruby",
+	"rs":           "rust",
+	"sh":           "shell",
+	"shell-script": "shell",
+	"ts":           "typescript",
+	"yml":          "yaml",
+}
+
+// modeline holds the options found in the modelines of a buffer
+type modeline struct {
+	filetype     string
+	tabstospaces *bool
+	tabWidth     int
+	indentWidth  int
+	fileformat   string
+	eofnewline   *bool
+	softwrap     *bool
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func modelineFiletype(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "-mode"))
+	if ft, ok := modelineFiletypes[name]; ok {
+		return ft
+	}
+	return name
+}
+
+// parseVim parses the options of a Vim modeline. Only the options in this
+// function are honored.
+func (m *modeline) parseVim(line string) bool {
+	match := vimModeline.FindStringSubmatch(line)
+	if match == nil {
+		return false
+	}
+	var opts []string
+	if set := vimSet.FindStringSubmatch(match[1]); set != nil {
+		opts = strings.Fields(set[1])
+	} else {
+		opts = strings.FieldsFunc(match[1], func(r rune) bool {
+			return r == ':' || util.IsWhitespace(r)
+		})
+	}
+
+	for _, opt := range opts {
+		name, value, _ := strings.Cut(opt, "=")
+		value = strings.ReplaceAll(value, `\`, "")
+		n, _ := strconv.Atoi(value)
+		switch name {
+		case "ft", "filetype", "syn", "syntax":
+			m.filetype = modelineFiletype(value)
+		case "ts", "tabstop":
+			m.tabWidth = n
+		case "sw", "shiftwidth", "sts", "softtabstop":
+			m.indentWidth = n
+		case "et", "expandtab":
+			m.tabstospaces = boolPtr(true)
+		case "noet", "noexpandtab":
+			m.tabstospaces = boolPtr(false)
+		case "ff", "fileformat":
+			m.fileformat = value
+		case "eol", "endofline", "fixeol", "fixendofline":
+			m.eofnewline = boolPtr(true)
+		case "noeol", "noendofline", "nofixeol", "nofixendofline":
+			m.eofnewline = boolPtr(false)
+		case "wrap":
+			m.softwrap = boolPtr(true)
+		case "nowrap":
+			m.softwrap = boolPtr(false)
+		}
+	}
+	return true
+}
+
+// setEmacs sets the option of the Emacs variable name. Only the variables
+// in this function are honored.
+func (m *modeline) setEmacs(name, value string) {
+	name = strings.ToLower(name)
+	value = strings.Trim(value, `"`)
+	n, _ := strconv.Atoi(value)
+	switch {
+	case name == "mode":
+		m.filetype = modelineFiletype(value)
+	case name == "tab-width":
+		m.tabWidth = n
+	case name == "indent-tabs-mode":
+		m.tabstospaces = boolPtr(value == "nil")
+	case name == "require-final-newline":
+		m.eofnewline = boolPtr(value != "nil")
+	case name == "coding":
+		if strings.HasSuffix(value, "-unix") {
+			m.fileformat = "unix"
+		} else if strings.HasSuffix(value, "-dos") {
+			m.fileformat = "dos"
+		}
+	case strings.HasSuffix(name, "-basic-offset"), strings.HasSuffix(name, "-indent-offset"),
+		strings.HasSuffix(name, "-indent-level"), name == "standard-indent":
+		m.indentWidth = n
+	}
+}
+
+// parseEmacs parses the variables of an Emacs modeline, which is either a
+// mode name or a list of variables between `-*-`
+func (m *modeline) parseEmacs(line string) bool {
+	match := emacsModeline.FindStringSubmatch(line)
+	if match == nil {
+		return false
+	}
+	if !strings.Contains(match[1], ":") {
+		m.filetype = modelineFiletype(match[1])
+		return true
+	}
+	for _, v := range strings.Split(match[1], ";") {
+		name, value, ok := strings.Cut(v, ":")
+		if ok {
+			m.setEmacs(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return true
+}
+
+// parseEmacsLocalVariables parses an Emacs "Local Variables" list starting
+// at line y. Each line of the list starts with the same prefix as the first
+// one, and the list ends with "End:".
+func (m *modeline) parseEmacsLocalVariables(b *Buffer, y int) {
+	first := string(b.LineBytes(y))
+	i := strings.Index(first, "Local Variables:")
+	prefix, suffix := first[:i], strings.TrimSpace(first[i+len("Local Variables:"):])
+	for y++; y < b.LinesNum(); y++ {
+		line := string(b.LineBytes(y))
+		if !strings.HasPrefix(line, prefix) {
+			return
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line[len(prefix):]), suffix))
+		if line == "End:" {
+			return
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			m.setEmacs(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+}
+
+// modelineSettings returns the options set by the Vim and Emacs modelines
+// in the first and last lines of the buffer. The number of lines is given
+// by the `modelines` option.
+func (b *Buffer) modelineSettings() map[string]any {
+	settings := make(map[string]any)
+	n := util.IntOpt(b.Settings["modelines"])
+	if n <= 0 {
+		return settings
+	}
+
+	m := new(modeline)
+	lines := b.LinesNum()
+	for y := 0; y < lines; y++ {
+		if y == n && lines-n > y {
+			y = lines - n
+		}
+		line := string(b.LineBytes(y))
+		if strings.Contains(line, "Local Variables:") {
+			m.parseEmacsLocalVariables(b, y)
+		} else if !m.parseVim(line) {
+			m.parseEmacs(line)
+		}
+	}
+
+	if m.filetype != "" {
+		settings["filetype"] = m.filetype
+	}
+	if m.tabstospaces != nil {
+		settings["tabstospaces"] = *m.tabstospaces
+	}
+	// micro uses the same width for indentation and tabs, so the indentation
+	// width wins when indenting with spaces
+	width := m.tabWidth
+	if m.indentWidth > 0 && (width <= 0 || (m.tabstospaces != nil && *m.tabstospaces)) {
+		width = m.indentWidth
+	}
+	if width > 0 {
+		settings["tabsize"] = float64(width)
+	}
+	if m.fileformat == "unix" || m.fileformat == "dos" {
+		settings["fileformat"] = m.fileformat
+	}
+	if m.eofnewline != nil {
+		settings["eofnewline"] = *m.eofnewline
+	}
+	if m.softwrap != nil {
+		settings["softwrap"] = *m.softwrap
+	}
+	return settings
+}
@@ -0,0 +1,61 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelineSettings(t *testing.T) {
+	tests := []struct {
+		text     string
+		settings map[string]any
+	}{
+		{"/* vim: set ts=8 noet ft=cpp: */\nint x;", map[string]any{
+			"tabsize": float64(8), "tabstospaces": false, "filetype": "c++",
+		}},
+		{"x\n# vim:sw=2:et:nowrap", map[string]any{
+			"tabsize": float64(2), "tabstospaces": true, "softwrap": false,
+		}},
+		{"/* -*- mode: c; tab-width: 8; indent-tabs-mode: t -*- */", map[string]any{
+			"filetype": "c", "tabsize": float64(8), "tabstospaces": false,
+		}},
+		{"#!/bin/sh\n# -*- shell-script -*-", map[string]any{"filetype": "shell"}},
+		{"x\n# Local Variables:\n# python-indent-offset: 4\n# coding: utf-8-dos\n# End:", map[string]any{
+			"tabsize": float64(4), "fileformat": "dos",
+		}},
+		// options that are not in the allow-list are ignored
+		{"# vim: set shell=rm modeline:\n", map[string]any{}},
+		{"a vi: b\n", map[string]any{}},
+	}
+	for _, test := range tests {
+		b := NewBufferFromString(test.text, "", BTDefault)
+		assert.Equal(t, test.settings, b.modelineSettings(), test.text)
+		b.Close()
+	}
+
+	// only the first and last lines are scanned
+	b := NewBufferFromString("\n\n\nvim: ts=3\n\n\n\n", "", BTDefault)
+	defer b.Close()
+	b.Settings["modelines"] = float64(3)
+	assert.Empty(t, b.modelineSettings())
+	b.Settings["modelines"] = float64(4)
+	assert.Equal(t, map[string]any{"tabsize": float64(3)}, b.modelineSettings())
+	b.Settings["modelines"] = float64(0)
+	assert.Empty(t, b.modelineSettings())
+}
+
+func TestModelineBuffer(t *testing.T) {
+	b := NewBufferFromString("# vim: ft=python ts=2 et\n", "", BTDefault)
+	defer b.Close()
+
+	assert.Equal(t, "python", b.Settings["filetype"])
+	assert.NotNil(t, b.SyntaxDef)
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+	assert.True(t, b.LocalSettings["tabsize"])
+
+	// the modelines are kept when the settings are reloaded
+	b.ReloadSettings(true)
+	assert.Equal(t, "python", b.Settings["filetype"])
+	assert.Equal(t, float64(2), b.Settings["tabsize"])
+}
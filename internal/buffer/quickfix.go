@@ -0,0 +1,125 @@
+package buffer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A QuickfixEntry is a location in a file with a message attached, such as
+// a compiler error or a search result
+type QuickfixEntry struct {
+	// Path of the file
+	Path string
+	// Loc is the location in the file, X is -1 if the column is unknown
+	Loc Loc
+	// Kind is the severity of the entry
+	Kind MsgType
+	// Msg is the text of the entry
+	Msg string
+}
+
+// NewQuickfixEntry creates a new quickfix entry
+func NewQuickfixEntry(path string, loc Loc, kind MsgType, msg string) *QuickfixEntry {
+	return &QuickfixEntry{
+		Path: path,
+		Loc:  loc,
+		Kind: kind,
+		Msg:  msg,
+	}
+}
+
+// errorformatVerbs maps the verbs of an errorformat to the regular
+// expression matching them
+var errorformatVerbs = map[byte]string{
+	'f': `(?P<f>.+?)`,
+	'l': `(?P<l>\d+)`,
+	'c': `(?P<c>\d+)`,
+	'm': `(?P<m>.+)`,
+	't': `(?P<t>[EeWwIi])`,
+}
+
+// CompileErrorFormat converts an errorformat into a regular expression.
+// An errorformat is a regular expression where the following verbs can be
+// used:
+//
+//	%f: file name
+//	%l: line number
+//	%c: column number
+//	%m: message
+//	%t: kind of the message (a single E, W or I character)
+//	%%: the % character
+func CompileErrorFormat(errorformat string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(errorformat); i++ {
+		c := errorformat[i]
+		if c != '%' || i+1 == len(errorformat) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if verb, ok := errorformatVerbs[errorformat[i]]; ok {
+			b.WriteString(verb)
+		} else if errorformat[i] == '%' {
+			b.WriteString("%")
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(errorformat[i])
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// ParseErrorFormat parses the output of a command (a compiler, a linter...)
+// with the given errorformat and returns the entries for the lines that
+// match it. Entries without a %t verb get the given kind.
+func ParseErrorFormat(output, errorformat string, kind MsgType) ([]QuickfixEntry, error) {
+	r, err := CompileErrorFormat(errorformat)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		sm := r.FindStringSubmatch(line)
+		if sm == nil {
+			continue
+		}
+
+		e := QuickfixEntry{
+			Loc:  Loc{-1, 0},
+			Kind: kind,
+		}
+		for i, name := range r.SubexpNames() {
+			switch name {
+			case "f":
+				e.Path = sm[i]
+			case "l":
+				l, _ := strconv.Atoi(sm[i])
+				e.Loc.Y = l - 1
+			case "c":
+				c, _ := strconv.Atoi(sm[i])
+				e.Loc.X = c - 1
+			case "m":
+				e.Msg = sm[i]
+			case "t":
+				switch sm[i] {
+				case "E", "e":
+					e.Kind = MTError
+				case "W", "w":
+					e.Kind = MTWarning
+				case "I", "i":
+					e.Kind = MTInfo
+				}
+			}
+		}
+		if e.Path == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
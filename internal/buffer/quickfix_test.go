@@ -0,0 +1,30 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorFormat(t *testing.T) {
+	output := "# example\n" +
+		"main.go:12:5: undefined: foo\n" +
+		"  util/a.go:3:1: missing return\n" +
+		"not an error\n"
+
+	entries, err := ParseErrorFormat(output, "%f:%l:%c: %m", MTError)
+	assert.NoError(t, err)
+	assert.Equal(t, []QuickfixEntry{
+		{"main.go", Loc{4, 11}, MTError, "undefined: foo"},
+		{"util/a.go", Loc{0, 2}, MTError, "missing return"},
+	}, entries)
+
+	entries, err = ParseErrorFormat("x.c(7): W: unused variable", `%f\(%l\): %t: %m`, MTError)
+	assert.NoError(t, err)
+	assert.Equal(t, []QuickfixEntry{
+		{"x.c", Loc{-1, 6}, MTWarning, "unused variable"},
+	}, entries)
+
+	_, err = ParseErrorFormat("", "%f:(%l", MTError)
+	assert.Error(t, err)
+}
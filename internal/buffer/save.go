@@ -345,6 +345,10 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		b.ReloadSettings(true)
 	}
 
+	if b.SaveCallback != nil {
+		b.SaveCallback()
+	}
+
 	err = b.Serialize()
 	return err
 }
@@ -1,7 +1,11 @@
 package buffer
 
 import (
+	"bytes"
+	"errors"
 	"regexp"
+	"regexp/syntax"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/micro-editor/micro/v2/internal/util"
@@ -41,24 +45,176 @@ func findLineParams(b *Buffer, start, end Loc, i int, r *regexp.Regexp) ([]byte,
 		}
 	}
 
-	if padMode != 0 {
-		re, err := regexp.Compile(r.String() + `\E`)
-		if err == nil {
-			// r contains \Q without closing \E
-			r = re
+	return l, charpos, padMode, padRegexp(r, padMode)
+}
+
+// padRegexp returns the regexp r padded according to padMode
+func padRegexp(r *regexp.Regexp, padMode int) *regexp.Regexp {
+	if padMode == 0 {
+		return r
+	}
+
+	re, err := regexp.Compile(r.String() + `\E`)
+	if err == nil {
+		// r contains \Q without closing \E
+		r = re
+	}
+
+	if padMode == padStart {
+		return regexp.MustCompile(".(?:" + r.String() + ")")
+	} else if padMode == padEnd {
+		return regexp.MustCompile("(?:" + r.String() + ").")
+	}
+	// padMode == padStart|padEnd
+	return regexp.MustCompile(".(?:" + r.String() + ").")
+}
+
+// multilineRegexps caches the result of multilineRegexp for each pattern,
+// so that a pattern is parsed only once and not at each search
+var multilineRegexps = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+// multilineRegexp returns whether the regexp r can match a newline, in
+// which case its matches may span several lines. This is the case when the
+// pattern contains a newline (\n) or a dot matching newlines (with the s
+// flag). Negated classes such as [^a], and classes such as \s which also
+// match spaces, do not make a pattern multi-line.
+func multilineRegexp(r *regexp.Regexp) bool {
+	pattern := r.String()
+	multilineRegexps.Lock()
+	defer multilineRegexps.Unlock()
+	if multiline, ok := multilineRegexps.m[pattern]; ok {
+		return multiline
+	}
+
+	multiline := false
+	if re, err := syntax.Parse(pattern, syntax.Perl&^syntax.ClassNL); err == nil {
+		multiline = matchesNewline(re)
+	}
+	if len(multilineRegexps.m) >= 100 {
+		// the patterns typed in incremental searches are not kept forever
+		multilineRegexps.m = make(map[string]bool)
+	}
+	multilineRegexps.m[pattern] = multiline
+	return multiline
+}
+
+func matchesNewline(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar:
+		return true
+	case syntax.OpLiteral:
+		for _, c := range re.Rune {
+			if c == '\n' {
+				return true
+			}
 		}
+	case syntax.OpCharClass:
+		nl, space := false, false
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			nl = nl || (re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1])
+			space = space || (re.Rune[i] <= ' ' && ' ' <= re.Rune[i+1])
+		}
+		if nl && !space {
+			return true
+		}
+	}
+	for _, sub := range re.Sub {
+		if matchesNewline(sub) {
+			return true
+		}
+	}
+	return false
+}
 
-		if padMode == padStart {
-			r = regexp.MustCompile(".(?:" + r.String() + ")")
-		} else if padMode == padEnd {
-			r = regexp.MustCompile("(?:" + r.String() + ").")
-		} else {
-			// padMode == padStart|padEnd
-			r = regexp.MustCompile(".(?:" + r.String() + ").")
+// searchLines returns the number of lines a match of a multi-line search is
+// guaranteed to be found within
+func (b *SharedBuffer) searchLines() int {
+	return util.Max(int(b.Settings["searchlines"].(float64)), 1)
+}
+
+// textLoc returns the location in the buffer of the byte at offset off in
+// text, which is the text of the buffer starting at loc
+func textLoc(text []byte, off int, loc Loc) Loc {
+	before := text[:off]
+	nl := bytes.LastIndexByte(before, '\n')
+	if nl < 0 {
+		return Loc{loc.X + util.CharacterCount(before), loc.Y}
+	}
+	return Loc{util.CharacterCount(before[nl+1:]), loc.Y + bytes.Count(before, []byte{'\n'})}
+}
+
+// findInRange returns the first match of r between start and end, which
+// may span several lines
+func (b *Buffer) findInRange(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
+	start.X = util.Clamp(start.X, 0, util.CharacterCount(b.LineBytes(start.Y)))
+	end.X = util.Clamp(end.X, 0, util.CharacterCount(b.LineBytes(end.Y)))
+
+	padMode := 0
+	from, to := start, end
+	if start.X > 0 {
+		from = Loc{start.X - 1, start.Y}
+		padMode |= padStart
+	}
+	if end.X < util.CharacterCount(b.LineBytes(end.Y)) {
+		to = Loc{end.X + 1, end.Y}
+		padMode |= padEnd
+	}
+
+	text := b.Substr(from, to)
+	match := padRegexp(r, padMode).FindIndex(text)
+	if match == nil {
+		return [2]Loc{}, false
+	}
+	if padMode&padStart != 0 {
+		_, size := utf8.DecodeRune(text[match[0]:])
+		match[0] += size
+	}
+	if padMode&padEnd != 0 {
+		_, size := utf8.DecodeLastRune(text[:match[1]])
+		match[1] -= size
+	}
+	return [2]Loc{textLoc(text, match[0], from), textLoc(text, match[1], from)}, true
+}
+
+// findDownMultiline is findDown for a regexp which can match a newline. The
+// text is searched in windows of twice the searchlines option, so that the
+// matches spanning up to searchlines lines are always found.
+func (b *Buffer) findDownMultiline(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
+	n := b.searchLines()
+	for y := start.Y; y <= end.Y; y += n {
+		from := Loc{0, y}.Clamp(start, end)
+		to := end
+		if last := y + 2*n - 1; last < end.Y {
+			to = Loc{util.CharacterCount(b.LineBytes(last)), last}
+		}
+
+		match, found := b.findInRange(r, from, to)
+		if found && (match[0].Y < y+n || to == end) {
+			return match, true
 		}
 	}
+	return [2]Loc{}, false
+}
 
-	return l, charpos, padMode, r
+// findUpMultiline is findUp for a regexp which can match a newline
+func (b *Buffer) findUpMultiline(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
+	n := b.searchLines()
+	for y := end.Y; y >= start.Y; y -= n {
+		from := Loc{0, y - n + 1}.Clamp(start, end)
+		last := util.Min(y+n-1, b.LinesNum()-1)
+		to := Loc{util.CharacterCount(b.LineBytes(last)), last}.Clamp(start, end)
+
+		matches := b.findAll(r, from, to)
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i][0].Y <= y {
+				return matches[i], true
+			}
+		}
+	}
+	return [2]Loc{}, false
 }
 
 func (b *Buffer) findDown(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
@@ -76,6 +232,10 @@ func (b *Buffer) findDown(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 		start, end = end, start
 	}
 
+	if multilineRegexp(r) {
+		return b.findDownMultiline(r, start, end)
+	}
+
 	for i := start.Y; i <= end.Y; i++ {
 		l, charpos, padMode, rPadded := findLineParams(b, start, end, i, r)
 
@@ -113,6 +273,10 @@ func (b *Buffer) findUp(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 		start, end = end, start
 	}
 
+	if multilineRegexp(r) {
+		return b.findUpMultiline(r, start, end)
+	}
+
 	for i := end.Y; i >= start.Y; i-- {
 		charCount := util.CharacterCount(b.LineBytes(i))
 		from := Loc{0, i}.Clamp(start, end)
@@ -128,9 +292,15 @@ func (b *Buffer) findUp(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 }
 
 func (b *Buffer) findAll(r *regexp.Regexp, start, end Loc) [][2]Loc {
+	return b.findAllLimit(r, start, end, -1)
+}
+
+// findAllLimit is findAll returning at most n matches, or all the matches
+// if n is negative
+func (b *Buffer) findAllLimit(r *regexp.Regexp, start, end Loc, n int) [][2]Loc {
 	var matches [][2]Loc
 	loc := start
-	for {
+	for n < 0 || len(matches) < n {
 		match, found := b.findDown(r, loc, end)
 		if !found {
 			break
@@ -147,6 +317,18 @@ func (b *Buffer) findAll(r *regexp.Regexp, start, end Loc) [][2]Loc {
 	return matches
 }
 
+// searchRegexp compiles the regexp used by FindNext to search for s
+func (b *Buffer) searchRegexp(s string, useRegex bool) (*regexp.Regexp, error) {
+	if !useRegex {
+		s = regexp.QuoteMeta(s)
+	}
+
+	if b.Settings["ignorecase"].(bool) {
+		return regexp.Compile("(?i)" + s)
+	}
+	return regexp.Compile(s)
+}
+
 // FindNext finds the next occurrence of a given string in the buffer
 // It returns the start and end location of the match (if found) and
 // a boolean indicating if it was found
@@ -156,19 +338,7 @@ func (b *Buffer) FindNext(s string, start, end, from Loc, down bool, useRegex bo
 		return [2]Loc{}, false, nil
 	}
 
-	var r *regexp.Regexp
-	var err error
-
-	if !useRegex {
-		s = regexp.QuoteMeta(s)
-	}
-
-	if b.Settings["ignorecase"].(bool) {
-		r, err = regexp.Compile("(?i)" + s)
-	} else {
-		r, err = regexp.Compile(s)
-	}
-
+	r, err := b.searchRegexp(s, useRegex)
 	if err != nil {
 		return [2]Loc{}, false, err
 	}
@@ -189,14 +359,144 @@ func (b *Buffer) FindNext(s string, start, end, from Loc, down bool, useRegex bo
 	return l, found, nil
 }
 
+// WholeWordPattern returns a regular expression matching the given search
+// string only when it is a whole word
+func WholeWordPattern(s string, useRegex bool) string {
+	if !useRegex {
+		s = regexp.QuoteMeta(s)
+	}
+	return `\b(?:` + s + `)\b`
+}
+
+// SearchPattern returns the pattern to pass to FindNext to search for s
+// with the given options, and whether this pattern is a regular expression.
+// The case sensitivity of the pattern overrides the ignorecase option.
+func SearchPattern(s string, useRegex, matchCase, wholeWord bool) (string, bool) {
+	if wholeWord {
+		s = WholeWordPattern(s, useRegex)
+	} else if !useRegex {
+		s = regexp.QuoteMeta(s)
+	}
+	if matchCase {
+		return "(?-i)" + s, true
+	}
+	return "(?i)" + s, true
+}
+
+// LastSearchCaseSensitive returns whether the last search is case-sensitive
+func (b *Buffer) LastSearchCaseSensitive() bool {
+	if b.LastSearchCaseToggled {
+		return b.LastSearchMatchCase
+	}
+	return !b.Settings["ignorecase"].(bool)
+}
+
+// LastSearchPattern returns the pattern to pass to FindNext to repeat the
+// last search, and whether this pattern is a regular expression
+func (b *Buffer) LastSearchPattern() (string, bool) {
+	return SearchPattern(b.LastSearch, b.LastSearchRegex, b.LastSearchCaseSensitive(), b.LastSearchWholeWord)
+}
+
+// A searchWindow holds the matches of a multi-line search in a window of
+// lines, which contains all the matches in the lines from `from` to `to`
+type searchWindow struct {
+	searchMatches
+	from, to int
+}
+
+// SearchCountLimit is the number of matches after which SearchCount stops
+// counting, so that the statusline stays fast in huge files
+const SearchCountLimit = 999
+
+// searchMatches are the first matches of a search in the whole buffer,
+// which are valid as long as the search, the searchlines option and the
+// text do not change
+type searchMatches struct {
+	search      string
+	searchLines int
+	changes     int
+	matches     [][2]Loc
+	done        bool
+}
+
+// lastSearchMatches returns the first SearchCountLimit+1 matches of the last
+// search in the whole buffer. The matches are cached until the search or the
+// text changes, so that they are searched only once with a single compiled
+// regexp, and not at each redraw of the statusline.
+func (b *Buffer) lastSearchMatches() [][2]Loc {
+	search, useRegex := b.LastSearchPattern()
+	m := &b.lastMatches
+	if m.done && m.search == search && m.searchLines == b.searchLines() && m.changes == b.changes {
+		return m.matches
+	}
+	m.search = search
+	m.searchLines = b.searchLines()
+	m.changes = b.changes
+	m.matches = nil
+	m.done = true
+
+	r, err := b.searchRegexp(search, useRegex)
+	if err != nil {
+		return nil
+	}
+	if multilineRegexp(r) {
+		m.matches = b.findAllLimit(r, b.Start(), b.End(), SearchCountLimit+1)
+		return m.matches
+	}
+	for i := 0; i < b.LinesNum() && len(m.matches) <= SearchCountLimit; i++ {
+		l := b.LineBytes(i)
+		for _, match := range r.FindAllIndex(l, SearchCountLimit+1-len(m.matches)) {
+			m.matches = append(m.matches, [2]Loc{
+				{util.RunePos(l, match[0]), i},
+				{util.RunePos(l, match[1]), i},
+			})
+		}
+	}
+	return m.matches
+}
+
+// SearchCount returns the number of matches of the last search in the
+// buffer, and the number (starting from 1) of the match spanning exactly
+// the given range, or 0 if the range is not a match. It stops counting
+// after SearchCountLimit matches, in which case the number of matches is
+// SearchCountLimit+1, and the number of a match after them is 0.
+func (b *Buffer) SearchCount(sel [2]Loc) (int, int) {
+	if b.LastSearch == "" {
+		return 0, 0
+	}
+
+	matches := b.lastSearchMatches()
+	for i, m := range matches {
+		if i < SearchCountLimit && m == sel {
+			return len(matches), i + 1
+		}
+	}
+	return len(matches), 0
+}
+
 // ReplaceRegex replaces all occurrences of 'search' with 'replace' in the given area
 // and returns the number of replacements made and the number of characters
-// added or removed on the last line of the range
+// added or removed on the last line of the range. If 'search' can match a
+// newline, the second value is the number of characters added or removed
+// in the whole range instead.
 func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []byte, captureGroups bool) (int, int) {
 	if start.GreaterThan(end) {
 		start, end = end, start
 	}
 
+	if multilineRegexp(search) {
+		matches := b.FindReplacements(start, end, search, replace, captureGroups)
+		nchars := 0
+		deltas := make([]Delta, 0, len(matches))
+		for i := len(matches) - 1; i >= 0; i-- {
+			m := matches[i]
+			nchars += util.CharacterCount(m.New) - util.CharacterCount(m.Old)
+			deltas = append(deltas, Delta{m.New, m.Start, m.End})
+		}
+		b.MultipleReplace(deltas)
+		return len(matches), nchars
+	}
+
 	charsEnd := util.CharacterCount(b.LineBytes(end.Y))
 	found := 0
 	var deltas []Delta
@@ -245,3 +545,59 @@ func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []b
 
 	return found, util.CharacterCount(b.LineBytes(end.Y)) - charsEnd
 }
+
+// A ReplaceMatch is a match of a search with the text it is replaced with
+type ReplaceMatch struct {
+	Start, End Loc
+	Old, New   []byte
+}
+
+// FindReplacements returns the matches of 'search' in the given area, with
+// the text ReplaceRegex would replace each of them with, without modifying
+// the buffer
+func (b *Buffer) FindReplacements(start, end Loc, search *regexp.Regexp, replace []byte, captureGroups bool) []ReplaceMatch {
+	if start.GreaterThan(end) {
+		start, end = end, start
+	}
+
+	var matches []ReplaceMatch
+	for _, m := range b.findAll(search, start, end) {
+		old := b.Substr(m[0], m[1])
+		newText := replace
+		if captureGroups {
+			newText = search.ReplaceAll(old, replace)
+		}
+		matches = append(matches, ReplaceMatch{m[0], m[1], old, newText})
+	}
+	return matches
+}
+
+// FindTextReplacements returns the matches of 'search' in text, with the
+// text each of them would be replaced with, as FindReplacements finds them
+// in a buffer containing text and having the given settings
+func FindTextReplacements(text []byte, settings map[string]any, search *regexp.Regexp, replace []byte, captureGroups bool) []ReplaceMatch {
+	b := new(Buffer)
+	b.SharedBuffer = new(SharedBuffer)
+	b.Settings = settings
+	b.LineArray = NewLineArray(uint64(len(text)), FFAuto, bytes.NewReader(text))
+	return b.FindReplacements(b.Start(), b.End(), search, replace, captureGroups)
+}
+
+// ApplyReplacements replaces the given matches with their new text as a
+// single undo event. It returns an error and leaves the buffer unchanged if
+// the text of a match has changed since it was found.
+func (b *Buffer) ApplyReplacements(matches []ReplaceMatch) error {
+	deltas := make([]Delta, 0, len(matches))
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		if !m.Start.LessEqual(m.End) || !m.End.LessEqual(b.End()) ||
+			string(b.Substr(m.Start, m.End)) != string(m.Old) {
+			return errors.New("the buffer has been modified since the search")
+		}
+		deltas = append(deltas, Delta{m.New, m.Start, m.End})
+	}
+	if len(deltas) > 0 {
+		b.MultipleReplace(deltas)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package buffer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchCount(t *testing.T) {
+	b := NewBufferFromString("foo bar\nfood foo\nbar", "", BTDefault)
+
+	total, cur := b.SearchCount([2]Loc{{0, 0}, {3, 0}})
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, cur)
+
+	b.LastSearch = "foo"
+	total, cur = b.SearchCount([2]Loc{{5, 1}, {8, 1}})
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 3, cur)
+
+	total, cur = b.SearchCount([2]Loc{{1, 1}, {2, 1}})
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 0, cur)
+
+	b.LastSearchWholeWord = true
+	total, cur = b.SearchCount([2]Loc{{5, 1}, {8, 1}})
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 2, cur)
+
+	b.LastSearch = "fo+"
+	b.LastSearchRegex = true
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 2, total)
+
+	// the last search follows the ignorecase option, unless its case
+	// sensitivity was toggled
+	b.LastSearch = "FOO"
+	b.LastSearchRegex = false
+	b.LastSearchWholeWord = false
+	b.Settings["ignorecase"] = true
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 3, total)
+	b.Settings["ignorecase"] = false
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 0, total)
+	b.LastSearchMatchCase = false
+	b.LastSearchCaseToggled = true
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 3, total)
+	b.LastSearchMatchCase = true
+	b.Settings["ignorecase"] = true
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 0, total)
+}
+
+func TestSearchCountLimit(t *testing.T) {
+	b := NewBufferFromString(strings.Repeat("a a\n", SearchCountLimit), "", BTDefault)
+	b.LastSearch = "a"
+
+	total, cur := b.SearchCount([2]Loc{{2, 1}, {3, 1}})
+	assert.Equal(t, SearchCountLimit+1, total)
+	assert.Equal(t, 4, cur)
+
+	// the matches after the limit are not counted
+	total, cur = b.SearchCount([2]Loc{{0, SearchCountLimit - 1}, {1, SearchCountLimit - 1}})
+	assert.Equal(t, SearchCountLimit+1, total)
+	assert.Equal(t, 0, cur)
+
+	b.Remove(Loc{0, 0}, Loc{0, SearchCountLimit / 2})
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, SearchCountLimit+1, total)
+	b.Remove(Loc{0, 0}, Loc{0, SearchCountLimit / 2})
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 2, total)
+}
+
+func TestReplacements(t *testing.T) {
+	b := NewBufferFromString("foo1 bar\nfoo2 foo3", "", BTDefault)
+	r := regexp.MustCompile(`foo(\d)`)
+
+	matches := b.FindReplacements(b.Start(), b.End(), r, []byte("x$1"), true)
+	assert.Len(t, matches, 3)
+	assert.Equal(t, Loc{5, 1}, matches[2].Start)
+	assert.Equal(t, "foo3", string(matches[2].Old))
+	assert.Equal(t, "x3", string(matches[2].New))
+
+	// skip the second match
+	assert.NoError(t, b.ApplyReplacements([]ReplaceMatch{matches[0], matches[2]}))
+	assert.Equal(t, "x1 bar\nfoo2 x3", string(b.Bytes()))
+
+	// the whole replacement is undone at once
+	b.Undo()
+	assert.Equal(t, "foo1 bar\nfoo2 foo3", string(b.Bytes()))
+
+	b.Insert(Loc{0, 0}, "a")
+	assert.Error(t, b.ApplyReplacements(matches))
+	assert.Equal(t, "afoo1 bar\nfoo2 foo3", string(b.Bytes()))
+}
+
+func TestMultilineSearch(t *testing.T) {
+	b := NewBufferFromString("foo\nbar baz\nfoo\nbarbar", "", BTDefault)
+
+	assert.True(t, multilineRegexp(regexp.MustCompile(`o\nb`)))
+	assert.True(t, multilineRegexp(regexp.MustCompile(`(?s)o.b`)))
+	assert.False(t, multilineRegexp(regexp.MustCompile(`o\s+b`)))
+	assert.False(t, multilineRegexp(regexp.MustCompile(`[^x]`)))
+
+	m, found, err := b.FindNext(`o\nb`, b.Start(), b.End(), Loc{0, 0}, true, true)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{2, 0}, {1, 1}}, m)
+
+	m, found, _ = b.FindNext(`o\nb`, b.Start(), b.End(), Loc{1, 1}, true, true)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{2, 2}, {1, 3}}, m)
+
+	m, found, _ = b.FindNext(`o\nb`, b.Start(), b.End(), Loc{0, 2}, false, true)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{2, 0}, {1, 1}}, m)
+
+	b.LastSearch = `o\nb`
+	b.LastSearchRegex = true
+	total, cur := b.SearchCount([2]Loc{{2, 2}, {1, 3}})
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 2, cur)
+	assert.True(t, b.SearchMatch(Loc{2, 0}))
+	assert.True(t, b.SearchMatch(Loc{0, 1}))
+	assert.False(t, b.SearchMatch(Loc{1, 1}))
+
+	// matches spanning more than twice searchlines lines are not found
+	b.Settings["searchlines"] = float64(1)
+	_, found, _ = b.FindNext(`o\nb`, b.Start(), b.End(), Loc{0, 0}, true, true)
+	assert.True(t, found)
+	_, found, _ = b.FindNext(`o\nbar baz\nf`, b.Start(), b.End(), Loc{0, 0}, true, true)
+	assert.False(t, found)
+	b.Settings["searchlines"] = float64(100)
+
+	n, _ := b.ReplaceRegex(b.Start(), b.End(), regexp.MustCompile(`(?m)o\n(b)`), []byte("o-$1"), true)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "foo-bar baz\nfoo-barbar", string(b.Bytes()))
+
+	// the cached matches of the whole buffer are updated after a change
+	total, _ = b.SearchCount([2]Loc{})
+	assert.Equal(t, 0, total)
+	b.Replace(Loc{3, 0}, Loc{4, 0}, "\n")
+	total, cur = b.SearchCount([2]Loc{{2, 0}, {1, 1}})
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 1, cur)
+}
+
+func TestMultilineSearchMatch(t *testing.T) {
+	b := NewBufferFromString(strings.Repeat("x\n", 10)+"foo\nbar\n"+strings.Repeat("x\n", 10), "", BTDefault)
+	b.LastSearch = `o\nb`
+	b.LastSearchRegex = true
+	b.Settings["searchlines"] = float64(3)
+
+	// the lines are searched in the same window until the text changes
+	for y := 0; y < b.LinesNum(); y++ {
+		assert.Equal(t, y == 10 || y == 11, b.SearchMatch(Loc{0, y}) || b.SearchMatch(Loc{2, y}), y)
+	}
+	b.Insert(Loc{0, 11}, "a")
+	assert.False(t, b.SearchMatch(Loc{2, 10}))
+	assert.False(t, b.SearchMatch(Loc{0, 11}))
+
+	// the neighbouring lines are updated according to the current
+	// searchlines option
+	b.Settings["searchlines"] = float64(2)
+	assert.False(t, b.SearchMatch(Loc{2, 10}))
+	b.Remove(Loc{0, 11}, Loc{1, 11})
+	assert.True(t, b.SearchMatch(Loc{2, 10}))
+	assert.True(t, b.SearchMatch(Loc{0, 11}))
+}
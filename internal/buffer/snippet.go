@@ -0,0 +1,490 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A Snippet is a piece of text which is inserted in place of its name.
+// The body of a snippet can contain the following fields:
+//
+//	$1, ${1}        a tabstop, the cursor goes to the tabstops in order
+//	${1:default}    a tabstop with a placeholder text, which is selected
+//	$0              the final position of the cursor
+//	$NAME, ${NAME}  a variable such as the name of the file or the date
+//	${NAME:default} a variable with a default value if it is not defined
+//
+// A tabstop used several times is mirrored: the occurrences are edited
+// together with multiple cursors. $, } and \ are escaped with a \.
+type Snippet struct {
+	// Name of the snippet, which is typed to insert it
+	Name string
+	// Description of the snippet, optional
+	Description string
+	// Body is the text of the snippet, with its fields
+	Body string
+}
+
+// ParseSnippets parses the snippets of a snippets file, which has the
+// following form:
+//
+//	# comment
+//	snippet name description
+//		body, indented with a tab
+//
+// The description is optional.
+func ParseSnippets(data []byte) map[string]*Snippet {
+	snippets := make(map[string]*Snippet)
+	var cur *Snippet
+	var body []string
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		for len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		cur.Body = strings.Join(body, "\n")
+		snippets[cur.Name] = cur
+		cur, body = nil, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, "\t") && cur != nil:
+			body = append(body, line[1:])
+		case line == "" && cur != nil:
+			body = append(body, "")
+		case strings.HasPrefix(line, "snippet "):
+			finish()
+			fields := strings.SplitN(strings.TrimSpace(line[len("snippet "):]), " ", 2)
+			if fields[0] == "" {
+				continue
+			}
+			cur = &Snippet{Name: fields[0]}
+			if len(fields) == 2 {
+				cur.Description = strings.TrimSpace(fields[1])
+			}
+		default:
+			finish()
+		}
+	}
+	finish()
+	return snippets
+}
+
+// Snippets returns the snippets of the filetype of the buffer, found in
+// the snippets runtime files named after the filetype. The snippets of the
+// user take precedence over the default ones, which take precedence over
+// the snippets of plugins.
+func (b *Buffer) Snippets() map[string]*Snippet {
+	ft := b.Settings["filetype"].(string)
+	snippets := make(map[string]*Snippet)
+	for _, f := range config.ListRuntimeFiles(config.RTSnippet) {
+		if f.Name() != ft {
+			continue
+		}
+		data, err := f.Data()
+		if err != nil {
+			continue
+		}
+		for name, s := range ParseSnippets(data) {
+			if _, ok := snippets[name]; !ok {
+				snippets[name] = s
+			}
+		}
+	}
+	return snippets
+}
+
+// snippetVariable returns the value of a variable of a snippet
+func (b *Buffer) snippetVariable(name string) (string, bool) {
+	now := time.Now()
+	switch name {
+	case "FILENAME":
+		return filepath.Base(b.Path), b.Path != ""
+	case "FILENAME_BASE":
+		base := filepath.Base(b.Path)
+		return strings.TrimSuffix(base, filepath.Ext(base)), b.Path != ""
+	case "FILEPATH":
+		return b.AbsPath, b.AbsPath != ""
+	case "DIRECTORY":
+		return filepath.Dir(b.AbsPath), b.AbsPath != ""
+	case "DATE":
+		return now.Format("2006-01-02"), true
+	case "TIME":
+		return now.Format("15:04:05"), true
+	case "YEAR":
+		return now.Format("2006"), true
+	case "MONTH":
+		return now.Format("01"), true
+	case "DAY":
+		return now.Format("02"), true
+	}
+	return "", false
+}
+
+// snippetParser expands the body of a snippet
+type snippetParser struct {
+	src  []rune
+	pos  int
+	vars func(name string) (string, bool)
+
+	out strings.Builder
+	// loc is the location of the end of the text, relative to the start
+	// of the snippet (X is only relative on the first line)
+	loc Loc
+	// ranges are the ranges of the tabstops
+	ranges map[int][][2]Loc
+	// defaults are the placeholders of the tabstops, used for the
+	// occurrences of the tabstops without placeholder
+	defaults map[int]string
+}
+
+func (p *snippetParser) write(s string) {
+	p.out.WriteString(s)
+	for _, r := range s {
+		if r == '\n' {
+			p.loc.X = 0
+			p.loc.Y++
+		} else {
+			p.loc.X++
+		}
+	}
+}
+
+// parse expands the body until its end, or until the closing brace of a
+// field if nested is true
+func (p *snippetParser) parse(nested bool) {
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		switch {
+		case r == '\\' && p.pos+1 < len(p.src) && strings.ContainsRune(`\$}`, p.src[p.pos+1]):
+			p.write(string(p.src[p.pos+1]))
+			p.pos += 2
+		case r == '}' && nested:
+			return
+		case r == '$' && p.field():
+		default:
+			p.write(string(r))
+			p.pos++
+		}
+	}
+}
+
+func isSnippetDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isSnippetVarChar(r rune) bool {
+	return r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || isSnippetDigit(r)
+}
+
+// scan returns the characters from the current position matching f
+func (p *snippetParser) scan(f func(rune) bool) string {
+	start := p.pos
+	for p.pos < len(p.src) && f(p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// skip skips the placeholder of a field until its closing brace
+func (p *snippetParser) skip() {
+	depth := 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+	}
+}
+
+// field expands the field starting at the current position and returns
+// true, or returns false if there is no valid field
+func (p *snippetParser) field() bool {
+	start := p.pos
+	p.pos++
+	braces := p.pos < len(p.src) && p.src[p.pos] == '{'
+	if braces {
+		p.pos++
+	}
+
+	var name string
+	num := p.scan(isSnippetDigit)
+	if num == "" {
+		name = p.scan(isSnippetVarChar)
+		if name == "" {
+			p.pos = start
+			return false
+		}
+	}
+
+	hasDefault := false
+	if braces {
+		if p.pos < len(p.src) && p.src[p.pos] == ':' {
+			hasDefault = true
+			p.pos++
+		} else if p.pos >= len(p.src) || p.src[p.pos] != '}' {
+			p.pos = start
+			return false
+		}
+	}
+
+	if name != "" {
+		value, ok := p.vars(name)
+		switch {
+		case ok:
+			p.write(value)
+			if hasDefault {
+				p.skip()
+			}
+		case hasDefault:
+			p.parse(true)
+		}
+	} else {
+		n := 0
+		for _, r := range num {
+			n = n*10 + int(r-'0')
+		}
+		from, offset := p.loc, p.out.Len()
+		if hasDefault {
+			p.parse(true)
+			if _, ok := p.defaults[n]; !ok && p.out.Len() > offset {
+				p.defaults[n] = p.out.String()[offset:]
+			}
+		} else {
+			p.write(p.defaults[n])
+		}
+		p.ranges[n] = append(p.ranges[n], [2]Loc{from, p.loc})
+	}
+
+	// skip the closing brace, which is missing only at the end of an
+	// unterminated placeholder
+	if braces && p.pos < len(p.src) {
+		p.pos++
+	}
+	return true
+}
+
+// A snippetStop is a tabstop of a snippet being filled in
+type snippetStop struct {
+	// ranges are the ranges of the occurrences of the tabstop
+	ranges [][2]Loc
+}
+
+// activeSnippet is a snippet inserted in a buffer whose tabstops are being
+// filled in
+type activeSnippet struct {
+	start, end Loc
+	// stops are the tabstops in the order they are visited, the last one
+	// is the final position of the cursor
+	stops []*snippetStop
+	cur   int
+}
+
+// move moves the locations of the snippet with the given function, which
+// is called for each change of the text
+func (s *activeSnippet) move(f func(loc Loc, stay bool) Loc) {
+	s.start = f(s.start, true)
+	s.end = f(s.end, false)
+	for _, stop := range s.stops {
+		for i, r := range stop.ranges {
+			stop.ranges[i] = [2]Loc{f(r[0], true), f(r[1], false)}
+		}
+	}
+}
+
+// expandSnippet returns the text of a snippet inserted at loc, and the
+// tabstops of the snippet in the order they are visited
+func (b *Buffer) expandSnippet(s *Snippet, loc Loc) (string, []*snippetStop) {
+	// indent the lines like the line of the snippet
+	indent := string(util.GetLeadingWhitespace(b.LineBytes(loc.Y)))
+	tab := b.IndentString(util.IntOpt(b.Settings["tabsize"]))
+	lines := strings.Split(s.Body, "\n")
+	for i, l := range lines {
+		tabs := len(l) - len(strings.TrimLeft(l, "\t"))
+		l = strings.Repeat(tab, tabs) + l[tabs:]
+		if i > 0 {
+			l = indent + l
+		}
+		lines[i] = l
+	}
+
+	p := &snippetParser{
+		src:      []rune(strings.Join(lines, "\n")),
+		vars:     b.snippetVariable,
+		ranges:   make(map[int][][2]Loc),
+		defaults: make(map[int]string),
+	}
+	// the first pass finds the placeholders of the mirrored tabstops
+	p.parse(false)
+	p.pos, p.loc = 0, Loc{0, 0}
+	p.out.Reset()
+	p.ranges = make(map[int][][2]Loc)
+	p.parse(false)
+
+	abs := func(l Loc) Loc {
+		if l.Y == 0 {
+			l.X += loc.X
+		}
+		l.Y += loc.Y
+		return l
+	}
+	var nums []int
+	for n := range p.ranges {
+		if n != 0 {
+			nums = append(nums, n)
+		}
+	}
+	sort.Ints(nums)
+	nums = append(nums, 0)
+
+	var stops []*snippetStop
+	for _, n := range nums {
+		ranges, ok := p.ranges[n]
+		if !ok {
+			// without $0, the cursor ends at the end of the snippet
+			ranges = [][2]Loc{{p.loc, p.loc}}
+		}
+		stop := &snippetStop{}
+		for _, r := range ranges {
+			stop.ranges = append(stop.ranges, [2]Loc{abs(r[0]), abs(r[1])})
+		}
+		stops = append(stops, stop)
+	}
+	return p.out.String(), stops
+}
+
+// InsertSnippet replaces the text between start and end with the given
+// snippet, and selects its first tabstop
+func (b *Buffer) InsertSnippet(s *Snippet, start, end Loc) {
+	b.ExitSnippet()
+	b.ClearCursors()
+	text, stops := b.expandSnippet(s, start)
+	b.Replace(start, end, text)
+	b.EventHandler.snippet = &activeSnippet{
+		start: start,
+		end:   start.MoveLA(util.CharacterCountInString(text), b.LineArray),
+		stops: stops,
+	}
+	b.gotoSnippetStop(0)
+}
+
+// ExpandSnippet inserts the snippet named by the word before the cursor,
+// and returns false if there is no such snippet
+func (b *Buffer) ExpandSnippet() bool {
+	word, start, ok := b.completionWord()
+	if !ok || word == "" {
+		return false
+	}
+	s, ok := b.Snippets()[word]
+	if !ok {
+		return false
+	}
+	b.InsertSnippet(s, start, b.GetActiveCursor().Loc)
+	return true
+}
+
+// gotoSnippetStop moves the cursors to the occurrences of the i-th
+// tabstop of the active snippet, selecting their placeholders. The snippet
+// is finished when the cursor goes to its final position.
+func (b *Buffer) gotoSnippetStop(i int) {
+	s := b.EventHandler.snippet
+	s.cur = i
+	b.ClearCursors()
+	for j, r := range s.stops[i].ranges {
+		c := b.GetActiveCursor()
+		if j > 0 {
+			c = NewCursor(b, r[1])
+			b.AddCursor(c)
+		}
+		c.GotoLoc(r[1])
+		if r[0] != r[1] {
+			c.SetSelectionStart(r[0])
+			c.SetSelectionEnd(r[1])
+		}
+	}
+	if i == len(s.stops)-1 {
+		b.ExitSnippet()
+	}
+}
+
+// inSnippet returns whether the cursor is inside the active snippet
+func (b *Buffer) inSnippet() bool {
+	s := b.EventHandler.snippet
+	c := b.GetActiveCursor()
+	return s != nil && c.Loc.GreaterEqual(s.start) && c.Loc.LessEqual(s.end)
+}
+
+// NextSnippetStop goes to the next tabstop of the snippet being filled in.
+// It returns false if there is no such snippet, or if the cursor has left
+// it, which finishes the snippet.
+func (b *Buffer) NextSnippetStop() bool {
+	if b.EventHandler.snippet == nil {
+		return false
+	}
+	if !b.inSnippet() {
+		b.ExitSnippet()
+		return false
+	}
+	b.gotoSnippetStop(b.EventHandler.snippet.cur + 1)
+	return true
+}
+
+// PrevSnippetStop goes to the previous tabstop of the snippet being
+// filled in, and returns false if there is none
+func (b *Buffer) PrevSnippetStop() bool {
+	s := b.EventHandler.snippet
+	if s == nil || s.cur == 0 {
+		return false
+	}
+	if !b.inSnippet() {
+		b.ExitSnippet()
+		return false
+	}
+	b.gotoSnippetStop(s.cur - 1)
+	return true
+}
+
+// ExitSnippet finishes the snippet being filled in, if any
+func (b *Buffer) ExitSnippet() {
+	b.EventHandler.snippet = nil
+}
+
+// InSnippet returns whether a snippet is being filled in
+func (b *Buffer) InSnippet() bool {
+	return b.EventHandler.snippet != nil
+}
+
+// snippetsSource suggests the snippets of the filetype of the buffer
+func snippetsSource(b *Buffer, word string) []Suggestion {
+	if word == "" {
+		return nil
+	}
+	var suggestions []Suggestion
+	for _, s := range b.Snippets() {
+		detail := "snippet"
+		if s.Description != "" {
+			detail = s.Description
+		}
+		suggestions = append(suggestions, Suggestion{Text: s.Name, Detail: detail, Line: -1, Snippet: s})
+	}
+	return suggestions
+}
@@ -0,0 +1,114 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnippets(t *testing.T) {
+	snippets := ParseSnippets([]byte("# comment\n" +
+		"snippet if if statement\n" +
+		"\tif $1 {\n" +
+		"\n" +
+		"\t\t$0\n" +
+		"\t}\n" +
+		"\n" +
+		"snippet x\n" +
+		"\tx\n"))
+	assert.Len(t, snippets, 2)
+	assert.Equal(t, "if statement", snippets["if"].Description)
+	assert.Equal(t, "if $1 {\n\n\t$0\n}", snippets["if"].Body)
+	assert.Equal(t, "", snippets["x"].Description)
+	assert.Equal(t, "x", snippets["x"].Body)
+}
+
+func TestExpandSnippet(t *testing.T) {
+	b := NewBufferFromString("", "/tmp/file.go", BTDefault)
+	defer b.Close()
+
+	text, stops := b.expandSnippet(&Snippet{
+		Body: `${1:a} \$1 ${2:b${3:c}} $1 ${FILENAME} ${FOO:$YEAR} $$ ${1`,
+	}, Loc{2, 3})
+	year := time.Now().Format("2006")
+	// an unterminated field is inserted as is
+	assert.Equal(t, "a $1 bc a file.go "+year+" $$ ${1", text)
+	assert.Len(t, stops, 4)
+	// a tabstop used several times is mirrored
+	assert.Equal(t, [][2]Loc{{{2, 3}, {3, 3}}, {{10, 3}, {11, 3}}}, stops[0].ranges)
+	assert.Equal(t, [][2]Loc{{{7, 3}, {9, 3}}}, stops[1].ranges)
+	assert.Equal(t, [][2]Loc{{{8, 3}, {9, 3}}}, stops[2].ranges)
+	// without $0, the cursor ends at the end of the snippet
+	assert.Equal(t, [][2]Loc{{{31, 3}, {31, 3}}}, stops[3].ranges)
+}
+
+func TestInsertSnippet(t *testing.T) {
+	b := NewBufferFromString("\tfoo", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabstospaces", false)
+	b.GetActiveCursor().GotoLoc(b.End())
+
+	b.InsertSnippet(&Snippet{Body: "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t$0\n}"}, Loc{1, 0}, b.End())
+	assert.Equal(t, "\tfor i := 0; i < n; i++ {\n\t\t\n\t}", string(b.Bytes()))
+	assert.True(t, b.InSnippet())
+
+	// the occurrences of the first tabstop are selected with multiple cursors
+	assert.Equal(t, 3, b.NumCursors())
+	c := b.GetActiveCursor()
+	assert.Equal(t, [2]Loc{{5, 0}, {6, 0}}, c.CurSelection)
+
+	// edit the mirrored tabstop like the action of a key with multiple
+	// cursors
+	for _, c := range b.GetCursors() {
+		b.SetCurCursor(c.Num)
+		c.DeleteSelection()
+		c.ResetSelection()
+		b.Insert(c.Loc, "idx")
+	}
+	b.SetCurCursor(0)
+	assert.Equal(t, "\tfor idx := 0; idx < n; idx++ {\n\t\t\n\t}", string(b.Bytes()))
+
+	assert.True(t, b.NextSnippetStop())
+	assert.Equal(t, 1, b.NumCursors())
+	assert.Equal(t, "n", string(b.GetActiveCursor().GetSelection()))
+	assert.True(t, b.PrevSnippetStop())
+	assert.Equal(t, "idx", string(b.GetActiveCursor().GetSelection()))
+	assert.True(t, b.NextSnippetStop())
+
+	// the snippet is finished at the final position
+	assert.True(t, b.NextSnippetStop())
+	assert.Equal(t, Loc{2, 1}, b.GetActiveCursor().Loc)
+	assert.False(t, b.InSnippet())
+	assert.False(t, b.NextSnippetStop())
+}
+
+func TestSnippetLeft(t *testing.T) {
+	b := NewBufferFromString("x\n", "", BTDefault)
+	defer b.Close()
+
+	b.InsertSnippet(&Snippet{Body: "f($1, $2)"}, Loc{0, 0}, Loc{1, 0})
+	assert.Equal(t, "f(, )\n", string(b.Bytes()))
+	assert.Equal(t, Loc{2, 0}, b.GetActiveCursor().Loc)
+
+	// the snippet is finished when the cursor has left it
+	b.GetActiveCursor().GotoLoc(Loc{0, 1})
+	assert.False(t, b.NextSnippetStop())
+	assert.False(t, b.InSnippet())
+}
+
+func TestSnippetStopRemoved(t *testing.T) {
+	b := NewBufferFromString("", "", BTDefault)
+	defer b.Close()
+
+	b.InsertSnippet(&Snippet{Body: "a$1\nb$2\nc\nd$3"}, Loc{0, 0}, Loc{0, 0})
+	assert.Equal(t, "a\nb\nc\nd", string(b.Bytes()))
+
+	// the tabstops in the removed text move to its start
+	b.Remove(Loc{1, 0}, Loc{0, 3})
+	assert.Equal(t, "ad", string(b.Bytes()))
+	assert.True(t, b.NextSnippetStop())
+	assert.Equal(t, Loc{1, 0}, b.GetActiveCursor().Loc)
+	assert.True(t, b.NextSnippetStop())
+	assert.Equal(t, Loc{2, 0}, b.GetActiveCursor().Loc)
+}
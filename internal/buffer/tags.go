@@ -0,0 +1,289 @@
+package buffer
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A Tag is an entry of a ctags file
+type Tag struct {
+	// Name of the symbol
+	Name string
+	// Path of the file where the symbol is defined
+	Path string
+	// Line is the line number of the definition (starting at 1), or 0 if
+	// the definition is located with a pattern
+	Line int
+	// Pattern is the text of the line of the definition
+	Pattern string
+	// Kind is the kind of the symbol, such as "f" or "function"
+	Kind string
+
+	// whether the pattern is anchored at the start or at the end of the line
+	startAnchor, endAnchor bool
+}
+
+// A TagIndex is the contents of a tags file
+type TagIndex struct {
+	// Path of the tags file
+	Path string
+	// Names are the names of the tags, sorted
+	Names []string
+
+	tags    map[string][]Tag
+	modTime time.Time
+}
+
+// ErrNoTagsFile is returned when no tags file is found
+var ErrNoTagsFile = errors.New("No tags file found")
+
+var (
+	tagIndexes     = make(map[string]*TagIndex)
+	tagIndexesLock sync.Mutex
+)
+
+// FindTagsFile returns the path of the first file named tags found in dir
+// or one of its parents, or an empty string if there is none
+func FindTagsFile(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, "tags")
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadTags returns the index of the tags file at the given path. The
+// index is cached and read again when the file is modified.
+func LoadTags(path string) (*TagIndex, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tagIndexesLock.Lock()
+	defer tagIndexesLock.Unlock()
+
+	if t, ok := tagIndexes[path]; ok && t.modTime.Equal(info.ModTime()) {
+		return t, nil
+	}
+	t, err := readTags(path)
+	if err != nil {
+		return nil, err
+	}
+	t.modTime = info.ModTime()
+	tagIndexes[path] = t
+	return t, nil
+}
+
+func readTags(path string) (*TagIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &TagIndex{
+		Path: path,
+		tags: make(map[string][]Tag),
+	}
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		tag, ok := parseTag(scanner.Text())
+		if !ok {
+			continue
+		}
+		if !filepath.IsAbs(tag.Path) {
+			tag.Path = filepath.Join(dir, tag.Path)
+		}
+		if _, ok := t.tags[tag.Name]; !ok {
+			t.Names = append(t.Names, tag.Name)
+		}
+		t.tags[tag.Name] = append(t.tags[tag.Name], tag)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(t.Names)
+	return t, nil
+}
+
+// parseTag parses a line of a tags file, which has the form
+//
+//	name<TAB>file<TAB>address;"<TAB>fields
+//
+// where address is a line number or a /pattern/ or ?pattern? search.
+func parseTag(line string) (Tag, bool) {
+	if strings.HasPrefix(line, "!_TAG_") {
+		return Tag{}, false
+	}
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" {
+		return Tag{}, false
+	}
+	tag := Tag{Name: parts[0], Path: parts[1]}
+
+	address, fields := parts[2], ""
+	if len(address) > 0 && (address[0] == '/' || address[0] == '?') {
+		// the pattern ends at the first unescaped delimiter
+		delim := address[0]
+		end := -1
+		for i := 1; i < len(address); i++ {
+			if address[i] == '\\' {
+				i++
+			} else if address[i] == delim {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return Tag{}, false
+		}
+		tag.setPattern(address[1:end], delim)
+		fields = address[end+1:]
+	} else {
+		i := strings.IndexFunc(address, func(r rune) bool { return r < '0' || r > '9' })
+		if i < 0 {
+			i = len(address)
+		}
+		n, err := strconv.Atoi(address[:i])
+		if err != nil {
+			return Tag{}, false
+		}
+		tag.Line = n
+		fields = address[i:]
+	}
+
+	if !strings.HasPrefix(fields, ";\"") {
+		return tag, true
+	}
+	for _, field := range strings.Split(fields[2:], "\t") {
+		if field == "" {
+			continue
+		}
+		key, value, found := strings.Cut(field, ":")
+		if !found {
+			// a field without a key is the kind
+			tag.Kind = field
+		} else if key == "kind" {
+			tag.Kind = value
+		} else if key == "line" {
+			if n, err := strconv.Atoi(value); err == nil {
+				tag.Line = n
+			}
+		}
+	}
+	return tag, true
+}
+
+// setPattern sets the pattern of the tag from a search pattern of a tags
+// file, in which only the anchors and the escaped characters are special
+func (t *Tag) setPattern(pattern string, delim byte) {
+	if strings.HasPrefix(pattern, "^") {
+		t.startAnchor = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, "\\$") {
+		t.endAnchor = true
+		pattern = pattern[:len(pattern)-1]
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) &&
+			(pattern[i+1] == '\\' || pattern[i+1] == delim || pattern[i+1] == '$') {
+			i++
+		}
+		b.WriteByte(pattern[i])
+	}
+	t.Pattern = b.String()
+}
+
+// Lookup returns the tags with the given name
+func (t *TagIndex) Lookup(name string) []Tag {
+	return t.tags[name]
+}
+
+// Tags returns the index of the tags file found in the directory of the
+// buffer or one of its parents, or in the working directory if the buffer
+// has no file
+func (b *Buffer) Tags() (*TagIndex, error) {
+	dir := "."
+	if b.AbsPath != "" {
+		dir = filepath.Dir(b.AbsPath)
+	}
+	path := FindTagsFile(dir)
+	if path == "" {
+		return nil, ErrNoTagsFile
+	}
+	return LoadTags(path)
+}
+
+// matches returns whether the line matches the pattern of the tag
+func (t *Tag) matches(line string) bool {
+	switch {
+	case t.startAnchor && t.endAnchor:
+		return line == t.Pattern
+	case t.startAnchor:
+		return strings.HasPrefix(line, t.Pattern)
+	case t.endAnchor:
+		return strings.HasSuffix(line, t.Pattern)
+	}
+	return strings.Contains(line, t.Pattern)
+}
+
+// Locate returns the location of the tag in the given buffer, which holds
+// the file of the tag. If the pattern of the tag is found on several lines,
+// the closest one to the line number of the tag is chosen. The line number
+// is used when there is no pattern or the pattern is not found.
+func (t *Tag) Locate(b *Buffer) Loc {
+	hint := t.Line - 1
+	if hint < 0 {
+		hint = 0
+	}
+	if t.Pattern != "" {
+		found, dist := -1, 0
+		for y := 0; y < b.LinesNum(); y++ {
+			if !t.matches(b.Line(y)) {
+				continue
+			}
+			d := y - hint
+			if d < 0 {
+				d = -d
+			}
+			if found < 0 || d < dist {
+				found, dist = y, d
+			}
+		}
+		if found >= 0 {
+			x := 0
+			if i := strings.Index(b.Line(found), t.Name); i >= 0 {
+				x = util.CharacterCountInString(b.Line(found)[:i])
+			}
+			return Loc{x, found}
+		}
+	}
+	if hint >= b.LinesNum() {
+		hint = b.LinesNum() - 1
+	}
+	return Loc{0, hint}
+}
@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTag(t *testing.T) {
+	tag, ok := parseTag("main\tmain.go\t/^func main() {$/;\"\tf\tline:12")
+	assert.True(t, ok)
+	assert.Equal(t, "main", tag.Name)
+	assert.Equal(t, "main.go", tag.Path)
+	assert.Equal(t, "func main() {", tag.Pattern)
+	assert.Equal(t, 12, tag.Line)
+	assert.Equal(t, "f", tag.Kind)
+	assert.True(t, tag.startAnchor)
+	assert.True(t, tag.endAnchor)
+
+	tag, ok = parseTag(`path	a.c	/^char *path = "a\/b\\c";$/;"	kind:variable`)
+	assert.True(t, ok)
+	assert.Equal(t, `char *path = "a/b\c";`, tag.Pattern)
+	assert.Equal(t, "variable", tag.Kind)
+
+	tag, ok = parseTag("Foo\tfoo.py\t42")
+	assert.True(t, ok)
+	assert.Equal(t, 42, tag.Line)
+	assert.Equal(t, "", tag.Pattern)
+
+	_, ok = parseTag("!_TAG_FILE_FORMAT\t2\t/extended format/")
+	assert.False(t, ok)
+	_, ok = parseTag("broken\tfile")
+	assert.False(t, ok)
+}
+
+func TestTags(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	tagsPath := filepath.Join(dir, "tags")
+	os.WriteFile(tagsPath, []byte("!_TAG_FILE_SORTED\t1\t//\n"+
+		"foo\tsub/a.go\t/^func foo() {$/;\"\tf\n"+
+		"bar\tsub/a.go\t3;\"\tv\n"), 0644)
+	source := filepath.Join(sub, "a.go")
+	os.WriteFile(source, []byte("package a\n\nvar bar = 1\n\nfunc foo() {\n}\n"), 0644)
+
+	b, err := NewBufferFromFile(source, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+
+	index, err := b.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, tagsPath, index.Path)
+	assert.Equal(t, []string{"bar", "foo"}, index.Names)
+
+	tags := index.Lookup("foo")
+	assert.Len(t, tags, 1)
+	assert.Equal(t, source, tags[0].Path)
+	assert.Equal(t, Loc{5, 4}, tags[0].Locate(b))
+	assert.Equal(t, Loc{0, 2}, index.Lookup("bar")[0].Locate(b))
+	assert.Empty(t, index.Lookup("baz"))
+
+	// the index is read again when the file changes
+	os.WriteFile(tagsPath, []byte("baz\tsub/a.go\t1\n"), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(tagsPath, later, later)
+	index, err = b.Tags()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"baz"}, index.Names)
+
+	assert.Equal(t, "", FindTagsFile(t.TempDir()))
+}
@@ -0,0 +1,106 @@
+package buffer
+
+// VirtualTextKind is the position of a virtual text relative to its location
+type VirtualTextKind int
+
+const (
+	// VTInline is displayed before the character at its location, or at the
+	// end of the line if the location is at the end of the line
+	VTInline VirtualTextKind = iota
+	// VTEndOfLine is displayed after the end of the line of its location
+	VTEndOfLine
+)
+
+// A VirtualText is a text displayed in a buffer which is not part of its
+// content, such as a type hint or a diagnostic. The cursor skips over it,
+// and it moves with the modifications of the text.
+type VirtualText struct {
+	// Namespace is the name used to clear the virtual texts of a plugin
+	// together
+	Namespace string
+	Loc       Loc
+	Text      string
+	// Group is the colorscheme group of the style of the text
+	Group string
+	Kind  VirtualTextKind
+}
+
+// AddVirtualText displays text at loc with the style of the given
+// colorscheme group, and returns the virtual text so that it can be updated
+// or removed
+func (b *SharedBuffer) AddVirtualText(namespace string, loc Loc, text, group string, kind VirtualTextKind) *VirtualText {
+	vt := &VirtualText{
+		Namespace: namespace,
+		Loc:       clamp(loc, b.LineArray),
+		Text:      text,
+		Group:     group,
+		Kind:      kind,
+	}
+	b.virtualTexts = append(b.virtualTexts, vt)
+	return vt
+}
+
+// UpdateVirtualText changes the location, the text and the group of a
+// virtual text
+func (b *SharedBuffer) UpdateVirtualText(vt *VirtualText, loc Loc, text, group string) {
+	vt.Loc = clamp(loc, b.LineArray)
+	vt.Text = text
+	vt.Group = group
+}
+
+// RemoveVirtualText removes a virtual text from the buffer
+func (b *SharedBuffer) RemoveVirtualText(vt *VirtualText) {
+	for i, v := range b.virtualTexts {
+		if v == vt {
+			b.virtualTexts = append(b.virtualTexts[:i], b.virtualTexts[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearVirtualTexts removes all the virtual texts in the given namespace
+func (b *SharedBuffer) ClearVirtualTexts(namespace string) {
+	virtualTexts := b.virtualTexts[:0]
+	for _, vt := range b.virtualTexts {
+		if vt.Namespace != namespace {
+			virtualTexts = append(virtualTexts, vt)
+		}
+	}
+	for i := len(virtualTexts); i < len(b.virtualTexts); i++ {
+		b.virtualTexts[i] = nil
+	}
+	b.virtualTexts = virtualTexts
+}
+
+// VirtualTexts returns the virtual texts in the given namespace, in the
+// order they were added
+func (b *SharedBuffer) VirtualTexts(namespace string) []*VirtualText {
+	var virtualTexts []*VirtualText
+	for _, vt := range b.virtualTexts {
+		if vt.Namespace == namespace {
+			virtualTexts = append(virtualTexts, vt)
+		}
+	}
+	return virtualTexts
+}
+
+// VirtualTextsInLine returns the virtual texts of line y, in the order they
+// are displayed when they are at the same location
+func (b *SharedBuffer) VirtualTextsInLine(y int) []*VirtualText {
+	var virtualTexts []*VirtualText
+	for _, vt := range b.virtualTexts {
+		if vt.Loc.Y == y {
+			virtualTexts = append(virtualTexts, vt)
+		}
+	}
+	return virtualTexts
+}
+
+// moveVirtualTexts moves the virtual texts with the given function after a
+// modification of the text. A virtual text moves after the text inserted at
+// its location.
+func (b *SharedBuffer) moveVirtualTexts(move func(loc Loc, stay bool) Loc) {
+	for _, vt := range b.virtualTexts {
+		vt.Loc = move(vt.Loc, false)
+	}
+}
@@ -0,0 +1,40 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualTexts(t *testing.T) {
+	b := NewBufferFromString("foo bar\nbaz", "", BTDefault)
+	defer b.Close()
+
+	hint := b.AddVirtualText("test", Loc{4, 0}, ": int", "comment", VTInline)
+	eol := b.AddVirtualText("other", Loc{10, 1}, "error", "error", VTEndOfLine)
+	assert.Equal(t, Loc{3, 1}, eol.Loc)
+	assert.Equal(t, []*VirtualText{hint}, b.VirtualTextsInLine(0))
+	assert.Equal(t, []*VirtualText{eol}, b.VirtualTexts("other"))
+
+	// the text inserted at the location of a virtual text is before it
+	b.Insert(Loc{4, 0}, "big ")
+	assert.Equal(t, Loc{8, 0}, hint.Loc)
+	b.Insert(Loc{0, 0}, "\n")
+	assert.Equal(t, Loc{8, 1}, hint.Loc)
+	assert.Equal(t, Loc{3, 2}, eol.Loc)
+	assert.Equal(t, "foo big bar", string(b.LineBytes(1)))
+
+	// removing the text around a virtual text moves it to the start
+	b.Remove(Loc{2, 1}, Loc{10, 1})
+	assert.Equal(t, Loc{2, 1}, hint.Loc)
+
+	b.UpdateVirtualText(hint, Loc{0, 2}, "x", "todo")
+	assert.Equal(t, Loc{0, 2}, hint.Loc)
+	assert.Equal(t, "x", hint.Text)
+	assert.Equal(t, []*VirtualText{hint, eol}, b.VirtualTextsInLine(2))
+
+	b.ClearVirtualTexts("test")
+	assert.Empty(t, b.VirtualTexts("test"))
+	b.RemoveVirtualText(eol)
+	assert.Empty(t, b.VirtualTextsInLine(2))
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"errors"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/micro-editor/tcell/v2"
 )
@@ -47,27 +50,183 @@ func ColorschemeExists(colorschemeName string) bool {
 	return FindRuntimeFile(RTColorscheme, colorschemeName) != nil
 }
 
+// colorschemePollInterval is the interval between the checks of the
+// modification of the files of the current colorscheme
+const colorschemePollInterval = time.Second
+
+// ColorschemeModified receives a value when a file of the current
+// colorscheme has been modified
+var ColorschemeModified chan bool
+
+var (
+	colorschemeLock sync.Mutex
+	// colorschemeName is the name of the current colorscheme
+	colorschemeName string
+	// colorschemeFiles are the modification times of the files of the
+	// current colorscheme and of the colorschemes it includes
+	colorschemeFiles map[string]time.Time
+)
+
+var (
+	// colorschemeVariants are the colorschemes for a light and a dark
+	// terminal background, when the colorscheme option is an object such
+	// as {"light": "dukelight-tc", "dark": "one-dark"}
+	colorschemeVariants map[string]string
+	// termBackground is the background of the terminal, "light" or "dark",
+	// or empty if it is unknown
+	termBackground string
+)
+
+func init() {
+	ColorschemeModified = make(chan bool)
+}
+
+// ColorschemeFollowsBackground returns true if the colorscheme is chosen
+// according to the background of the terminal
+func ColorschemeFollowsBackground() bool {
+	return colorschemeVariants != nil
+}
+
+// ResolveColorschemeOption returns the colorscheme given by the value of the
+// colorscheme option in settings.json. If the value is an object giving a
+// colorscheme for each background of the terminal, the colorscheme is
+// chosen according to the background from now on.
+func ResolveColorschemeOption(value any) any {
+	variants, ok := value.(map[string]any)
+	if !ok {
+		colorschemeVariants = nil
+		return value
+	}
+
+	colorschemeVariants = make(map[string]string)
+	for background, name := range variants {
+		colorschemeVariants[background] = name.(string)
+	}
+	name, _ := backgroundColorscheme()
+	return name
+}
+
+// ClearColorschemeVariants stops choosing the colorscheme according to the
+// background of the terminal, when the colorscheme is set explicitly
+func ClearColorschemeVariants() {
+	colorschemeVariants = nil
+}
+
+// backgroundColorscheme returns the colorscheme for the background of the
+// terminal. The dark colorscheme is used when the background is unknown.
+func backgroundColorscheme() (string, bool) {
+	for _, background := range []string{termBackground, "dark", "light"} {
+		if name, ok := colorschemeVariants[background]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// SetTermBackground records the background of the terminal, "light" or
+// "dark", and switches to the colorscheme for this background if the
+// colorscheme depends on it
+func SetTermBackground(background string) error {
+	termBackground = background
+
+	name, ok := backgroundColorscheme()
+	if !ok || name == GlobalSettings["colorscheme"] {
+		return nil
+	}
+	GlobalSettings["colorscheme"] = name
+	if Colorscheme == nil {
+		// InitColorscheme has not been called yet, and the colorscheme
+		// may be added by a plugin
+		return nil
+	}
+	return SetColorscheme(name)
+}
+
 // InitColorscheme picks and initializes the colorscheme when micro starts
 func InitColorscheme() error {
 	Colorscheme = make(map[string]tcell.Style)
 	DefStyle = tcell.StyleDefault
 
-	c, err := LoadDefaultColorscheme()
-	if err == nil {
-		Colorscheme = c
-	} else {
+	err := SetColorscheme(GlobalSettings["colorscheme"].(string))
+	if err != nil {
 		// The colorscheme setting seems broken (maybe because we have not validated
 		// it earlier, see comment in verifySetting()). So reset it to the default
 		// colorscheme and try again.
 		GlobalSettings["colorscheme"] = DefaultGlobalOnlySettings["colorscheme"]
-		if c, err2 := LoadDefaultColorscheme(); err2 == nil {
-			Colorscheme = c
-		}
+		SetColorscheme(GlobalSettings["colorscheme"].(string))
 	}
 
 	return err
 }
 
+// SetColorscheme loads the given colorscheme and makes it the current one,
+// without changing the colorscheme option. It is used to preview
+// colorschemes. The current colorscheme is kept if the given one cannot be
+// loaded.
+func SetColorscheme(name string) error {
+	defStyle := DefStyle
+	DefStyle = tcell.StyleDefault
+
+	l := newColorschemeLoader(new([]string))
+	c, err := l.load(name)
+	if err != nil {
+		DefStyle = defStyle
+		return err
+	}
+	Colorscheme = c
+
+	colorschemeLock.Lock()
+	defer colorschemeLock.Unlock()
+	colorschemeName = name
+	colorschemeFiles = l.files
+	return nil
+}
+
+// ReloadColorscheme loads the current colorscheme again, after one of its
+// files has been modified
+func ReloadColorscheme() error {
+	colorschemeLock.Lock()
+	name := colorschemeName
+	// Do not report the same modification again if the colorscheme is
+	// now invalid
+	for path := range colorschemeFiles {
+		if info, err := os.Stat(path); err == nil {
+			colorschemeFiles[path] = info.ModTime()
+		}
+	}
+	colorschemeLock.Unlock()
+
+	return SetColorscheme(name)
+}
+
+// colorschemeModified returns true if one of the files of the current
+// colorscheme has been modified since it was loaded
+func colorschemeModified() bool {
+	colorschemeLock.Lock()
+	defer colorschemeLock.Unlock()
+
+	for path, modTime := range colorschemeFiles {
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartColorschemeWatcher checks periodically the files of the current
+// colorscheme, and sends a value to ColorschemeModified when one of them is
+// modified so that the colorscheme can be reloaded
+func StartColorschemeWatcher() {
+	go func() {
+		ticker := time.NewTicker(colorschemePollInterval)
+		for range ticker.C {
+			if colorschemeModified() {
+				ColorschemeModified <- true
+			}
+		}
+	}()
+}
+
 // LoadDefaultColorscheme loads the default colorscheme from $(ConfigDir)/colorschemes
 func LoadDefaultColorscheme() (map[string]tcell.Style, error) {
 	var parsedColorschemes []string
@@ -76,16 +235,62 @@ func LoadDefaultColorscheme() (map[string]tcell.Style, error) {
 
 // LoadColorscheme loads the given colorscheme from a directory
 func LoadColorscheme(colorschemeName string, parsedColorschemes *[]string) (map[string]tcell.Style, error) {
+	return newColorschemeLoader(parsedColorschemes).load(colorschemeName)
+}
+
+// ParseColorscheme parses the text definition for a colorscheme and returns the corresponding object
+// Colorschemes are made up of color-link statements linking a color group to a list of colors
+// For example, color-link keyword (blue,red) makes all keywords have a blue foreground and
+// red background
+func ParseColorscheme(name string, text string, parsedColorschemes *[]string) (map[string]tcell.Style, error) {
+	return newColorschemeLoader(parsedColorschemes).parse(name, text)
+}
+
+var (
+	colorParser  = regexp.MustCompile(`color-link\s+(\S*)\s+"(.*)"`)
+	defineParser = regexp.MustCompile(`^\s*define\s+(\S+)\s+"(.*)"`)
+	// colorWordParser matches the attributes and colors of a style
+	colorWordParser = regexp.MustCompile(`[^\s,]+`)
+	includeParser   = regexp.MustCompile(`include\s+"(.*)"`)
+)
+
+// A colorschemeLoader loads a colorscheme and the colorschemes it includes
+type colorschemeLoader struct {
+	// parsedColorschemes are the names of the colorschemes already loaded,
+	// to prevent circular includes. Includes are ignored if it is nil.
+	parsedColorschemes *[]string
+	// variables are the names of the colors defined with define
+	// statements, shared with the included colorschemes
+	variables map[string]string
+	// files are the modification times of the loaded files
+	files map[string]time.Time
+}
+
+func newColorschemeLoader(parsedColorschemes *[]string) *colorschemeLoader {
+	return &colorschemeLoader{
+		parsedColorschemes: parsedColorschemes,
+		variables:          make(map[string]string),
+		files:              make(map[string]time.Time),
+	}
+}
+
+// load loads the given colorscheme from the runtime files
+func (l *colorschemeLoader) load(colorschemeName string) (map[string]tcell.Style, error) {
 	c := make(map[string]tcell.Style)
 	file := FindRuntimeFile(RTColorscheme, colorschemeName)
 	if file == nil {
 		return c, errors.New(colorschemeName + " is not a valid colorscheme")
 	}
+	if rf, ok := file.(realFile); ok {
+		if info, err := os.Stat(string(rf)); err == nil {
+			l.files[string(rf)] = info.ModTime()
+		}
+	}
 	if data, err := file.Data(); err != nil {
 		return c, errors.New("Error loading colorscheme: " + err.Error())
 	} else {
 		var err error
-		c, err = ParseColorscheme(file.Name(), string(data), parsedColorschemes)
+		c, err = l.parse(file.Name(), string(data))
 		if err != nil {
 			return c, err
 		}
@@ -93,14 +298,24 @@ func LoadColorscheme(colorschemeName string, parsedColorschemes *[]string) (map[
 	return c, nil
 }
 
-// ParseColorscheme parses the text definition for a colorscheme and returns the corresponding object
-// Colorschemes are made up of color-link statements linking a color group to a list of colors
-// For example, color-link keyword (blue,red) makes all keywords have a blue foreground and
-// red background
-func ParseColorscheme(name string, text string, parsedColorschemes *[]string) (map[string]tcell.Style, error) {
+// expand replaces the variables in the given style string with their
+// colors
+func (l *colorschemeLoader) expand(colors string) string {
+	if len(l.variables) == 0 {
+		return colors
+	}
+	return colorWordParser.ReplaceAllStringFunc(colors, func(word string) string {
+		if color, ok := l.variables[word]; ok {
+			return color
+		}
+		return word
+	})
+}
+
+// parse parses the text definition of a colorscheme
+func (l *colorschemeLoader) parse(name string, text string) (map[string]tcell.Style, error) {
 	var err error
-	colorParser := regexp.MustCompile(`color-link\s+(\S*)\s+"(.*)"`)
-	includeParser := regexp.MustCompile(`include\s+"(.*)"`)
+	parsedColorschemes := l.parsedColorschemes
 	lines := strings.Split(text, "\n")
 	c := make(map[string]tcell.Style)
 
@@ -116,7 +331,13 @@ lineLoop:
 			continue
 		}
 
-		matches := includeParser.FindSubmatch([]byte(line))
+		matches := defineParser.FindSubmatch([]byte(line))
+		if len(matches) == 3 {
+			l.variables[string(matches[1])] = l.expand(string(matches[2]))
+			continue
+		}
+
+		matches = includeParser.FindSubmatch([]byte(line))
 		if len(matches) == 2 {
 			// support includes only in case parsedColorschemes are given
 			if parsedColorschemes != nil {
@@ -128,7 +349,7 @@ lineLoop:
 						continue lineLoop
 					}
 				}
-				includeScheme, err := LoadColorscheme(include, parsedColorschemes)
+				includeScheme, err := l.load(include)
 				if err != nil {
 					return c, err
 				}
@@ -142,7 +363,7 @@ lineLoop:
 		matches = colorParser.FindSubmatch([]byte(line))
 		if len(matches) == 3 {
 			link := string(matches[1])
-			colors := string(matches[2])
+			colors := l.expand(string(matches[2]))
 
 			style := StringToStyle(colors)
 			c[link] = style
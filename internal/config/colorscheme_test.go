@@ -1,7 +1,10 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/micro-editor/tcell/v2"
 	"github.com/stretchr/testify/assert"
@@ -72,3 +75,73 @@ color-link constant.string.char "#BDE6AD,#282828"`
 	assert.Equal(t, tcell.NewRGBColor(117, 113, 94), fg)
 	assert.Equal(t, tcell.NewRGBColor(40, 40, 40), bg)
 }
+
+func TestColorschemeVariables(t *testing.T) {
+	AddRuntimeFile(RTColorscheme, memoryFile{"testpalette", []byte(`define red "#ff5555"
+define bg "#282828"`)})
+	testColorscheme := `include "testpalette"
+define error "bold red,bg"
+color-link comment "red,bg"
+color-link error "error"`
+
+	var parsedColorschemes []string
+	c, err := ParseColorscheme("testColorscheme", testColorscheme, &parsedColorschemes)
+	assert.Nil(t, err)
+
+	fg, bg, attr := c["error"].Decompose()
+	assert.Equal(t, tcell.NewRGBColor(255, 85, 85), fg)
+	assert.Equal(t, tcell.NewRGBColor(40, 40, 40), bg)
+	assert.NotZero(t, attr&tcell.AttrBold)
+	fg, _, _ = c["comment"].Decompose()
+	assert.Equal(t, tcell.NewRGBColor(255, 85, 85), fg)
+}
+
+func TestReloadColorscheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testreload.micro")
+	assert.Nil(t, os.WriteFile(path, []byte(`color-link comment "red"`), 0644))
+	AddRealRuntimeFile(RTColorscheme, realFile(path))
+
+	assert.Nil(t, SetColorscheme("testreload"))
+	assert.False(t, colorschemeModified())
+	fg, _, _ := GetColor("comment").Decompose()
+	assert.Equal(t, tcell.ColorMaroon, fg)
+
+	assert.Nil(t, os.WriteFile(path, []byte(`color-link comment "green"`), 0644))
+	assert.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.True(t, colorschemeModified())
+	assert.Nil(t, ReloadColorscheme())
+	assert.False(t, colorschemeModified())
+	fg, _, _ = GetColor("comment").Decompose()
+	assert.Equal(t, tcell.ColorGreen, fg)
+
+	// an invalid colorscheme keeps the previous one
+	assert.Nil(t, os.WriteFile(path, []byte(`color-link comment`), 0644))
+	assert.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+	assert.NotNil(t, ReloadColorscheme())
+	assert.False(t, colorschemeModified())
+	fg, _, _ = GetColor("comment").Decompose()
+	assert.Equal(t, tcell.ColorGreen, fg)
+}
+
+func TestColorschemeBackground(t *testing.T) {
+	InitGlobalSettings()
+	defer func() {
+		termBackground = ""
+		ClearColorschemeVariants()
+	}()
+
+	assert.NotNil(t, validateColorschemeVariants(map[string]any{"day": "simple"}))
+	assert.Nil(t, validateColorschemeVariants(map[string]any{"light": "simple", "dark": "monokai"}))
+
+	// the dark colorscheme is used when the background is unknown
+	name := ResolveColorschemeOption(map[string]any{"light": "simple", "dark": "monokai"})
+	assert.Equal(t, "monokai", name)
+	assert.True(t, ColorschemeFollowsBackground())
+
+	GlobalSettings["colorscheme"] = name
+	assert.Nil(t, SetTermBackground("light"))
+	assert.Equal(t, "simple", GlobalSettings["colorscheme"])
+
+	assert.Equal(t, "simple", ResolveColorschemeOption("simple"))
+	assert.False(t, ColorschemeFollowsBackground())
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// editorConfigFile is a parsed .editorconfig file
+type editorConfigFile struct {
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+// editorConfigSection is a section of an .editorconfig file: the
+// properties that apply to the files matching its glob
+type editorConfigSection struct {
+	glob  *editorConfigGlob
+	props [][2]string
+}
+
+// editorConfigGlob is a section glob compiled to a regular expression.
+// The numeric ranges of the glob are captured by the groups of the
+// regular expression and checked after matching.
+type editorConfigGlob struct {
+	re     *regexp.Regexp
+	ranges [][2]int
+}
+
+// match returns whether the path, relative to the directory of the
+// .editorconfig file and with forward slashes, matches the glob
+func (g *editorConfigGlob) match(path string) bool {
+	m := g.re.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	for i, r := range g.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+var editorConfigRange = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// globTranslator translates an EditorConfig glob to a regular expression
+type globTranslator struct {
+	ranges [][2]int
+}
+
+// closing returns the index of the bracket closing the one at the start of
+// s, or -1 if there is none
+func closing(s string, open, close byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits s at the commas that are not nested in braces
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alts, s[start:])
+}
+
+func (t *globTranslator) translate(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			} else {
+				re.WriteString(`\\`)
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				re.WriteString(`.*`)
+				i++
+			} else {
+				re.WriteString(`[^/]*`)
+			}
+		case '?':
+			re.WriteString(`[^/]`)
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 || strings.Contains(glob[i+1:i+1+end], "/") {
+				re.WriteString(`\[`)
+				break
+			}
+			class := glob[i+1 : i+1+end]
+			re.WriteByte('[')
+			if strings.HasPrefix(class, "!") {
+				re.WriteByte('^')
+				class = class[1:]
+			}
+			for j := 0; j < len(class); j++ {
+				if strings.IndexByte(`\[]^`, class[j]) >= 0 {
+					re.WriteByte('\\')
+				}
+				re.WriteByte(class[j])
+			}
+			re.WriteByte(']')
+			i += end + 1
+		case '{':
+			end := closing(glob[i:], '{', '}')
+			if end < 0 {
+				re.WriteString(`\{`)
+				break
+			}
+			inner := glob[i+1 : i+end]
+			if m := editorConfigRange.FindStringSubmatch(inner); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				t.ranges = append(t.ranges, [2]int{lo, hi})
+				re.WriteString(`([+-]?\d+)`)
+				i += end
+				break
+			}
+			alts := splitAlternatives(inner)
+			if len(alts) == 1 {
+				// a single word in braces is matched literally
+				re.WriteString(`\{`)
+				break
+			}
+			for j, alt := range alts {
+				alts[j] = t.translate(alt)
+			}
+			re.WriteString(`(?:` + strings.Join(alts, "|") + `)`)
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return re.String()
+}
+
+// compileEditorConfigGlob compiles the glob of a section. A glob without a
+// slash matches files in any subdirectory, and one with a slash matches
+// paths relative to the directory of the .editorconfig file.
+func compileEditorConfigGlob(glob string) *editorConfigGlob {
+	prefix := `(?:.*/)?`
+	if strings.Contains(glob, "/") {
+		prefix = ""
+		glob = strings.TrimPrefix(glob, "/")
+	}
+	t := new(globTranslator)
+	re, err := regexp.Compile(`^` + prefix + t.translate(glob) + `$`)
+	if err != nil {
+		return nil
+	}
+	return &editorConfigGlob{re, t.ranges}
+}
+
+// parseEditorConfig parses the contents of the .editorconfig file in dir
+func parseEditorConfig(data []byte, dir string) *editorConfigFile {
+	ec := &editorConfigFile{dir: dir}
+	var section *editorConfigSection
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			ec.sections = append(ec.sections, editorConfigSection{
+				glob: compileEditorConfigGlob(line[1 : len(line)-1]),
+			})
+			section = &ec.sections[len(ec.sections)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if section == nil {
+			if key == "root" {
+				ec.root = value == "true"
+			}
+			continue
+		}
+		section.props = append(section.props, [2]string{key, value})
+	}
+	return ec
+}
+
+// editorConfigProperties returns the EditorConfig properties of the file at
+// path, read from the .editorconfig files of its directory and the parent
+// directories up to the one marked with `root = true`. Properties set in
+// closer files and later sections take precedence.
+func editorConfigProperties(path string) map[string]string {
+	var files []*editorConfigFile
+	for dir := filepath.Dir(path); ; {
+		if data, err := os.ReadFile(filepath.Join(dir, ".editorconfig")); err == nil {
+			ec := parseEditorConfig(data, dir)
+			files = append(files, ec)
+			if ec.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range files[i].sections {
+			if s.glob == nil || !s.glob.match(rel) {
+				continue
+			}
+			for _, p := range s.props {
+				if p[1] == "unset" {
+					delete(props, p[0])
+				} else {
+					props[p[0]] = p[1]
+				}
+			}
+		}
+	}
+	return props
+}
+
+// EditorConfigSettings returns the options of the file at path given by
+// its EditorConfig properties
+func EditorConfigSettings(path string) map[string]any {
+	props := editorConfigProperties(path)
+	settings := make(map[string]any)
+
+	switch props["indent_style"] {
+	case "space":
+		settings["tabstospaces"] = true
+	case "tab":
+		settings["tabstospaces"] = false
+	}
+
+	// micro uses the same width for indentation and tabs, so the tab width
+	// wins when indenting with tabs
+	size := props["indent_size"]
+	if size == "" || size == "tab" || (props["indent_style"] == "tab" && props["tab_width"] != "") {
+		size = props["tab_width"]
+	}
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		settings["tabsize"] = float64(n)
+	}
+
+	switch props["end_of_line"] {
+	case "lf":
+		settings["fileformat"] = "unix"
+	case "crlf":
+		settings["fileformat"] = "dos"
+	}
+
+	switch props["charset"] {
+	case "utf-8", "utf-8-bom":
+		settings["encoding"] = "utf-8"
+	case "latin1":
+		settings["encoding"] = "iso-8859-1"
+	case "utf-16be", "utf-16le":
+		settings["encoding"] = props["charset"]
+	}
+
+	for prop, option := range map[string]string{
+		"insert_final_newline":     "eofnewline",
+		"trim_trailing_whitespace": "rmtrailingws",
+	} {
+		switch props[prop] {
+		case "true":
+			settings[option] = true
+		case "false":
+			settings[option] = false
+		}
+	}
+	return settings
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*", "a.go", true},
+		{"*", "dir/a.go", true},
+		{"*.go", "dir/sub/a.go", true},
+		{"*.go", "a.py", false},
+		{"dir/*.go", "dir/a.go", true},
+		{"dir/*.go", "dir/sub/a.go", false},
+		{"/dir/*.go", "other/dir/a.go", false},
+		{"dir/**.go", "dir/sub/a.go", true},
+		{"?.c", "a.c", true},
+		{"?.c", "ab.c", false},
+		{"[ab].c", "b.c", true},
+		{"[!ab].c", "b.c", false},
+		{"*.{js,ts}", "a.ts", true},
+		{"*.{js,ts}", "a.go", false},
+		{"{a,{b,c}}.h", "c.h", true},
+		{"{single}", "{single}", true},
+		{"file{1..10}.txt", "file7.txt", true},
+		{"file{1..10}.txt", "file11.txt", false},
+		{"Makefile", "sub/Makefile", true},
+		{`a\*.c`, "a*.c", true},
+		{`a\*.c`, "ab.c", false},
+	}
+	for _, test := range tests {
+		g := compileEditorConfigGlob(test.glob)
+		assert.NotNil(t, g, test.glob)
+		assert.Equal(t, test.match, g.match(test.path), "%s %s", test.glob, test.path)
+	}
+}
+
+func TestEditorConfigSettings(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	sub := filepath.Join(project, "sub")
+	os.MkdirAll(sub, 0755)
+
+	// this file is ignored because of the root file below it
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("[*]\ncharset = latin1\n"), 0644)
+	os.WriteFile(filepath.Join(project, ".editorconfig"), []byte(
+		"# comment\n"+
+			"root = true\n"+
+			"\n"+
+			"[*]\n"+
+			"indent_style = space\n"+
+			"indent_size = 4\n"+
+			"end_of_line = LF\n"+
+			"insert_final_newline = true\n"+
+			"trim_trailing_whitespace = true\n"+
+			"\n"+
+			"[*.go]\n"+
+			"indent_style = tab\n"+
+			"tab_width = 8\n"+
+			"\n"+
+			"[sub/*.md]\n"+
+			"trim_trailing_whitespace = false\n"), 0644)
+	os.WriteFile(filepath.Join(sub, ".editorconfig"), []byte(
+		"[*.md]\nindent_size = unset\nend_of_line = crlf\n"), 0644)
+
+	assert.Equal(t, map[string]any{
+		"tabstospaces": false,
+		"tabsize":      float64(8),
+		"fileformat":   "unix",
+		"eofnewline":   true,
+		"rmtrailingws": true,
+	}, EditorConfigSettings(filepath.Join(sub, "main.go")))
+
+	assert.Equal(t, map[string]any{
+		"tabstospaces": true,
+		"fileformat":   "dos",
+		"eofnewline":   true,
+		"rmtrailingws": false,
+	}, EditorConfigSettings(filepath.Join(sub, "README.md")))
+
+	assert.Equal(t, map[string]any{
+		"encoding": "iso-8859-1",
+	}, EditorConfigSettings(filepath.Join(dir, "a.txt")))
+}
@@ -17,10 +17,11 @@ const (
 	RTHelp         = 2
 	RTPlugin       = 3
 	RTSyntaxHeader = 4
+	RTSnippet      = 5
 )
 
 var (
-	NumTypes = 5 // How many filetypes are there
+	NumTypes = 6 // How many filetypes are there
 )
 
 type RTFiletype int
@@ -175,6 +176,7 @@ func InitRuntimeFiles(user bool) {
 	add(RTSyntax, "syntax", "*.yaml")
 	add(RTSyntaxHeader, "syntax", "*.hdr")
 	add(RTHelp, "help", "*.md")
+	add(RTSnippet, "snippets", "*.snippets")
 }
 
 // InitPlugins initializes the plugins
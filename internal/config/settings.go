@@ -28,13 +28,16 @@ var optionValidators = map[string]optionValidator{
 	"detectlimit":     validateNonNegativeValue,
 	"encoding":        validateEncoding,
 	"fileformat":      validateChoice,
+	"filetreewidth":   validatePositiveValue,
 	"helpsplit":       validateChoice,
 	"matchbracestyle": validateChoice,
+	"modelines":       validateNonNegativeValue,
 	"multiopen":       validateChoice,
 	"pageoverlap":     validateNonNegativeValue,
 	"reload":          validateChoice,
 	"scrollmargin":    validateNonNegativeValue,
 	"scrollspeed":     validateNonNegativeValue,
+	"searchlines":     validatePositiveValue,
 	"tabsize":         validatePositiveValue,
 	"truecolor":       validateChoice,
 }
@@ -59,11 +62,16 @@ var defaultCommonSettings = map[string]any{
 	"backupdir":       "",
 	"basename":        false,
 	"colorcolumn":     float64(0),
+	"commentstring":   "",
+	"completesources": "buffer,buffers,files,dictionary,snippets",
 	"cursorline":      true,
 	"detectlimit":     float64(100),
+	"dictionary":      "",
 	"diffgutter":      false,
+	"editorconfig":    true,
 	"encoding":        "utf-8",
 	"eofnewline":      true,
+	"errorformat":     "%f:%l:%c: %m",
 	"fastdirty":       false,
 	"fileformat":      defaultFileFormat(),
 	"filetype":        "unknown",
@@ -73,11 +81,14 @@ var defaultCommonSettings = map[string]any{
 	"ignorecase":      true,
 	"incsearch":       true,
 	"indentchar":      " ", // Deprecated
+	"inlinemessages":  false,
 	"keepautoindent":  false,
+	"lspserver":       "",
 	"matchbrace":      true,
 	"matchbraceleft":  true,
 	"matchbracestyle": "underline",
 	"mkparents":       false,
+	"modelines":       float64(5),
 	"pageoverlap":     float64(2),
 	"permbackup":      false,
 	"readonly":        false,
@@ -90,6 +101,7 @@ var defaultCommonSettings = map[string]any{
 	"scrollbar":       false,
 	"scrollmargin":    float64(3),
 	"scrollspeed":     float64(2),
+	"searchlines":     float64(100),
 	"showchars":       "",
 	"smartpaste":      true,
 	"softwrap":        false,
@@ -116,6 +128,8 @@ var DefaultGlobalOnlySettings = map[string]any{
 	"divchars":       "|-",
 	"divreverse":     true,
 	"fakecursor":     defaultFakeCursor(),
+	"filetreewidth":  float64(30),
+	"findhidden":     false,
 	"helpsplit":      "hsplit",
 	"infobar":        true,
 	"keymenu":        false,
@@ -174,6 +188,16 @@ func validateParsedSettings() error {
 	var err error
 	defaults := DefaultAllSettings()
 	for k, v := range parsedSettings {
+		if k == "colorscheme" {
+			if variants, ok := v.(map[string]any); ok {
+				if e := validateColorschemeVariants(variants); e != nil {
+					err = e
+					delete(parsedSettings, k)
+				}
+				continue
+			}
+		}
+
 		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") {
 			if strings.HasPrefix(k, "ft:") {
 				for k1, v1 := range v.(map[string]any) {
@@ -301,6 +325,13 @@ func InitGlobalSettings() error {
 			GlobalSettings[k] = v
 		}
 	}
+
+	// The colorscheme may be given for each background of the terminal
+	colorscheme, ok := parsedSettings["colorscheme"]
+	if !ok {
+		colorscheme = GlobalSettings["colorscheme"]
+	}
+	GlobalSettings["colorscheme"] = ResolveColorschemeOption(colorscheme)
 	return err
 }
 
@@ -309,7 +340,7 @@ func InitGlobalSettings() error {
 // Must be called after ReadSettings
 func UpdatePathGlobLocals(settings map[string]any, path string) {
 	for k, v := range parsedSettings {
-		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") && !strings.HasPrefix(k, "ft:") {
+		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") && !strings.HasPrefix(k, "ft:") && k != "colorscheme" {
 			g, _ := glob.Compile(k)
 			if g.MatchString(path) {
 				for k1, v1 := range v.(map[string]any) {
@@ -584,6 +615,24 @@ func validateColorscheme(option string, value any) error {
 	return nil
 }
 
+// validateColorschemeVariants validates the value of the colorscheme option
+// when it gives a colorscheme for each background of the terminal. The
+// colorschemes are not checked since they may be added by plugins.
+func validateColorschemeVariants(variants map[string]any) error {
+	if len(variants) == 0 {
+		return errors.New("Error: setting 'colorscheme' has no colorscheme, using default value: default")
+	}
+	for background, name := range variants {
+		if background != "light" && background != "dark" {
+			return errors.New("Error: setting 'colorscheme' has invalid background '" + background + "', expected 'light' or 'dark'")
+		}
+		if _, ok := name.(string); !ok {
+			return errors.New("Expected string type for colorscheme")
+		}
+	}
+	return nil
+}
+
 func validateEncoding(option string, value any) error {
 	_, err := htmlindex.Get(value.(string))
 	return err
@@ -177,6 +177,7 @@ func (w *BufWindow) getStartInfo(n, lineN int) ([]byte, int, int, *tcell.Style)
 	width := 0
 	bloc := buffer.Loc{0, lineN}
 	b := w.Buf.LineBytes(lineN)
+	inline := w.inlineTexts(lineN)
 	curStyle := config.DefStyle
 	var s *tcell.Style
 	for len(b) > 0 {
@@ -187,13 +188,15 @@ func (w *BufWindow) getStartInfo(n, lineN int) ([]byte, int, int, *tcell.Style)
 			s = &curStyle
 		}
 
-		w := 0
+		// An inline virtual text is scrolled together with the
+		// following character
+		w := inline.width(bloc.X)
 		switch r {
 		case '\t':
-			ts := tabsize - (width % tabsize)
-			w = ts
+			ts := tabsize - ((width + w) % tabsize)
+			w += ts
 		default:
-			w = runewidth.RuneWidth(r)
+			w += runewidth.RuneWidth(r)
 		}
 		if width+w > n {
 			return b, n - width, bloc.X, s
@@ -245,7 +248,7 @@ func (w *BufWindow) Relocate() bool {
 
 	// horizontal relocation (scrolling)
 	if !b.Settings["softwrap"].(bool) {
-		cx := activeC.GetVisualX(false)
+		cx := w.VLocFromLoc(activeC.Loc).VisualX
 		rw := runewidth.RuneWidth(activeC.RuneUnder(activeC.X))
 		if rw == 0 {
 			rw = 1 // tab or newline
@@ -509,6 +512,8 @@ func (w *BufWindow) displayBuffer() {
 
 		bline := b.LineBytes(bloc.Y)
 		blineLen := util.CharacterCount(bline)
+		decorations := b.DecorationsInLine(bloc.Y)
+		inline := w.inlineTexts(bloc.Y)
 
 		leadingwsEnd := len(util.GetLeadingWhitespace(bline))
 		trailingwsStart := blineLen - util.CharacterCount(util.GetTrailingWhitespace(bline))
@@ -706,11 +711,31 @@ func (w *BufWindow) displayBuffer() {
 			}
 		}
 
+		// Virtual text is drawn with the background of the cursor line
+		// unless it has its own background
+		virtualStyle := func(style tcell.Style) tcell.Style {
+			_, bg, _ := style.Decompose()
+			_, defBg, _ := config.DefStyle.Decompose()
+			if bg != defBg || !b.Settings["cursorline"].(bool) || !w.active {
+				return style
+			}
+			for _, c := range cursors {
+				if !c.HasSelection() && c.Y == bloc.Y {
+					if s, ok := config.Colorscheme["cursor-line"]; ok {
+						fg, _, _ := s.Decompose()
+						return style.Background(fg)
+					}
+				}
+			}
+			return style
+		}
+
 		type glyph struct {
-			r     rune
-			combc []rune
-			style tcell.Style
-			width int
+			r       rune
+			combc   []rune
+			style   tcell.Style
+			width   int
+			virtual bool
 		}
 
 		var word []glyph
@@ -720,15 +745,27 @@ func (w *BufWindow) displayBuffer() {
 			word = make([]glyph, 0, 1)
 		}
 		wordwidth := 0
+		// the number of characters of the buffer in the word
+		wordChars := 0
 
 		totalwidth := w.StartCol - nColsBeforeStart
 		for len(line) > 0 && vloc.X < maxWidth {
 			r, combc, size := util.DecodeCharacter(line)
 			line = line[size:]
 
-			loc := buffer.Loc{X: bloc.X + len(word), Y: bloc.Y}
+			loc := buffer.Loc{X: bloc.X + wordChars, Y: bloc.Y}
 			curStyle, _ = w.getStyle(curStyle, loc)
 
+			// Inline virtual text is drawn before the character and
+			// wrapped together with it
+			if vt, ok := inline[loc.X]; ok {
+				for _, g := range vt.glyphs {
+					word = append(word, glyph{g.r, g.combc, g.style, g.width, true})
+				}
+				wordwidth += vt.width
+				totalwidth += vt.width
+			}
+
 			width := 0
 
 			linex := totalwidth
@@ -742,8 +779,16 @@ func (w *BufWindow) displayBuffer() {
 				totalwidth += width
 			}
 
-			word = append(word, glyph{r, combc, curStyle, width})
+			style := curStyle
+			for _, d := range decorations {
+				if d.Contains(loc) {
+					style = d.Apply(style)
+				}
+			}
+
+			word = append(word, glyph{r, combc, style, width, false})
 			wordwidth += width
+			wordChars++
 
 			// Collect a complete word to know its width.
 			// If wordwrap is off, every single character is a complete "word".
@@ -772,6 +817,15 @@ func (w *BufWindow) displayBuffer() {
 			}
 
 			for _, r := range word {
+				if r.virtual {
+					style := virtualStyle(r.style)
+					draw(r.r, r.combc, style, false, false, true)
+					for i := 1; i < r.width; i++ {
+						draw(' ', nil, style, false, false, true)
+					}
+					continue
+				}
+
 				drawrune, drawstyle, preservebg := getRuneStyle(r.r, r.style, 0, linex, false)
 				draw(drawrune, r.combc, drawstyle, true, true, preservebg)
 
@@ -789,6 +843,7 @@ func (w *BufWindow) displayBuffer() {
 
 			word = word[:0]
 			wordwidth = 0
+			wordChars = 0
 
 			// If we reach the end of the window then we either stop or we wrap for softwrap
 			if vloc.X >= maxWidth {
@@ -831,6 +886,20 @@ func (w *BufWindow) displayBuffer() {
 			draw(drawrune, nil, drawstyle, true, true, preservebg)
 		}
 
+		// Draw the virtual text at the end of the line after the newline
+		if len(line) == 0 && vloc.Y >= 0 && vloc.Y < w.bufHeight {
+			for _, g := range w.endOfLineGlyphs(bloc.Y) {
+				if vloc.X+g.width > maxWidth {
+					break
+				}
+				style := virtualStyle(g.style)
+				draw(g.r, g.combc, style, false, false, true)
+				for i := 1; i < g.width; i++ {
+					draw(' ', nil, style, false, false, true)
+				}
+			}
+		}
+
 		bloc.X = w.StartCol
 		bloc.Y++
 		if bloc.Y >= b.LinesNum() {
@@ -900,4 +969,135 @@ func (w *BufWindow) Display() {
 	w.displayStatusLine()
 	w.displayScrollBar()
 	w.displayBuffer()
+	w.displayCompletionMenu()
+}
+
+// maximum number of suggestions displayed by the autocompletion menu
+const completionMenuHeight = 10
+
+// maximum width of the suggestions in the autocompletion menu
+const completionMenuTextWidth = 40
+
+// completionMenuStyles returns the styles of the autocompletion menu, of
+// its selected suggestion and of the details
+func completionMenuStyles() (tcell.Style, tcell.Style, tcell.Style) {
+	style := config.DefStyle.Reverse(true)
+	if s, ok := config.Colorscheme["autocomplete"]; ok {
+		style = s
+	} else if s, ok := config.Colorscheme["statusline.suggestions"]; ok {
+		style = s
+	} else if s, ok := config.Colorscheme["statusline"]; ok {
+		style = s
+	}
+	selStyle := style.Reverse(true)
+	if s, ok := config.Colorscheme["autocomplete.selected"]; ok {
+		selStyle = s
+	} else if s, ok := config.Colorscheme["selection"]; ok {
+		selStyle = s
+	}
+	detailStyle := style
+	if s, ok := config.Colorscheme["comment"]; ok {
+		fg, _, _ := s.Decompose()
+		detailStyle = style.Foreground(fg)
+	}
+	return style, selStyle, detailStyle
+}
+
+// displayCompletionMenu draws the autocompletion menu below the word being
+// completed, or above it if there is not enough space below
+func (w *BufWindow) displayCompletionMenu() {
+	m := w.Buf.Menu
+	if m == nil || !w.active || len(m.Items) == 0 {
+		return
+	}
+
+	vloc := w.VLocFromLoc(m.Start)
+	row := w.Diff(w.StartLine, vloc.SLoc)
+	if row < 0 || row >= w.bufHeight {
+		return
+	}
+
+	height := util.Min(len(m.Items), completionMenuHeight)
+	below, above := w.bufHeight-row-1, row
+	y := w.Y + row + 1
+	if below < height {
+		if above >= height {
+			y = w.Y + row - height
+		} else if below >= above {
+			height = below
+		} else {
+			height = above
+			y = w.Y
+		}
+	}
+	if height <= 0 {
+		return
+	}
+	if m.Selected < m.Top {
+		m.Top = m.Selected
+	} else if m.Selected >= m.Top+height {
+		m.Top = m.Selected - height + 1
+	}
+	m.Top = util.Clamp(m.Top, 0, len(m.Items)-height)
+
+	textWidth, detailWidth := 0, 0
+	for _, s := range m.Items[m.Top : m.Top+height] {
+		textWidth = util.Max(textWidth, runewidth.StringWidth(s.Text))
+		detailWidth = util.Max(detailWidth, runewidth.StringWidth(s.Detail))
+	}
+	textWidth = util.Min(textWidth, completionMenuTextWidth)
+	width := textWidth + 2
+	if detailWidth > 0 {
+		width += detailWidth + 2
+	}
+	maxWidth := w.Width - w.gutterOffset
+	if width > maxWidth {
+		// hide the details if there is not enough space
+		width = util.Min(textWidth+2, maxWidth)
+		detailWidth = 0
+	}
+
+	// the suggestions are aligned with the word being completed
+	x := w.X + w.gutterOffset + vloc.VisualX - w.StartCol - 1
+	x = util.Clamp(x, w.X+w.gutterOffset, w.X+w.Width-width)
+
+	style, selStyle, detailStyle := completionMenuStyles()
+	for j := 0; j < height; j++ {
+		s := m.Items[m.Top+j]
+		lineStyle, dStyle := style, detailStyle
+		if m.Top+j == m.Selected {
+			lineStyle, dStyle = selStyle, selStyle
+		}
+
+		cx := x
+		end := x + width
+		draw := func(r rune, st tcell.Style) {
+			if cx+runewidth.RuneWidth(r) <= end {
+				screen.SetContent(cx, y+j, r, nil, st)
+			}
+			cx += runewidth.RuneWidth(r)
+		}
+		draw(' ', lineStyle)
+		textEnd := x + 1 + textWidth
+		for _, r := range s.Text {
+			if cx+runewidth.RuneWidth(r) > textEnd {
+				break
+			}
+			draw(r, lineStyle)
+		}
+		for cx < textEnd+1 && cx < end {
+			draw(' ', lineStyle)
+		}
+		if detailWidth > 0 {
+			for cx < end-1-runewidth.StringWidth(s.Detail) {
+				draw(' ', dStyle)
+			}
+			for _, r := range s.Detail {
+				draw(r, dStyle)
+			}
+		}
+		for cx < end {
+			draw(' ', lineStyle)
+		}
+	}
 }
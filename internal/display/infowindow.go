@@ -1,6 +1,9 @@
 package display
 
 import (
+	"fmt"
+	"strings"
+
 	runewidth "github.com/mattn/go-runewidth"
 	"github.com/micro-editor/micro/v2/internal/buffer"
 	"github.com/micro-editor/micro/v2/internal/config"
@@ -209,6 +212,134 @@ func (i *InfoWindow) scrollToSuggestion() {
 	}
 }
 
+// maximum number of items displayed by a picker
+const pickerMaxHeight = 12
+
+// pickerLine draws a line of a picker from x to x+width. The beginning of
+// text is cut if it is too long, since the end of a path is more useful.
+// The runes whose indices are in matched are highlighted, and detail is
+// displayed at the end of the line with detailStyle.
+func pickerLine(x, y, width int, text []rune, style tcell.Style, matched map[int]bool, detail string, detailStyle tcell.Style) {
+	end := x + width
+	if detail != "" && runewidth.StringWidth(detail)+2 < width/2 {
+		end -= runewidth.StringWidth(detail) + 1
+		dx := end + 1
+		for _, r := range detail {
+			screen.SetContent(dx, y, r, nil, detailStyle)
+			dx += runewidth.RuneWidth(r)
+		}
+		screen.SetContent(end, y, ' ', nil, style)
+	}
+
+	start := 0
+	for start < len(text) && runewidth.StringWidth(string(text[start:])) > end-x {
+		start++
+	}
+	if start > 0 {
+		screen.SetContent(x, y, '<', nil, style)
+		x++
+	}
+	for j := start; j < len(text) && x < end; j++ {
+		s := style
+		if matched[j] {
+			s = s.Bold(true)
+			if special, ok := config.Colorscheme["special"]; ok {
+				fg, _, _ := special.Decompose()
+				s = s.Foreground(fg)
+			}
+		}
+		screen.SetContent(x, y, text[j], nil, s)
+		x += runewidth.RuneWidth(text[j])
+	}
+	for ; x < end; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+}
+
+func (i *InfoWindow) displayPicker(keymenuOffset int) {
+	p := i.Picker
+	bottom := i.Y - keymenuOffset - 1
+	height := util.Min(len(p.Matches), pickerMaxHeight)
+	listWidth := i.Width
+	preview := p.Preview != nil && i.Width >= 60
+	if preview {
+		height = pickerMaxHeight
+		listWidth = i.Width / 2
+	}
+	height = util.Min(height, bottom/2)
+	if height < 0 {
+		return
+	}
+	if p.Selected < p.Top {
+		p.Top = p.Selected
+	} else if p.Selected >= p.Top+height {
+		p.Top = p.Selected - height + 1
+	}
+
+	titleStyle := config.DefStyle.Reverse(true)
+	if style, ok := config.Colorscheme["statusline.suggestions"]; ok {
+		titleStyle = style
+	} else if style, ok := config.Colorscheme["statusline"]; ok {
+		titleStyle = style
+	}
+	selStyle := i.defStyle().Reverse(true)
+	if style, ok := config.Colorscheme["selection"]; ok {
+		selStyle = style
+	}
+	detailStyle := i.defStyle()
+	if style, ok := config.Colorscheme["comment"]; ok {
+		detailStyle = style
+	}
+
+	title := fmt.Sprintf("%s (%d/%d)", p.Title, len(p.Matches), len(p.Items))
+	if p.Loading {
+		title += "..."
+	}
+	pickerLine(0, bottom-height, i.Width, []rune(" "+title), titleStyle, nil, "", titleStyle)
+
+	for j := 0; j < height; j++ {
+		y := bottom - height + 1 + j
+		if p.Top+j >= len(p.Matches) {
+			pickerLine(0, y, listWidth, nil, i.defStyle(), nil, "", detailStyle)
+			continue
+		}
+		m := p.Matches[p.Top+j]
+		style := i.defStyle()
+		if p.Top+j == p.Selected {
+			style = selStyle
+		}
+		matched := make(map[int]bool)
+		for _, pos := range p.MatchPositions(m) {
+			matched[pos+1] = true
+		}
+		pickerLine(0, y, listWidth, []rune(" "+p.Items[m.Index]), style, matched, p.Detail(m.Index), detailStyle)
+	}
+
+	if preview {
+		lines := strings.Split(p.PreviewText(), "\n")
+		for j := 0; j < height; j++ {
+			y := bottom - height + 1 + j
+			screen.SetContent(listWidth, y, '|', nil, titleStyle)
+			line := ""
+			if j < len(lines) {
+				line = strings.ReplaceAll(strings.TrimSuffix(lines[j], "\r"), "\t", "    ")
+			}
+			x := listWidth + 2
+			screen.SetContent(listWidth+1, y, ' ', nil, i.defStyle())
+			for _, r := range line {
+				if x+runewidth.RuneWidth(r) > i.Width {
+					break
+				}
+				screen.SetContent(x, y, r, nil, i.defStyle())
+				x += runewidth.RuneWidth(r)
+			}
+			for ; x < i.Width; x++ {
+				screen.SetContent(x, y, ' ', nil, i.defStyle())
+			}
+		}
+	}
+}
+
 func (i *InfoWindow) Display() {
 	if i.HasPrompt || config.GlobalSettings["infobar"].(bool) {
 		i.Clear()
@@ -238,6 +369,14 @@ func (i *InfoWindow) Display() {
 		}
 	}
 
+	if i.HasPrompt && i.Picker != nil {
+		keymenuOffset := 0
+		if config.GetGlobalOption("keymenu").(bool) {
+			keymenuOffset = len(keydisplay)
+		}
+		i.displayPicker(keymenuOffset)
+	}
+
 	if i.HasSuggestions && len(i.Suggestions) > 1 {
 		i.scrollToSuggestion()
 
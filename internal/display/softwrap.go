@@ -82,25 +82,33 @@ func (w *BufWindow) getVLocFromLoc(loc buffer.Loc) VLoc {
 	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
 
 	line := w.Buf.LineBytes(loc.Y)
+	inline := w.inlineTexts(loc.Y)
 	x := 0
 	totalwidth := 0
 
 	wordwidth := 0
 	wordoffset := 0
 
-	for len(line) > 0 {
+	for i := 0; len(line) > 0; i++ {
 		r, _, size := util.DecodeCharacter(line)
 		line = line[size:]
 
-		width := 0
+		// An inline virtual text is wrapped together with the
+		// following character
+		width := inline.width(i)
+		totalwidth += width
+		if i == loc.X {
+			wordoffset += width
+		}
 		switch r {
 		case '\t':
 			ts := tabsize - (totalwidth % tabsize)
-			width = util.Min(ts, w.bufWidth-vloc.VisualX)
+			width += util.Min(ts, w.bufWidth-vloc.VisualX)
 			totalwidth += ts
 		default:
-			width = runewidth.RuneWidth(r)
-			totalwidth += width
+			rw := runewidth.RuneWidth(r)
+			width += rw
+			totalwidth += rw
 		}
 
 		wordwidth += width
@@ -153,6 +161,7 @@ func (w *BufWindow) getLocFromVLoc(svloc VLoc) buffer.Loc {
 	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
 
 	line := w.Buf.LineBytes(svloc.Line)
+	inline := w.inlineTexts(svloc.Line)
 	vloc := VLoc{SLoc: SLoc{svloc.Line, 0}, VisualX: 0}
 
 	totalwidth := 0
@@ -165,19 +174,23 @@ func (w *BufWindow) getLocFromVLoc(svloc VLoc) buffer.Loc {
 	}
 	wordwidth := 0
 
-	for len(line) > 0 {
+	for x := 0; len(line) > 0; x++ {
 		r, _, size := util.DecodeCharacter(line)
 		line = line[size:]
 
-		width := 0
+		// Clicking on an inline virtual text moves to the following
+		// character
+		width := inline.width(x)
+		totalwidth += width
 		switch r {
 		case '\t':
 			ts := tabsize - (totalwidth % tabsize)
-			width = util.Min(ts, w.bufWidth-vloc.VisualX)
+			width += util.Min(ts, w.bufWidth-vloc.VisualX)
 			totalwidth += ts
 		default:
-			width = runewidth.RuneWidth(r)
-			totalwidth += width
+			rw := runewidth.RuneWidth(r)
+			width += rw
+			totalwidth += rw
 		}
 
 		widths = append(widths, width)
@@ -321,9 +334,7 @@ func (w *BufWindow) SLocFromLoc(loc buffer.Loc) SLoc {
 // visual location in the linewrapped buffer.
 func (w *BufWindow) VLocFromLoc(loc buffer.Loc) VLoc {
 	if !w.Buf.Settings["softwrap"].(bool) {
-		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
-
-		visualx := util.StringWidth(w.Buf.LineBytes(loc.Y), loc.X, tabsize)
+		visualx := w.stringWidth(loc.Y, loc.X)
 		return VLoc{SLoc{loc.Y, 0}, visualx}
 	}
 	return w.getVLocFromLoc(loc)
@@ -333,9 +344,7 @@ func (w *BufWindow) VLocFromLoc(loc buffer.Loc) VLoc {
 // the position in the buffer corresponding to this visual location.
 func (w *BufWindow) LocFromVLoc(vloc VLoc) buffer.Loc {
 	if !w.Buf.Settings["softwrap"].(bool) {
-		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
-
-		x := util.GetCharPosInLine(w.Buf.LineBytes(vloc.Line), vloc.VisualX, tabsize)
+		x := w.charPosInLine(vloc.Line, vloc.VisualX)
 		return buffer.Loc{x, vloc.Line}
 	}
 	return w.getLocFromVLoc(vloc)
@@ -59,6 +59,47 @@ var statusInfo = map[string]func(*buffer.Buffer) string{
 	"percentage": func(b *buffer.Buffer) string {
 		return strconv.Itoa((b.GetActiveCursor().Y + 1) * 100 / b.LinesNum())
 	},
+	"search": func(b *buffer.Buffer) string {
+		if b.LastSearch == "" {
+			return ""
+		}
+		c := b.GetActiveCursor()
+		sel := c.CurSelection
+		if sel[0].GreaterThan(sel[1]) {
+			sel[0], sel[1] = sel[1], sel[0]
+		}
+		total, cur := b.SearchCount(sel)
+		if total == 0 {
+			return "no matches"
+		}
+		count := strconv.Itoa(total)
+		if total > buffer.SearchCountLimit {
+			count = strconv.Itoa(buffer.SearchCountLimit) + "+"
+		}
+		if cur > 0 && c.HasSelection() {
+			return fmt.Sprintf("match %d/%s", cur, count)
+		}
+		return count + " matches"
+	},
+	"searchflags": func(b *buffer.Buffer) string {
+		if b.LastSearch == "" {
+			return ""
+		}
+		var flags []string
+		if b.LastSearchRegex {
+			flags = append(flags, "regex")
+		}
+		if b.LastSearchCaseSensitive() {
+			flags = append(flags, "case")
+		}
+		if b.LastSearchWholeWord {
+			flags = append(flags, "word")
+		}
+		if len(flags) == 0 {
+			return ""
+		}
+		return "[" + strings.Join(flags, ",") + "]"
+	},
 }
 
 func SetStatusInfoFnLua(fn string) {
@@ -0,0 +1,190 @@
+package display
+
+import (
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// virtualGlyph is a character of a virtual text
+type virtualGlyph struct {
+	r     rune
+	combc []rune
+	style tcell.Style
+	width int
+}
+
+// appendVirtualGlyphs appends the characters of text with the given style
+// to glyphs, as long as their width does not exceed max, and returns the
+// new glyphs and their total width. The characters which cannot be
+// displayed, such as tabs, are replaced by spaces.
+func appendVirtualGlyphs(glyphs []virtualGlyph, text string, style tcell.Style, width, max int) ([]virtualGlyph, int) {
+	for len(text) > 0 {
+		r, combc, size := util.DecodeCharacterInString(text)
+		text = text[size:]
+
+		if r < ' ' {
+			r, combc = ' ', nil
+		}
+		rw := runewidth.RuneWidth(r)
+		if width+rw > max {
+			break
+		}
+		glyphs = append(glyphs, virtualGlyph{r, combc, style, rw})
+		width += rw
+	}
+	return glyphs, width
+}
+
+// inlineText is the inline virtual text displayed before a character
+type inlineText struct {
+	glyphs []virtualGlyph
+	width  int
+}
+
+// inlineTexts are the inline virtual texts of a line, by the index of the
+// character they are displayed before
+type inlineTexts map[int]*inlineText
+
+// width returns the width of the virtual text before character x
+func (t inlineTexts) width(x int) int {
+	if vt, ok := t[x]; ok {
+		return vt.width
+	}
+	return 0
+}
+
+// inlineTexts returns the inline virtual texts of line y. The texts at the
+// end of the line are displayed after it instead, and each text is
+// truncated to half of the width of the window so that it can be wrapped
+// with the following character.
+func (w *BufWindow) inlineTexts(y int) inlineTexts {
+	var texts inlineTexts
+	var lineLen int
+	for _, vt := range w.Buf.VirtualTextsInLine(y) {
+		if vt.Kind != buffer.VTInline {
+			continue
+		}
+		if texts == nil {
+			texts = make(inlineTexts)
+			lineLen = util.CharacterCount(w.Buf.LineBytes(y))
+		}
+		if vt.Loc.X >= lineLen {
+			continue
+		}
+		t, ok := texts[vt.Loc.X]
+		if !ok {
+			t = new(inlineText)
+			texts[vt.Loc.X] = t
+		}
+		t.glyphs, t.width = appendVirtualGlyphs(t.glyphs, vt.Text, config.GetColor(vt.Group), t.width, util.Max(w.bufWidth/2, 1))
+	}
+	return texts
+}
+
+// endOfLineGlyphs returns the characters displayed after the end of line
+// y, which are the virtual texts at the end of the line and, when the
+// `inlinemessages` option is on, the warning and error messages of the
+// line. The texts are separated by a space.
+func (w *BufWindow) endOfLineGlyphs(y int) []virtualGlyph {
+	var glyphs []virtualGlyph
+	width := 0
+	add := func(text string, style tcell.Style) {
+		if glyphs != nil {
+			glyphs, width = appendVirtualGlyphs(glyphs, " ", config.DefStyle, width, w.bufWidth)
+		}
+		glyphs, width = appendVirtualGlyphs(glyphs, text, style, width, w.bufWidth)
+	}
+
+	lineLen := -1
+	for _, vt := range w.Buf.VirtualTextsInLine(y) {
+		if vt.Kind == buffer.VTInline {
+			if lineLen < 0 {
+				lineLen = util.CharacterCount(w.Buf.LineBytes(y))
+			}
+			if vt.Loc.X < lineLen {
+				continue
+			}
+		}
+		add(vt.Text, config.GetColor(vt.Group))
+	}
+
+	if w.Buf.Settings["inlinemessages"].(bool) {
+		for _, m := range w.Buf.Messages {
+			if m.Start.Y == y && (m.Kind == buffer.MTWarning || m.Kind == buffer.MTError) {
+				msg, _, _ := strings.Cut(m.Msg, "\n")
+				add(msg, m.Style())
+			}
+		}
+	}
+	return glyphs
+}
+
+// stringWidth returns the visual width of the first n characters of line
+// y, including the inline virtual texts before them and before character n
+func (w *BufWindow) stringWidth(y, n int) int {
+	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	line := w.Buf.LineBytes(y)
+	inline := w.inlineTexts(y)
+	if inline == nil {
+		return util.StringWidth(line, n, tabsize)
+	}
+
+	width := 0
+	for i := 0; len(line) > 0 && i <= n; i++ {
+		width += inline.width(i)
+		if i == n {
+			break
+		}
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+
+		switch r {
+		case '\t':
+			width += tabsize - (width % tabsize)
+		default:
+			width += runewidth.RuneWidth(r)
+		}
+	}
+	return width
+}
+
+// charPosInLine returns the index of the character of line y displayed at
+// the visual position visualPos, which is the following character for the
+// inline virtual texts
+func (w *BufWindow) charPosInLine(y, visualPos int) int {
+	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	line := w.Buf.LineBytes(y)
+	inline := w.inlineTexts(y)
+	if inline == nil {
+		return util.GetCharPosInLine(line, visualPos, tabsize)
+	}
+
+	i := 0
+	width := 0
+	for len(line) > 0 {
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+
+		width += inline.width(i)
+		switch r {
+		case '\t':
+			width += tabsize - (width % tabsize)
+		default:
+			width += runewidth.RuneWidth(r)
+		}
+
+		if width >= visualPos {
+			if width == visualPos {
+				i++
+			}
+			break
+		}
+		i++
+	}
+	return i
+}
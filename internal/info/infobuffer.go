@@ -33,6 +33,9 @@ type InfoBuf struct {
 	// Is the current message a message from the gutter
 	HasGutter bool
 
+	// Picker is the list of items shown above the prompt, if any
+	Picker *Picker
+
 	PromptCallback func(resp string, canceled bool)
 	EventCallback  func(resp string)
 	YNCallback     func(yes bool, canceled bool)
@@ -137,6 +140,7 @@ func (i *InfoBuf) YNPrompt(prompt string, donecb func(bool, bool)) {
 func (i *InfoBuf) DonePrompt(canceled bool) {
 	hadYN := i.HasYN
 	i.HasPrompt = false
+	i.closePicker()
 	i.HasYN = false
 	i.HasGutter = false
 	if !hadYN {
@@ -170,9 +174,18 @@ func (i *InfoBuf) DonePrompt(canceled bool) {
 	}
 }
 
+// closePicker removes the picker of the prompt, if any
+func (i *InfoBuf) closePicker() {
+	if i.Picker != nil && i.Picker.OnClose != nil {
+		i.Picker.OnClose()
+	}
+	i.Picker = nil
+}
+
 // Reset resets the infobuffer's msg and info
 func (i *InfoBuf) Reset() {
 	i.Msg = ""
 	i.HasPrompt, i.HasMessage, i.HasError = false, false, false
+	i.closePicker()
 	i.HasGutter = false
 }
@@ -0,0 +1,182 @@
+package info
+
+import (
+	"sort"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A PickerMatch is an item of a picker matching the filter
+type PickerMatch struct {
+	// Index of the item in the picker
+	Index int
+	Score int
+}
+
+// A Picker is a list of items displayed above the info bar during a
+// prompt. The items are filtered and ranked with fuzzy matching against the
+// text of the prompt, and one of them can be selected.
+type Picker struct {
+	Title string
+	Items []string
+	// Details are optional texts displayed next to the items, which are not
+	// used to filter them
+	Details []string
+	// Preview returns the text previewed for the selected item. It can be
+	// nil if the picker has no preview.
+	Preview func(index int) string
+	// OnSelect is called with the index of the selected item when the
+	// selection changes. It can be nil.
+	OnSelect func(index int)
+	// OnClose is called when the picker is removed from the info bar,
+	// whether an item was chosen or not. It can be nil.
+	OnClose func()
+
+	// Matches are the items matching the filter, best first
+	Matches []PickerMatch
+	// Selected is the index of the selected match
+	Selected int
+	// Top is the index of the first match displayed
+	Top int
+	// Loading indicates that more items are going to be added
+	Loading bool
+
+	filter string
+
+	previewIndex int
+	previewText  string
+
+	selectIndex int
+}
+
+// NewPicker returns a new picker showing the given items
+func NewPicker(title string, items []string) *Picker {
+	p := new(Picker)
+	p.Title = title
+	p.previewIndex = -1
+	p.selectIndex = -1
+	p.AddItems(items)
+	return p
+}
+
+// match returns the matches of the filter among the items starting at the
+// given index
+func (p *Picker) match(start int) []PickerMatch {
+	var matches []PickerMatch
+	for i := start; i < len(p.Items); i++ {
+		if score, ok := util.FuzzyScore(p.filter, p.Items[i]); ok {
+			matches = append(matches, PickerMatch{i, score})
+		}
+	}
+	return matches
+}
+
+func (p *Picker) sort() {
+	sort.SliceStable(p.Matches, func(i, j int) bool {
+		a, b := p.Matches[i], p.Matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return len(p.Items[a.Index]) < len(p.Items[b.Index])
+	})
+}
+
+// AddItems adds items to the picker
+func (p *Picker) AddItems(items []string) {
+	start := len(p.Items)
+	p.Items = append(p.Items, items...)
+	p.Matches = append(p.Matches, p.match(start)...)
+	if p.filter != "" {
+		p.sort()
+	}
+	p.selectionChanged()
+}
+
+// Filter keeps only the items matching the given pattern, ranked by their
+// fuzzy matching score, and selects the best one
+func (p *Picker) Filter(pattern string) {
+	p.filter = pattern
+	p.Matches = p.match(0)
+	if pattern != "" {
+		p.sort()
+	}
+	p.Selected = 0
+	p.Top = 0
+	p.selectionChanged()
+}
+
+// FilterText returns the pattern the items are filtered with
+func (p *Picker) FilterText() string {
+	return p.filter
+}
+
+// MatchPositions returns the indices of the runes of the given match which
+// are matched by the filter
+func (p *Picker) MatchPositions(m PickerMatch) []int {
+	_, pos, _ := util.FuzzyMatch(p.filter, p.Items[m.Index])
+	return pos
+}
+
+// Move moves the selection by n matches
+func (p *Picker) Move(n int) {
+	if len(p.Matches) == 0 {
+		return
+	}
+	p.Selected = util.Clamp(p.Selected+n, 0, len(p.Matches)-1)
+	p.selectionChanged()
+}
+
+// Select selects the given item if it matches the filter, without calling
+// OnSelect
+func (p *Picker) Select(index int) {
+	for i, m := range p.Matches {
+		if m.Index == index {
+			p.Selected = i
+			p.selectIndex = index
+			return
+		}
+	}
+}
+
+// selectionChanged calls OnSelect if the selected item has changed
+func (p *Picker) selectionChanged() {
+	i, ok := p.Selection()
+	if !ok || i == p.selectIndex {
+		return
+	}
+	p.selectIndex = i
+	if p.OnSelect != nil {
+		p.OnSelect(i)
+	}
+}
+
+// Selection returns the index of the selected item, and false if no item
+// is selected
+func (p *Picker) Selection() (int, bool) {
+	if p.Selected >= len(p.Matches) {
+		return -1, false
+	}
+	return p.Matches[p.Selected].Index, true
+}
+
+// Detail returns the detail text of the given item
+func (p *Picker) Detail(index int) string {
+	if index < len(p.Details) {
+		return p.Details[index]
+	}
+	return ""
+}
+
+// PreviewText returns the preview of the selected item, which is only
+// computed again when the selection changes
+func (p *Picker) PreviewText() string {
+	i, ok := p.Selection()
+	if p.Preview == nil || !ok {
+		return ""
+	}
+	if i != p.previewIndex {
+		p.previewIndex = i
+		p.previewText = p.Preview(i)
+	}
+	return p.previewText
+}
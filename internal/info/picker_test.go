@@ -0,0 +1,87 @@
+package info
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// matched returns the items matching the filter of the picker, best first
+func matched(p *Picker) []string {
+	var items []string
+	for _, m := range p.Matches {
+		items = append(items, p.Items[m.Index])
+	}
+	return items
+}
+
+func TestPickerFilter(t *testing.T) {
+	p := NewPicker("Files", []string{"main.go", "internal/action/actions.go", "README.md", "cmd/micro/micro.go"})
+	// all items are listed in order without a filter
+	assert.Equal(t, p.Items, matched(p))
+
+	p.Filter("mi")
+	assert.Equal(t, []string{"cmd/micro/micro.go", "main.go"}, matched(p))
+	i, ok := p.Selection()
+	assert.True(t, ok)
+	assert.Equal(t, 3, i)
+	assert.Equal(t, "mi", p.FilterText())
+
+	// the items added later are filtered and ranked too
+	p.AddItems([]string{"micro", "other"})
+	assert.Equal(t, []string{"micro", "cmd/micro/micro.go", "main.go"}, matched(p))
+
+	p.Filter("xyz")
+	assert.Empty(t, p.Matches)
+	_, ok = p.Selection()
+	assert.False(t, ok)
+}
+
+func TestPickerSelect(t *testing.T) {
+	var selected []int
+	p := NewPicker("Items", []string{"a", "b", "c"})
+	p.OnSelect = func(i int) {
+		selected = append(selected, i)
+	}
+
+	p.Move(1)
+	p.Move(5)
+	p.Move(-1)
+	assert.Equal(t, 1, p.Selected)
+	assert.Equal(t, []int{1, 2, 1}, selected)
+
+	// Select does not call OnSelect, and OnSelect is only called when the
+	// selection changes
+	p.Select(2)
+	assert.Equal(t, 2, p.Selected)
+	p.Move(0)
+	p.Filter("")
+	assert.Equal(t, []int{1, 2, 1, 0}, selected)
+
+	// an item not matching the filter is not selected
+	p.Filter("b")
+	p.Select(2)
+	i, _ := p.Selection()
+	assert.Equal(t, 1, i)
+}
+
+func TestPickerPreview(t *testing.T) {
+	calls := 0
+	p := NewPicker("Items", []string{"a", "b"})
+	p.Details = []string{"first"}
+	assert.Equal(t, "", p.PreviewText())
+
+	p.Preview = func(i int) string {
+		calls++
+		return p.Items[i] + " preview"
+	}
+	assert.Equal(t, "a preview", p.PreviewText())
+	assert.Equal(t, "a preview", p.PreviewText())
+	assert.Equal(t, 1, calls)
+	p.Move(1)
+	assert.Equal(t, "b preview", p.PreviewText())
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, "first", p.Detail(0))
+	assert.Equal(t, "", p.Detail(1))
+}
@@ -0,0 +1,310 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Timeout is the maximum time to wait for the response to a request
+var Timeout = 10 * time.Second
+
+// A Client is a connection to a language server running as a subprocess
+// and communicating over its standard input and output
+type Client struct {
+	Name string
+
+	cmd      *exec.Cmd
+	conn     *Conn
+	sync     syncOptions
+	exited   chan struct{}
+	notified func(method string, params json.RawMessage)
+}
+
+// NewClient starts the language server with the given command and
+// arguments in the root directory and initializes it. The notifications
+// sent by the server are passed to handler, which is called from a
+// separate goroutine.
+func NewClient(name string, args []string, root string, handler func(method string, params json.RawMessage)) (*Client, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		Name:     name,
+		cmd:      cmd,
+		exited:   make(chan struct{}),
+		notified: handler,
+	}
+	c.conn = NewConn(stdout, stdin, c.handle)
+	go func() {
+		cmd.Wait()
+		c.conn.Close(errors.New("The language server " + name + " exited"))
+		close(c.exited)
+	}()
+
+	var result struct {
+		Capabilities serverCapabilities `json:"capabilities"`
+	}
+	err = c.conn.Call("initialize", map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   FileURI(root),
+		"clientInfo": map[string]string{
+			"name": "micro",
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization": map[string]any{
+					"didSave": true,
+				},
+				"hover": map[string]any{
+					"contentFormat": []string{"markdown", "plaintext"},
+				},
+				"publishDiagnostics": map[string]any{},
+			},
+			"workspace": map[string]any{
+				"configuration": true,
+			},
+		},
+	}, &result, Timeout)
+	if err != nil {
+		c.Kill()
+		return nil, err
+	}
+	c.sync = result.Capabilities.TextDocumentSync
+	c.conn.Notify("initialized", struct{}{})
+	return c, nil
+}
+
+// handle answers the requests of the server and forwards its notifications.
+// The unknown requests are answered with a MethodNotFound error.
+func (c *Client) handle(method string, params json.RawMessage, request bool) (any, error) {
+	if !request {
+		if c.notified != nil {
+			c.notified(method, params)
+		}
+		return nil, nil
+	}
+
+	switch method {
+	case "workspace/configuration":
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		return nil, nil
+	case "workspace/applyEdit":
+		return map[string]bool{"applied": false}, nil
+	}
+	return nil, &ResponseError{CodeMethodNotFound, "Method not found: " + method}
+}
+
+// Done returns a channel which is closed when the connection to the server
+// is lost
+func (c *Client) Done() <-chan struct{} {
+	return c.conn.Done()
+}
+
+// Err returns the reason why the connection to the server was lost
+func (c *Client) Err() error {
+	return c.conn.Err()
+}
+
+// SyncKind returns how the server wants the changes of the documents to be
+// sent
+func (c *Client) SyncKind() TextDocumentSyncKind {
+	return c.sync.Change
+}
+
+// DidOpen notifies the server that a document has been opened
+func (c *Client) DidOpen(uri, languageID string, version int, text string) error {
+	return c.conn.Notify("textDocument/didOpen", map[string]any{
+		"textDocument": textDocumentItem{uri, languageID, version, text},
+	})
+}
+
+// DidChange notifies the server that a document has been modified
+func (c *Client) DidChange(uri string, version int, changes []TextDocumentContentChangeEvent) error {
+	return c.conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   versionedTextDocumentIdentifier{uri, version},
+		"contentChanges": changes,
+	})
+}
+
+// DidSave notifies the server that a document has been saved. The text is
+// only sent if the server asked for it.
+func (c *Client) DidSave(uri, text string) error {
+	params := map[string]any{
+		"textDocument": textDocumentIdentifier{uri},
+	}
+	if c.sync.IncludeText {
+		params["text"] = text
+	}
+	return c.conn.Notify("textDocument/didSave", params)
+}
+
+// DidClose notifies the server that a document has been closed
+func (c *Client) DidClose(uri string) error {
+	return c.conn.Notify("textDocument/didClose", map[string]any{
+		"textDocument": textDocumentIdentifier{uri},
+	})
+}
+
+// Hover returns the documentation of the symbol at the given position, or
+// an empty string if there is none
+func (c *Client) Hover(uri string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.conn.Call("textDocument/hover", textDocumentPositionParams{textDocumentIdentifier{uri}, pos}, &result, Timeout); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return markupText(result.Contents), nil
+}
+
+// locations decodes a Location, an array of Locations or an array of
+// LocationLinks
+func locations(data json.RawMessage) ([]Location, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var loc Location
+	if data[0] == '{' {
+		err := json.Unmarshal(data, &loc)
+		return []Location{loc}, err
+	}
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange *Range `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	locs := make([]Location, 0, len(items))
+	for _, item := range items {
+		if item.TargetURI != "" && item.TargetSelectionRange != nil {
+			locs = append(locs, Location{item.TargetURI, *item.TargetSelectionRange})
+		} else {
+			locs = append(locs, item.Location)
+		}
+	}
+	return locs, nil
+}
+
+// Definition returns the locations where the symbol at the given position
+// is defined
+func (c *Client) Definition(uri string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.conn.Call("textDocument/definition", textDocumentPositionParams{textDocumentIdentifier{uri}, pos}, &result, Timeout); err != nil {
+		return nil, err
+	}
+	return locations(result)
+}
+
+// References returns the locations where the symbol at the given position
+// is used, including its declaration
+func (c *Client) References(uri string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	err := c.conn.Call("textDocument/references", map[string]any{
+		"textDocument": textDocumentIdentifier{uri},
+		"position":     pos,
+		"context": map[string]bool{
+			"includeDeclaration": true,
+		},
+	}, &result, Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return locations(result)
+}
+
+// Rename returns the edits, by document URI, renaming the symbol at the
+// given position to newName
+func (c *Client) Rename(uri string, pos Position, newName string) (map[string][]TextEdit, error) {
+	var result *struct {
+		Changes         map[string][]TextEdit `json:"changes"`
+		DocumentChanges []struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+			Edits        []TextEdit             `json:"edits"`
+		} `json:"documentChanges"`
+	}
+	err := c.conn.Call("textDocument/rename", map[string]any{
+		"textDocument": textDocumentIdentifier{uri},
+		"position":     pos,
+		"newName":      newName,
+	}, &result, Timeout)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	edits := make(map[string][]TextEdit)
+	for u, e := range result.Changes {
+		edits[u] = append(edits[u], e...)
+	}
+	for _, change := range result.DocumentChanges {
+		// file operations have no text document and are not supported
+		if change.TextDocument.URI != "" {
+			edits[change.TextDocument.URI] = append(edits[change.TextDocument.URI], change.Edits...)
+		}
+	}
+	return edits, nil
+}
+
+// Formatting returns the edits formatting the whole document
+func (c *Client) Formatting(uri string, tabSize int, insertSpaces bool) ([]TextEdit, error) {
+	var edits []TextEdit
+	err := c.conn.Call("textDocument/formatting", map[string]any{
+		"textDocument": textDocumentIdentifier{uri},
+		"options": map[string]any{
+			"tabSize":      tabSize,
+			"insertSpaces": insertSpaces,
+		},
+	}, &edits, Timeout)
+	return edits, err
+}
+
+// SortEdits sorts text edits by position
+func SortEdits(edits []TextEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+}
+
+// Shutdown asks the server to exit, and kills it if it does not
+func (c *Client) Shutdown() {
+	if c.conn.Call("shutdown", nil, nil, time.Second) == nil {
+		c.conn.Notify("exit", nil)
+	}
+	select {
+	case <-c.exited:
+	case <-time.After(time.Second):
+		c.Kill()
+	}
+}
+
+// Kill kills the server
+func (c *Client) Kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,278 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The test binary runs itself as a small language server when this
+// environment variable is set
+const fakeServerEnv = "MICRO_TEST_LSP_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		runFakeServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// offset returns the byte offset of a UTF-16 position in text
+func offset(text string, pos Position) int {
+	off := 0
+	for i := 0; i < pos.Line; i++ {
+		off += strings.IndexByte(text[off:], '\n') + 1
+	}
+	units := 0
+	for i, r := range text[off:] {
+		if units >= pos.Character || r == '\n' {
+			return off + i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(text)
+}
+
+// runFakeServer serves the language server protocol on the standard input
+// and output. It stores the documents it is sent and answers hover requests
+// with the text of the document, so that the synchronization can be
+// checked.
+func runFakeServer() {
+	var mu sync.Mutex
+	docs := make(map[string]string)
+	exit := make(chan struct{})
+
+	var conn *Conn
+	conn = NewConn(os.Stdin, os.Stdout, func(method string, params json.RawMessage, request bool) (any, error) {
+		var p struct {
+			TextDocument   textDocumentItem                 `json:"textDocument"`
+			ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+			Position       Position                         `json:"position"`
+			NewName        string                           `json:"newName"`
+		}
+		json.Unmarshal(params, &p)
+		uri := p.TextDocument.URI
+		word := Range{p.Position, Position{p.Position.Line, p.Position.Character + 3}}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch method {
+		case "initialize":
+			return map[string]any{
+				"capabilities": map[string]any{
+					"textDocumentSync": map[string]any{
+						"openClose": true,
+						"change":    SyncIncremental,
+						"save":      map[string]bool{"includeText": true},
+					},
+				},
+			}, nil
+		case "textDocument/didOpen":
+			docs[uri] = p.TextDocument.Text
+			conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+				URI: uri,
+				Diagnostics: []Diagnostic{{
+					Range:    Range{Position{0, 0}, Position{0, 1}},
+					Severity: SeverityWarning,
+					Message:  "opened",
+				}},
+			})
+		case "textDocument/didChange":
+			for _, change := range p.ContentChanges {
+				text := docs[uri]
+				if change.Range == nil {
+					docs[uri] = change.Text
+				} else {
+					start, end := offset(text, change.Range.Start), offset(text, change.Range.End)
+					docs[uri] = text[:start] + change.Text + text[end:]
+				}
+			}
+		case "textDocument/hover":
+			return map[string]any{
+				"contents": map[string]string{"kind": "plaintext", "value": docs[uri]},
+			}, nil
+		case "textDocument/definition":
+			return []map[string]any{{
+				"targetUri":            uri,
+				"targetRange":          word,
+				"targetSelectionRange": word,
+			}}, nil
+		case "textDocument/references":
+			return []Location{{uri, word}, {uri, Range{Position{1, 0}, Position{1, 3}}}}, nil
+		case "textDocument/rename":
+			return map[string]any{
+				"documentChanges": []any{map[string]any{
+					"textDocument": versionedTextDocumentIdentifier{uri, 1},
+					"edits":        []TextEdit{{word, p.NewName}},
+				}},
+			}, nil
+		case "textDocument/formatting":
+			return []TextEdit{{Range{Position{0, 0}, Position{0, 0}}, "// formatted\n"}}, nil
+		case "textDocument/crash":
+			return nil, errors.New("crashed")
+		case "shutdown":
+			return nil, nil
+		case "exit":
+			close(exit)
+		}
+		return nil, nil
+	})
+
+	select {
+	case <-exit:
+	case <-conn.Done():
+	}
+}
+
+func startFakeServer(t *testing.T, handler func(method string, params json.RawMessage)) *Client {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(fakeServerEnv, "1")
+	c, err := NewClient(exe, nil, t.TempDir(), handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Shutdown)
+	return c
+}
+
+func TestURI(t *testing.T) {
+	uri := FileURI("/tmp/a b/c.go")
+	assert.Equal(t, "file:///tmp/a%20b/c.go", uri)
+	assert.Equal(t, "/tmp/a b/c.go", URIPath(uri))
+}
+
+func TestSync(t *testing.T) {
+	diagnostics := make(chan PublishDiagnosticsParams, 1)
+	c := startFakeServer(t, func(method string, params json.RawMessage) {
+		if method == "textDocument/publishDiagnostics" {
+			var p PublishDiagnosticsParams
+			json.Unmarshal(params, &p)
+			diagnostics <- p
+		}
+	})
+	assert.Equal(t, SyncIncremental, c.SyncKind())
+
+	uri := FileURI("/test.go")
+	assert.NoError(t, c.DidOpen(uri, "go", 1, "package main\n\nfunc 😀() {}\n"))
+
+	select {
+	case p := <-diagnostics:
+		assert.Equal(t, uri, p.URI)
+		assert.Len(t, p.Diagnostics, 1)
+		assert.Equal(t, "opened", p.Diagnostics[0].Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no diagnostics received")
+	}
+
+	// the emoji is two UTF-16 code units long
+	assert.NoError(t, c.DidChange(uri, 2, []TextDocumentContentChangeEvent{
+		{&Range{Position{2, 5}, Position{2, 7}}, "foo"},
+		{&Range{Position{0, 8}, Position{0, 12}}, "test"},
+		{&Range{Position{1, 0}, Position{1, 0}}, "// a\n// b"},
+	}))
+	text, err := c.Hover(uri, Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, "package test\n// a\n// b\nfunc foo() {}\n", text)
+
+	assert.NoError(t, c.DidChange(uri, 3, []TextDocumentContentChangeEvent{{nil, "full"}}))
+	text, err = c.Hover(uri, Position{})
+	assert.NoError(t, err)
+	assert.Equal(t, "full", text)
+}
+
+func TestRequests(t *testing.T) {
+	c := startFakeServer(t, nil)
+	uri := FileURI("/test.go")
+	pos := Position{3, 5}
+	word := Range{pos, Position{3, 8}}
+
+	locs, err := c.Definition(uri, pos)
+	assert.NoError(t, err)
+	assert.Equal(t, []Location{{uri, word}}, locs)
+
+	locs, err = c.References(uri, pos)
+	assert.NoError(t, err)
+	assert.Len(t, locs, 2)
+
+	edits, err := c.Rename(uri, pos, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]TextEdit{uri: {{word, "bar"}}}, edits)
+
+	formatting, err := c.Formatting(uri, 4, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []TextEdit{{Range{}, "// formatted\n"}}, formatting)
+
+	err = c.conn.Call("textDocument/crash", nil, nil, Timeout)
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr))
+	assert.Equal(t, "crashed", err.Error())
+}
+
+func TestServerRequests(t *testing.T) {
+	var mu sync.Mutex
+	var notified []string
+	c := &Client{notified: func(method string, params json.RawMessage) {
+		mu.Lock()
+		notified = append(notified, method)
+		mu.Unlock()
+	}}
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c.conn = NewConn(clientIn, clientOut, c.handle)
+	server := NewConn(serverIn, serverOut, nil)
+	defer clientOut.Close()
+	defer serverOut.Close()
+
+	var result []any
+	err := server.Call("workspace/configuration", map[string]any{"items": []any{1, 2}}, &result, Timeout)
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	// the unknown requests are answered with an error, as the protocol
+	// requires
+	err = server.Call("custom/request", nil, nil, Timeout)
+	var respErr *ResponseError
+	if assert.True(t, errors.As(err, &respErr)) {
+		assert.Equal(t, CodeMethodNotFound, respErr.Code)
+	}
+	// only the notifications are forwarded
+	assert.NoError(t, server.Notify("custom/notification", nil))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(notified) > 0
+	}, Timeout, time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"custom/notification"}, notified)
+	mu.Unlock()
+}
+
+func TestMarkupText(t *testing.T) {
+	assert.Equal(t, "a", markupText(json.RawMessage(`"a"`)))
+	assert.Equal(t, "b", markupText(json.RawMessage(`{"kind":"markdown","value":"b"}`)))
+	assert.Equal(t, "a\n\n```go\nfunc f()\n```", markupText(json.RawMessage(`["a",{"language":"go","value":"func f()"}]`)))
+}
+
+func TestSortEdits(t *testing.T) {
+	edits := []TextEdit{
+		{Range{Position{2, 0}, Position{2, 1}}, "c"},
+		{Range{Position{0, 4}, Position{0, 5}}, "b"},
+		{Range{Position{0, 1}, Position{0, 2}}, "a"},
+	}
+	SortEdits(edits)
+	assert.Equal(t, "a", edits[0].NewText)
+	assert.Equal(t, "b", edits[1].NewText)
+	assert.Equal(t, "c", edits[2].NewText)
+}
@@ -0,0 +1,276 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error codes defined by JSON-RPC and the language server protocol
+const (
+	CodeMethodNotFound   = -32601
+	CodeInternalError    = -32603
+	CodeRequestCancelled = -32800
+)
+
+// ErrTimeout is returned when the response to a request does not arrive in
+// time
+var ErrTimeout = errors.New("The language server did not respond in time")
+
+// A ResponseError is an error returned in response to a request
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// A message is a JSON-RPC request, notification or response. Requests and
+// responses have an ID, requests and notifications have a method.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// A Handler handles the requests and notifications received on a
+// connection, request telling which one it is. The result is sent back for
+// requests and ignored for notifications.
+type Handler func(method string, params json.RawMessage, request bool) (any, error)
+
+// A Conn is a JSON-RPC connection using the base protocol of the language
+// server protocol, where each message is preceded by a Content-Length
+// header. Messages are written by a separate goroutine so that sending a
+// notification never blocks.
+type Conn struct {
+	w       io.Writer
+	handler Handler
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	queue   [][]byte
+	err     error
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// NewConn returns a connection reading messages from r and writing them to
+// w. Incoming requests and notifications are passed to handler.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	c := &Conn{
+		w:       w,
+		handler: handler,
+		pending: make(map[int]chan *message),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	go c.writeLoop()
+	return c
+}
+
+// Done returns a channel which is closed when the connection is closed
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error which closed the connection
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection, making the pending and future requests fail
+// with the given error
+func (c *Conn) Close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+func (c *Conn) send(m *message) error {
+	m.JSONRPC = "2.0"
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.queue = append(c.queue, data)
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *Conn) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+		}
+
+		c.mu.Lock()
+		queue := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+
+		for _, data := range queue {
+			if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+				c.Close(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) readLoop(r *bufio.Reader) {
+	tr := textproto.NewReader(r)
+	for {
+		header, err := tr.ReadMIMEHeader()
+		if err != nil {
+			c.Close(err)
+			return
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+		if err != nil {
+			c.Close(fmt.Errorf("Invalid Content-Length: %q", header.Get("Content-Length")))
+			return
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			c.Close(err)
+			return
+		}
+
+		m := new(message)
+		if err := json.Unmarshal(data, m); err != nil {
+			continue
+		}
+		if m.Method != "" {
+			c.handle(m)
+		} else if m.ID != nil {
+			var id int
+			if err := json.Unmarshal(*m.ID, &id); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+		}
+	}
+}
+
+// handle runs the handler for a request or a notification and sends the
+// response of requests
+func (c *Conn) handle(m *message) {
+	var result any
+	var err error
+	if c.handler != nil {
+		result, err = c.handler(m.Method, m.Params, m.ID != nil)
+	} else if m.ID != nil {
+		err = &ResponseError{CodeMethodNotFound, "Method not found: " + m.Method}
+	}
+	if m.ID == nil {
+		return
+	}
+
+	resp := &message{ID: m.ID}
+	if err != nil {
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) {
+			respErr = &ResponseError{CodeInternalError, err.Error()}
+		}
+		resp.Error = respErr
+	} else if resp.Result, err = json.Marshal(result); err != nil {
+		resp.Error = &ResponseError{CodeInternalError, err.Error()}
+	}
+	c.send(resp)
+}
+
+// Notify sends a notification
+func (c *Conn) Notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.send(&message{Method: method, Params: data})
+}
+
+// Call sends a request and waits for its response, which is decoded into
+// result unless it is nil. The request is cancelled if there is no response
+// after the given timeout.
+func (c *Conn) Call(method string, params, result any, timeout time.Duration) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	rawID := json.RawMessage(strconv.Itoa(id))
+	if err := c.send(&message{ID: &rawID, Method: method, Params: data}); err != nil {
+		c.forget(id)
+		return err
+	}
+
+	select {
+	case m := <-ch:
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil && len(m.Result) > 0 {
+			return json.Unmarshal(m.Result, result)
+		}
+		return nil
+	case <-c.done:
+		c.forget(id)
+		return c.Err()
+	case <-time.After(timeout):
+		c.forget(id)
+		c.Notify("$/cancelRequest", map[string]int{"id": id})
+		return ErrTimeout
+	}
+}
+
+func (c *Conn) forget(id int) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
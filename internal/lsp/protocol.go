@@ -0,0 +1,199 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// A Position is a location in a document. Character is an offset in UTF-16
+// code units in the line.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// A Range is a part of a document. The end is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// A Location is a range in a document
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// A TextEdit replaces a range of a document by a new text
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// A TextDocumentContentChangeEvent is a change of a document. The whole
+// document is replaced if Range is nil.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// TextDocumentSyncKind defines how the changes of the documents are sent to
+// the server
+type TextDocumentSyncKind int
+
+const (
+	// SyncNone means that the changes are not sent
+	SyncNone TextDocumentSyncKind = 0
+	// SyncFull means that the whole document is sent after each change
+	SyncFull TextDocumentSyncKind = 1
+	// SyncIncremental means that only the changes are sent
+	SyncIncremental TextDocumentSyncKind = 2
+)
+
+// DiagnosticSeverity is the severity of a diagnostic
+type DiagnosticSeverity int
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+// A Diagnostic is an error or a warning about a range of a document
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+// PublishDiagnosticsParams are the parameters of the
+// textDocument/publishDiagnostics notification
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// MessageType is the type of a message shown by the server
+type MessageType int
+
+const (
+	MessageError   MessageType = 1
+	MessageWarning MessageType = 2
+	MessageInfo    MessageType = 3
+	MessageLog     MessageType = 4
+)
+
+// ShowMessageParams are the parameters of the window/showMessage and
+// window/logMessage notifications
+type ShowMessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type versionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// syncOptions are the textDocumentSync capabilities of a server, which are
+// either a TextDocumentSyncKind or an object
+type syncOptions struct {
+	Change      TextDocumentSyncKind
+	IncludeText bool
+}
+
+func (s *syncOptions) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Change); err == nil {
+		return nil
+	}
+	var opts struct {
+		Change TextDocumentSyncKind `json:"change"`
+		Save   json.RawMessage      `json:"save"`
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return err
+	}
+	s.Change = opts.Change
+	var save struct {
+		IncludeText bool `json:"includeText"`
+	}
+	if json.Unmarshal(opts.Save, &save) == nil {
+		s.IncludeText = save.IncludeText
+	}
+	return nil
+}
+
+type serverCapabilities struct {
+	TextDocumentSync syncOptions `json:"textDocumentSync"`
+}
+
+// markupText returns the text of hover contents, which are a MarkupContent,
+// a MarkedString or an array of MarkedStrings
+func markupText(data json.RawMessage) string {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s
+	}
+	var markup struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(data, &markup) == nil {
+		if markup.Language != "" {
+			return "```" + markup.Language + "\n" + markup.Value + "\n```"
+		}
+		return markup.Value
+	}
+	var array []json.RawMessage
+	if json.Unmarshal(data, &array) == nil {
+		parts := make([]string, 0, len(array))
+		for _, a := range array {
+			parts = append(parts, markupText(a))
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// FileURI returns the file URI of the given absolute path
+func FileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// URIPath returns the path of the given file URI
+func URIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
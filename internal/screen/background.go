@@ -0,0 +1,164 @@
+package screen
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/tcell/v2"
+	"golang.org/x/term"
+)
+
+// backgroundQueryTimeout is how long micro waits for the terminal to report
+// its background color
+const backgroundQueryTimeout = 200 * time.Millisecond
+
+// Some terminals notify the application when their theme changes between
+// light and dark if it enables the mode 2031
+const (
+	themeNotificationsOn  = "\x1b[?2031h"
+	themeNotificationsOff = "\x1b[?2031l"
+	themeDarkSeq          = "\x1b[?997;1n"
+	themeLightSeq         = "\x1b[?997;2n"
+)
+
+// themeNotifications indicates that the theme change notifications are
+// enabled
+var themeNotifications bool
+
+var backgroundParser = regexp.MustCompile(`\]11;rgb:([[:xdigit:]]{1,4})/([[:xdigit:]]{1,4})/([[:xdigit:]]{1,4})`)
+
+// parseBackground returns "light" or "dark" according to the brightness of
+// the color in the answer of the terminal to an OSC 11 query, or an empty
+// string if there is no color in the answer
+func parseBackground(answer []byte) string {
+	m := backgroundParser.FindSubmatch(answer)
+	if m == nil {
+		return ""
+	}
+
+	// each component has 1 to 4 hex digits
+	var rgb [3]float64
+	for i, c := range m[1:] {
+		v, _ := strconv.ParseUint(string(c), 16, 16)
+		rgb[i] = float64(v) / float64(uint64(1)<<(4*len(c))-1)
+	}
+	if 0.299*rgb[0]+0.587*rgb[1]+0.114*rgb[2] > 0.5 {
+		return "light"
+	}
+	return "dark"
+}
+
+// queryBackground asks the terminal for its background color with an OSC 11
+// query and returns "light" or "dark", or an empty string if the terminal
+// does not answer. A device attributes query is sent after it: all terminals
+// answer it, so micro does not wait for the timeout when OSC 11 is not
+// supported. This must be done before tcell reads the terminal.
+func queryBackground() string {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return ""
+	}
+	defer tty.Close()
+
+	// tty.Fd() would disable the read deadline
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var state *term.State
+	conn.Control(func(fd uintptr) {
+		state, err = term.MakeRaw(int(fd))
+	})
+	if err != nil {
+		return ""
+	}
+	defer conn.Control(func(fd uintptr) {
+		term.Restore(int(fd), state)
+	})
+
+	if err := tty.SetReadDeadline(time.Now().Add(backgroundQueryTimeout)); err != nil {
+		return ""
+	}
+	if _, err := tty.WriteString("\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return ""
+	}
+
+	var answer []byte
+	buf := make([]byte, 128)
+	for {
+		n, err := tty.Read(buf)
+		answer = append(answer, buf[:n]...)
+		if err != nil {
+			break
+		}
+		// the answer to the device attributes query is last
+		if i := bytes.Index(answer, []byte("\x1b[?")); i >= 0 && bytes.IndexByte(answer[i:], 'c') >= 0 {
+			break
+		}
+	}
+	return parseBackground(answer)
+}
+
+// writeTTY writes an escape sequence to the terminal outside of tcell
+func writeTTY(seq string) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	tty.WriteString(seq)
+	tty.Close()
+}
+
+// initBackground queries the background of the terminal and enables the
+// theme change notifications when the colorscheme depends on the background
+func initBackground() {
+	if !config.ColorschemeFollowsBackground() {
+		return
+	}
+	if background := queryBackground(); background != "" {
+		if err := config.SetTermBackground(background); err != nil {
+			log.Println("Error setting the colorscheme of the background:", err)
+		}
+	}
+}
+
+// enableThemeNotifications asks the terminal to notify micro when its theme
+// changes, if the colorscheme depends on the background
+func enableThemeNotifications() {
+	if !config.ColorschemeFollowsBackground() {
+		return
+	}
+	Screen.RegisterRawSeq(themeDarkSeq)
+	Screen.RegisterRawSeq(themeLightSeq)
+	writeTTY(themeNotificationsOn)
+	themeNotifications = true
+}
+
+// BackgroundChange returns the new background of the terminal, "light" or
+// "dark", if the event is a theme change notification
+func BackgroundChange(event tcell.Event) (string, bool) {
+	if e, ok := event.(*tcell.EventRaw); ok {
+		switch e.EscSeq() {
+		case themeDarkSeq:
+			return "dark", true
+		case themeLightSeq:
+			return "light", true
+		}
+	}
+	return "", false
+}
+
+// Fini shuts the screen down, and disables the theme change notifications
+// so that they are not sent to the shell
+func Fini() {
+	if themeNotifications {
+		writeTTY(themeNotificationsOff)
+		themeNotifications = false
+	}
+	Screen.Fini()
+}
@@ -159,7 +159,7 @@ func TempFini() bool {
 	screenWasNil := Screen == nil
 
 	if !screenWasNil {
-		Screen.Fini()
+		Fini()
 		Lock()
 		Screen = nil
 	}
@@ -204,6 +204,8 @@ func Init() error {
 		setXterm()
 	}
 
+	initBackground()
+
 	// Initilize tcell
 	var err error
 	Screen, err = tcell.NewScreen()
@@ -235,6 +237,8 @@ func Init() error {
 		Screen.RegisterRawSeq(r)
 	}
 
+	enableThemeNotifications()
+
 	return nil
 }
 
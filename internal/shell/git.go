@@ -0,0 +1,171 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A GitStatusEntry is a single file reported by `git status --porcelain`
+type GitStatusEntry struct {
+	// Index is the status of the file in the index (staging area)
+	Index byte
+	// Worktree is the status of the file in the working tree
+	Worktree byte
+	// Path is the path of the file relative to the repository root
+	Path string
+	// OrigPath is the original path of a renamed or copied file
+	OrigPath string
+}
+
+// Untracked returns true if the file is not tracked by git
+func (e GitStatusEntry) Untracked() bool {
+	return e.Index == '?' && e.Worktree == '?'
+}
+
+// Staged returns true if the file has changes in the index
+func (e GitStatusEntry) Staged() bool {
+	return e.Index != ' ' && e.Index != '?' && e.Index != '!'
+}
+
+// Unstaged returns true if the file has changes in the working tree
+// that are not staged yet
+func (e GitStatusEntry) Unstaged() bool {
+	return e.Worktree != ' ' && e.Worktree != '?' && e.Worktree != '!'
+}
+
+// Git runs git with the given arguments in the given repository root
+// It returns the standard output of the command, and the error output is
+// used as the error message if git fails. The error output is not returned
+// since git prints warnings there, which would mix with the output parsed
+// by the callers.
+func Git(root string, args ...string) (string, error) {
+	if root != "" {
+		args = append([]string{"-C", root}, args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			return stdout.String(), err
+		}
+		return stdout.String(), errors.New(msg)
+	}
+	return stdout.String(), nil
+}
+
+// GitRoot returns the absolute path of the root of the git repository
+// containing dir
+func GitRoot(dir string) (string, error) {
+	out, err := Git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(strings.TrimSpace(out)), nil
+}
+
+// GitDir returns the absolute path of the .git directory of the repository
+// with the given root
+func GitDir(root string) (string, error) {
+	out, err := Git(root, "rev-parse", "--git-dir")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// GitStatus returns the current branch and the list of changed files
+// in the repository with the given root
+func GitStatus(root string) (string, []GitStatusEntry, error) {
+	out, err := Git(root, "status", "--porcelain", "-b", "-z")
+	if err != nil {
+		return "", nil, err
+	}
+	branch, entries := parseGitStatus(out)
+	return branch, entries, nil
+}
+
+// parseGitStatus parses the output of `git status --porcelain -b -z`
+func parseGitStatus(out string) (string, []GitStatusEntry) {
+	var branch string
+	var entries []GitStatusEntry
+
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, "## ") {
+			branch = f[3:]
+			continue
+		}
+		if len(f) < 4 {
+			continue
+		}
+		e := GitStatusEntry{
+			Index:    f[0],
+			Worktree: f[1],
+			Path:     f[3:],
+		}
+		if (e.Index == 'R' || e.Index == 'C') && i+1 < len(fields) {
+			// renames and copies are followed by the original path
+			i++
+			e.OrigPath = fields[i]
+		}
+		entries = append(entries, e)
+	}
+	return branch, entries
+}
+
+// GitStage adds the given file to the index
+func GitStage(root, path string) error {
+	_, err := Git(root, "add", "--", path)
+	return err
+}
+
+// GitUnstage removes the given file from the index, keeping the changes
+// in the working tree
+func GitUnstage(root, path string) error {
+	_, err := Git(root, "reset", "-q", "HEAD", "--", path)
+	if err == nil {
+		return nil
+	}
+	if _, headErr := Git(root, "rev-parse", "-q", "--verify", "HEAD"); headErr != nil {
+		// there is no HEAD yet in a new repository, so all the files of
+		// the index are new
+		_, err = Git(root, "rm", "-q", "--cached", "--", path)
+		return err
+	}
+	if _, restoreErr := Git(root, "restore", "-q", "--staged", "--", path); restoreErr == nil {
+		return nil
+	}
+	return err
+}
+
+// GitDiscard throws away the unstaged changes to the given file.
+// Untracked files are deleted.
+func GitDiscard(root string, e GitStatusEntry) error {
+	var err error
+	if e.Untracked() {
+		_, err = Git(root, "clean", "-f", "-q", "--", e.Path)
+	} else {
+		_, err = Git(root, "checkout", "-q", "--", e.Path)
+	}
+	return err
+}
+
+// GitCommit creates a commit using the message stored in the given file.
+// Comment lines and surrounding whitespace are stripped from the message.
+func GitCommit(root, msgFile string) (string, error) {
+	return Git(root, "commit", "--cleanup=strip", "-F", msgFile)
+}
@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	var tests = []struct {
+		out      string
+		branch   string
+		entries  []GitStatusEntry
+		staged   []bool
+		unstaged []bool
+	}{
+		{
+			out:    "## master\x00",
+			branch: "master",
+		},
+		{
+			out:    "## master...origin/master [ahead 1]\x00M  staged.go\x00 M unstaged.go\x00MM both.go\x00",
+			branch: "master...origin/master [ahead 1]",
+			entries: []GitStatusEntry{
+				{'M', ' ', "staged.go", ""},
+				{' ', 'M', "unstaged.go", ""},
+				{'M', 'M', "both.go", ""},
+			},
+			staged:   []bool{true, false, true},
+			unstaged: []bool{false, true, true},
+		},
+		{
+			// with -z, the new path comes first and paths are not quoted
+			out:    "## dev\x00R  new name.go\x00old name.go\x00C  copy.go\x00orig.go\x00RM moved.go\x00was.go\x00",
+			branch: "dev",
+			entries: []GitStatusEntry{
+				{'R', ' ', "new name.go", "old name.go"},
+				{'C', ' ', "copy.go", "orig.go"},
+				{'R', 'M', "moved.go", "was.go"},
+			},
+			staged:   []bool{true, true, true},
+			unstaged: []bool{false, false, true},
+		},
+		{
+			out:    "## HEAD (no branch)\x00A  added.go\x00AM added2.go\x00D  removed.go\x00 D deleted.go\x00?? new.go\x00UU conflict.go\x00",
+			branch: "HEAD (no branch)",
+			entries: []GitStatusEntry{
+				{'A', ' ', "added.go", ""},
+				{'A', 'M', "added2.go", ""},
+				{'D', ' ', "removed.go", ""},
+				{' ', 'D', "deleted.go", ""},
+				{'?', '?', "new.go", ""},
+				{'U', 'U', "conflict.go", ""},
+			},
+			staged:   []bool{true, true, true, false, false, true},
+			unstaged: []bool{false, true, false, true, false, true},
+		},
+	}
+
+	for _, test := range tests {
+		branch, entries := parseGitStatus(test.out)
+		assert.Equal(t, test.branch, branch)
+		assert.Equal(t, test.entries, entries)
+		for i, e := range entries {
+			assert.Equal(t, test.staged[i], e.Staged(), e.Path)
+			assert.Equal(t, test.unstaged[i], e.Unstaged(), e.Path)
+			assert.Equal(t, e.Index == '?', e.Untracked(), e.Path)
+		}
+	}
+}
+
+func TestGitUnstage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		_, err := Git(root, args...)
+		assert.Nil(t, err)
+	}
+	status := func() []GitStatusEntry {
+		_, entries, err := GitStatus(root)
+		assert.Nil(t, err)
+		return entries
+	}
+
+	// without commits, unstaging a file makes it untracked
+	git("init", "-q")
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0644))
+	git("add", "a.txt")
+	assert.Nil(t, GitUnstage(root, "a.txt"))
+	assert.Equal(t, []GitStatusEntry{{'?', '?', "a.txt", ""}}, status())
+
+	// with commits, unstaging a tracked file keeps it tracked
+	git("add", "a.txt")
+	git("commit", "-q", "-m", "init")
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("b\n"), 0644))
+	git("add", "a.txt")
+	assert.Nil(t, GitUnstage(root, "a.txt"))
+	assert.Equal(t, []GitStatusEntry{{' ', 'M', "a.txt", ""}}, status())
+}
+
+func TestGitOutput(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	_, err := Git(root, "init", "-q")
+	assert.Nil(t, err)
+
+	// the messages printed on the error output are not part of the output
+	out, err := Git(root, "checkout", "-b", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "", out)
+	branch, entries, err := GitStatus(root)
+	assert.Nil(t, err)
+	assert.Contains(t, branch, "dev")
+	assert.Empty(t, entries)
+
+	// but they are used as the error message
+	_, err = Git(root, "checkout", "-q", "nonexistent")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "nonexistent")
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"unicode"
+)
+
+// Scores used by the fuzzy matching
+const (
+	fuzzyMatch        = 16
+	fuzzyBoundary     = 8
+	fuzzyCamelCase    = 6
+	fuzzyConsecutive  = 8
+	fuzzyGapStart     = 3
+	fuzzyGapExtension = 1
+)
+
+// fuzzyNone is the score of an impossible match
+const fuzzyNone = -1 << 30
+
+// FuzzyScore returns whether all the runes of pattern appear in s in the
+// same order, and the score of the best such match (higher is better).
+// Matches at the beginning of words and consecutive matched runes get a
+// higher score, while gaps between matched runes are penalized. The match
+// is case-insensitive unless pattern contains an uppercase letter.
+func FuzzyScore(pattern, s string) (int, bool) {
+	score, _, ok := fuzzy([]rune(pattern), []rune(s), false)
+	return score, ok
+}
+
+// FuzzyMatch is like FuzzyScore but also returns the indices of the runes of
+// s matched by the runes of pattern
+func FuzzyMatch(pattern, s string) (int, []int, bool) {
+	return fuzzy([]rune(pattern), []rune(s), true)
+}
+
+// fuzzyBonus returns the bonus for matching the rune s[j]
+func fuzzyBonus(s []rune, j int) int {
+	if j == 0 {
+		return fuzzyBoundary
+	}
+	switch prev := s[j-1]; {
+	case prev == '/' || prev == '\\' || prev == '_' || prev == '-' || prev == '.' || unicode.IsSpace(prev):
+		return fuzzyBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(s[j]):
+		return fuzzyCamelCase
+	}
+	return 0
+}
+
+func fuzzy(pattern, s []rune, positions bool) (int, []int, bool) {
+	m, n := len(pattern), len(s)
+	if m == 0 {
+		return 0, nil, true
+	}
+	if m > n {
+		return 0, nil, false
+	}
+
+	caseSensitive := false
+	for _, r := range pattern {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	eq := func(a, b rune) bool {
+		if caseSensitive {
+			return a == b
+		}
+		return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// check that pattern is a subsequence of s and find the part of s
+	// where the matches can be
+	first, i := -1, 0
+	for j := 0; j < n && i < m; j++ {
+		if eq(pattern[i], s[j]) {
+			if i == 0 {
+				first = j
+			}
+			i++
+		}
+	}
+	if i < m {
+		return 0, nil, false
+	}
+	last := n - 1
+	for !eq(pattern[m-1], s[last]) {
+		last--
+	}
+
+	// cur[j] is the best score of a match of pattern[:i+1] ending with
+	// pattern[i] matched with s[first+j]
+	w := last - first + 1
+	prev := make([]int, w)
+	cur := make([]int, w)
+	var back [][]int32
+	if positions {
+		back = make([][]int32, m)
+	}
+	valid := func(score int) bool {
+		return score > fuzzyNone/2
+	}
+
+	for i := 0; i < m; i++ {
+		if positions {
+			back[i] = make([]int32, w)
+		}
+		// best score of a match of pattern[:i] followed by a gap
+		gap, gapFrom := fuzzyNone, -1
+		for j := 0; j < w; j++ {
+			if i > 0 && j >= 2 {
+				gap -= fuzzyGapExtension
+				if c := prev[j-2] - fuzzyGapStart; c > gap {
+					gap, gapFrom = c, j-2
+				}
+			}
+
+			cur[j] = fuzzyNone
+			if !eq(pattern[i], s[first+j]) {
+				continue
+			}
+			bonus := fuzzyBonus(s, first+j)
+			if i == 0 {
+				cur[j] = fuzzyMatch + 2*bonus
+				continue
+			}
+
+			score, from := fuzzyNone, -1
+			if j >= 1 && valid(prev[j-1]) {
+				score, from = prev[j-1]+fuzzyMatch+bonus+fuzzyConsecutive, j-1
+			}
+			if valid(gap) && gap+fuzzyMatch+bonus > score {
+				score, from = gap+fuzzyMatch+bonus, gapFrom
+			}
+			cur[j] = score
+			if positions {
+				back[i][j] = int32(from)
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	best, end := fuzzyNone, -1
+	for j, score := range prev {
+		if score > best {
+			best, end = score, j
+		}
+	}
+	if !valid(best) {
+		return 0, nil, false
+	}
+	if !positions {
+		return best, nil, true
+	}
+
+	pos := make([]int, m)
+	for i := m - 1; i >= 0; i-- {
+		pos[i] = first + end
+		end = int(back[i][end])
+	}
+	return best, pos, true
+}
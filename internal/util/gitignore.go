@@ -0,0 +1,188 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// An ignoreRule is a single pattern of a .gitignore file
+type ignoreRule struct {
+	// directory containing the .gitignore file, relative to the root of
+	// the walk and using forward slashes ("" for the root itself)
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	// the pattern contains a slash, so it is matched against the whole
+	// path relative to base instead of the file name only
+	anchored bool
+}
+
+// A GitIgnore decides whether paths are ignored according to the
+// .gitignore files found while walking a directory tree
+type GitIgnore struct {
+	rules []ignoreRule
+}
+
+// ParseIgnoreRules adds the rules from the content of a .gitignore file
+// located in the directory base (relative to the root, with forward slashes)
+func (g *GitIgnore) ParseIgnoreRules(base string, data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		r.base = base
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.segments = strings.Split(line, "/")
+		g.rules = append(g.rules, r)
+	}
+}
+
+// AddIgnoreFile reads the .gitignore file in dir, if there is one. rel is
+// the path of dir relative to the root of the walk.
+func (g *GitIgnore) AddIgnoreFile(dir, rel string) {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	g.ParseIgnoreRules(rel, data)
+}
+
+// Ignored returns true if the given path (relative to the root of the walk)
+// is ignored. Since a file inside an ignored directory cannot be
+// re-included, the parent directories are expected to have been checked
+// already.
+func (g *GitIgnore) Ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		p := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			p = rel[len(r.base)+1:]
+		}
+		var match bool
+		if r.anchored {
+			match = matchSegments(r.segments, strings.Split(p, "/"))
+		} else {
+			match, _ = path.Match(r.segments[0], path.Base(p))
+		}
+		if match {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches a path split on slashes against a glob pattern
+// split on slashes, where a "**" segment matches any number of directories
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// WalkFiles calls fn for every regular file below root, skipping the .git
+// directory and the files ignored by .gitignore files. Hidden files and
+// directories are skipped unless hidden is true. The walk stops as soon
+// as fn returns false.
+func WalkFiles(root string, hidden bool, fn func(path string) bool) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		fn(root)
+		return nil
+	}
+
+	var ignore GitIgnore
+	var walk func(dir, rel string) bool
+	walk = func(dir, rel string) bool {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return true
+		}
+		ignore.AddIgnoreFile(dir, rel)
+		for _, e := range entries {
+			name := e.Name()
+			if name == ".git" || (!hidden && strings.HasPrefix(name, ".")) {
+				continue
+			}
+			erel := filepath.Join(rel, name)
+			if ignore.Ignored(erel, e.IsDir()) {
+				continue
+			}
+			p := filepath.Join(dir, name)
+			if e.IsDir() {
+				if !walk(p, erel) {
+					return false
+				}
+			} else if e.Type().IsRegular() {
+				if !fn(p) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	walk(root, ".")
+	return nil
+}
+
+// IsBinary returns true if the given data looks like the beginning of a
+// binary file, which is assumed when it contains a NUL byte, like git does
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
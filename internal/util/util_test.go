@@ -31,3 +31,51 @@ func TestSliceVisualEnd(t *testing.T) {
 	assert.Equal(t, []byte("ello"), slc)
 	assert.Equal(t, 0, n)
 }
+
+func TestGitIgnore(t *testing.T) {
+	var g GitIgnore
+	g.ParseIgnoreRules("", []byte("# comment\n*.o\n/build\nlogs/\n!keep.o\ndocs/**/*.tmp\n"))
+	g.ParseIgnoreRules("sub", []byte("local.txt\n"))
+
+	assert.True(t, g.Ignored("main.o", false))
+	assert.True(t, g.Ignored("a/b/main.o", false))
+	assert.False(t, g.Ignored("keep.o", false))
+	assert.True(t, g.Ignored("build", true))
+	assert.False(t, g.Ignored("a/build", true))
+	assert.True(t, g.Ignored("a/logs", true))
+	assert.False(t, g.Ignored("logs", false))
+	assert.True(t, g.Ignored("docs/x.tmp", false))
+	assert.True(t, g.Ignored("docs/a/b/x.tmp", false))
+	assert.False(t, g.Ignored("x.tmp", false))
+	assert.True(t, g.Ignored("sub/local.txt", false))
+	assert.False(t, g.Ignored("local.txt", false))
+}
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, IsBinary([]byte("hello\nworld\n")))
+	assert.True(t, IsBinary([]byte("ELF\x00\x01")))
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	_, ok := FuzzyScore("xyz", "internal/util/fuzzy.go")
+	assert.False(t, ok)
+
+	score, pos, ok := FuzzyMatch("fuz", "internal/util/fuzzy.go")
+	assert.True(t, ok)
+	assert.Equal(t, []int{14, 15, 16}, pos)
+	s, _ := FuzzyScore("fuz", "internal/util/fuzzy.go")
+	assert.Equal(t, score, s)
+
+	// matches at word boundaries are preferred
+	_, pos, _ = FuzzyMatch("bp", "internal/action/bufpane.go")
+	assert.Equal(t, []int{16, 19}, pos)
+	a, _ := FuzzyScore("bp", "internal/action/bufpane.go")
+	b, _ := FuzzyScore("bp", "cmd/micro/debug.go")
+	assert.Greater(t, a, b)
+
+	// smart case
+	_, ok = FuzzyScore("Fuz", "fuzzy.go")
+	assert.False(t, ok)
+	_, ok = FuzzyScore("fuz", "FUZZY.go")
+	assert.True(t, ok)
+}
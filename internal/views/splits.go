@@ -438,6 +438,41 @@ func (n *Node) VSplit(right bool) uint64 {
 	return n.hVSplit(0, right)
 }
 
+// DockLeft creates a split of the given width on the left of all the other
+// splits and returns its id. It must be called on the root of the tree.
+// The new split is not resized when other splits are added.
+func (n *Node) DockLeft(width int) uint64 {
+	if n.parent != nil || width <= 0 || width >= n.W {
+		return 0
+	}
+	if n.IsLeaf() || n.Kind != STHoriz {
+		// move the content of the root to a new node so that the root can
+		// lay out its children horizontally
+		id := n.id
+		if !n.IsLeaf() {
+			id = NewID()
+		}
+		c := NewNode(STVert, n.X, n.Y, n.W, n.H, n, id)
+		c.children = n.children
+		for _, child := range c.children {
+			child.parent = c
+		}
+		n.Kind = STHoriz
+		n.children = []*Node{c}
+	}
+
+	scale := float64(n.W-width) / float64(n.W)
+	for _, c := range n.children {
+		c.propW *= scale
+	}
+	newid := NewID()
+	dock := NewNode(STVert, n.X, n.Y, width, n.H, n, newid)
+	dock.canResize = false
+	n.children = append([]*Node{dock}, n.children...)
+	n.Resize(n.W, n.H)
+	return newid
+}
+
 // unsplits the child of a split
 func (n *Node) unsplit(i int) {
 	copy(n.children[i:], n.children[i+1:])
@@ -14,3 +14,32 @@ func TestHSplit(t *testing.T) {
 
 	fmt.Println(root.String())
 }
+
+func TestDockLeft(t *testing.T) {
+	root := NewRoot(0, 0, 80, 40)
+	id := root.id
+	root.HSplit(true)
+
+	dock := root.DockLeft(20)
+	if dock == 0 {
+		t.Fatal("DockLeft failed")
+	}
+	d := root.GetNode(dock)
+	if d.X != 0 || d.W != 20 || d.H != 40 {
+		t.Errorf("dock has view %v", d.View)
+	}
+	n := root.GetNode(id)
+	if n.X != 20 || n.W != 60 || n.H != 20 {
+		t.Errorf("split has view %v", n.View)
+	}
+
+	// the dock keeps its width when other splits are added
+	root.GetNode(id).VSplit(true)
+	if d.W != 20 {
+		t.Errorf("dock width changed to %d", d.W)
+	}
+
+	if root.GetNode(id).DockLeft(10) != 0 {
+		t.Error("DockLeft succeeded on a child")
+	}
+}
@@ -143,6 +143,7 @@ func (h *Highlighter) highlightRegion(highlights LineMatch, start int, canMatchE
 		}
 	}
 	if firstRegion != nil && firstLoc[0] != lineLen {
+		firstRegion = matchRegion(firstRegion, line, firstLoc[0])
 		if !statesOnly {
 			highlights[start+firstLoc[0]] = firstRegion.limitGroup
 		}
@@ -159,7 +160,7 @@ func (h *Highlighter) highlightRegion(highlights LineMatch, start int, canMatchE
 
 		if searchNesting {
 			for _, p := range curRegion.rules.patterns {
-				if curRegion.group == curRegion.limitGroup || p.group == curRegion.limitGroup {
+				if curRegion.group == curRegion.limitGroup || curRegion.language != "" || p.group == curRegion.limitGroup {
 					matches := findAllIndex(p.regex, line)
 					for _, m := range matches {
 						if (endLoc == nil) || (m[0] < endLoc[0]) {
@@ -225,6 +226,7 @@ func (h *Highlighter) highlightEmptyRegion(highlights LineMatch, start int, canM
 		}
 	}
 	if firstRegion != nil && firstLoc[0] != lineLen {
+		firstRegion = matchRegion(firstRegion, line, firstLoc[0])
 		if !statesOnly {
 			highlights[start+firstLoc[0]] = firstRegion.limitGroup
 		}
@@ -382,6 +384,67 @@ func (h *Highlighter) ReHighlightStates(input LineStates, startline int) int {
 	return input.LinesNum() - 1
 }
 
+// highlightLine highlights line lineN from the state at the end of the
+// previous line, and returns its matches (unless statesOnly is true) and
+// the state at its end
+func (h *Highlighter) highlightLine(input LineStates, lineN int, statesOnly bool) (LineMatch, State) {
+	line := input.LineBytes(lineN)
+
+	var highlights LineMatch
+	if !statesOnly {
+		highlights = make(LineMatch)
+	}
+	h.lastRegion = nil
+	if lineN > 0 {
+		h.lastRegion = input.State(lineN - 1)
+	}
+	if h.lastRegion == nil {
+		h.highlightEmptyRegion(highlights, 0, true, lineN, line, statesOnly)
+	} else {
+		h.highlightRegion(highlights, 0, true, lineN, line, h.lastRegion, statesOnly)
+	}
+	return highlights, h.lastRegion
+}
+
+// HighlightLineState returns the state at the end of line lineN, computed
+// from the state at the end of the previous line
+// It does not lock the input, which must be locked by the caller since the
+// state of the highlighter is modified
+func (h *Highlighter) HighlightLineState(input LineStates, lineN int) State {
+	_, state := h.highlightLine(input, lineN, true)
+	return state
+}
+
+// HighlightLineMatch returns the matches of line lineN, computed from the
+// state at the end of the previous line
+// It does not lock the input, which must be locked by the caller since the
+// state of the highlighter is modified
+func (h *Highlighter) HighlightLineMatch(input LineStates, lineN int) LineMatch {
+	match, _ := h.highlightLine(input, lineN, false)
+	return match
+}
+
+// StateAt returns the state at column x (in characters) of line lineN,
+// which is the region containing the text at this position
+// This assumes that the states of the previous lines are set correctly
+func (h *Highlighter) StateAt(input LineStates, lineN, x int) State {
+	input.Lock()
+	defer input.Unlock()
+
+	// use another highlighter to keep the last region of this one
+	hl := NewHighlighter(h.Def)
+	line := sliceEnd(input.LineBytes(lineN), x)
+	if lineN > 0 {
+		hl.lastRegion = input.State(lineN - 1)
+	}
+	if hl.lastRegion == nil {
+		hl.highlightEmptyRegion(nil, 0, true, lineN, line, true)
+	} else {
+		hl.highlightRegion(nil, 0, true, lineN, line, hl.lastRegion, true)
+	}
+	return hl.lastRegion
+}
+
 // ReHighlightLine will rehighlight the state and match for a single line
 func (h *Highlighter) ReHighlightLine(input LineStates, lineN int) {
 	input.Lock()
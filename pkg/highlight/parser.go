@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 )
@@ -27,6 +30,19 @@ func (g Group) String() string {
 	return ""
 }
 
+// GetGroup returns the group with the given name, which is added to Groups
+// if it does not exist yet, so that the panes which are not highlighted by a
+// syntax file (such as the file tree) can use the colors of the colorscheme
+func GetGroup(name string) Group {
+	g, ok := Groups[name]
+	if !ok {
+		numGroups++
+		g = numGroups
+		Groups[name] = g
+	}
+	return g
+}
+
 // A Def is a full syntax definition for a language
 // It has a filetype, information about how to detect the filetype based
 // on filename or header (the first line of the file)
@@ -34,6 +50,31 @@ func (g Group) String() string {
 type Def struct {
 	*Header
 	rules *rules
+
+	// Indent are the indentation rules of the language, or nil
+	Indent *IndentRules
+	// Comment are the comment strings of the language, or nil
+	Comment *Comment
+}
+
+// Comment holds the strings which start and end comments in a language
+type Comment struct {
+	// Line starts a comment which ends at the end of the line, such as //
+	Line string
+	// BlockStart and BlockEnd delimit a comment which may span several
+	// lines, such as /* and */
+	BlockStart string
+	BlockEnd   string
+}
+
+// IndentRules define how the lines of a file are indented
+type IndentRules struct {
+	// Increase matches the lines after which the indentation increases,
+	// such as a line ending with an opening brace
+	Increase *regexp.Regexp
+	// Decrease matches the lines which are indented one level less than
+	// the previous line, such as a line starting with a closing brace
+	Decrease *regexp.Regexp
 }
 
 type Header struct {
@@ -86,6 +127,87 @@ type region struct {
 	end        *regexp.Regexp
 	skip       *regexp.Regexp
 	rules      *rules
+	// comment are the comment strings inside the region, if they differ
+	// from the ones of its parent, such as in an included language
+	comment *Comment
+
+	// inject is the capture group of the start regex which gives the
+	// language included in the region, or 0. The regions including each
+	// language are created when they are first matched.
+	inject     int
+	injectLock sync.Mutex
+	injected   map[string]*region
+	// language is the language included in a region created for a capture
+	language string
+}
+
+// FindInjectedDef returns a new syntax definition for the language with the
+// given name, which is included in a region with `include: "$N"`, or nil if
+// the language is unknown. It is set by the user of the package.
+var FindInjectedDef func(name string) *Def
+
+// injectLanguage returns the region which highlights the given language
+// with the rules of this region, or this region if the language is unknown
+func (r *region) injectLanguage(name string) *region {
+	r.injectLock.Lock()
+	defer r.injectLock.Unlock()
+
+	if inj, ok := r.injected[name]; ok {
+		return inj
+	}
+
+	inj := r
+	var def *Def
+	if FindInjectedDef != nil && name != "" {
+		def = FindInjectedDef(name)
+	}
+	if def != nil {
+		inj = &region{
+			group:      r.group,
+			limitGroup: r.limitGroup,
+			parent:     r.parent,
+			start:      r.start,
+			end:        r.end,
+			skip:       r.skip,
+			comment:    r.comment,
+			language:   name,
+		}
+		if def.Comment != nil {
+			inj.comment = def.Comment
+		}
+		inj.rules = &rules{
+			patterns: append(append([]*pattern{}, r.rules.patterns...), def.rules.patterns...),
+			regions:  append(append([]*region{}, r.rules.regions...), def.rules.regions...),
+		}
+		for _, sub := range def.rules.regions {
+			sub.parent = inj
+		}
+	}
+
+	if r.injected == nil {
+		r.injected = make(map[string]*region)
+	}
+	r.injected[name] = inj
+	return inj
+}
+
+// matchRegion returns the region started by the match of the start regex of
+// r at the character loc of line, which is r itself unless it includes a
+// language given by a capture
+func matchRegion(r *region, line []byte, loc int) *region {
+	if r.inject == 0 {
+		return r
+	}
+	for _, m := range r.start.FindAllSubmatchIndex(line, -1) {
+		if runePos(m[0], line) < loc {
+			continue
+		}
+		if m[2*r.inject] < 0 {
+			break
+		}
+		return r.injectLanguage(string(line[m[2*r.inject]:m[2*r.inject+1]]))
+	}
+	return r
 }
 
 func init() {
@@ -253,6 +375,16 @@ func ParseDef(f *File, header *Header) (s *Def, err error) {
 			}
 
 			s.rules = rules
+		} else if k == "indent" {
+			s.Indent, err = parseIndentRules(v.(map[any]any))
+			if err != nil {
+				return nil, err
+			}
+		} else if k == "comment" {
+			s.Comment, err = parseComment(v.(map[any]any))
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -264,6 +396,73 @@ func ParseDef(f *File, header *Header) (s *Def, err error) {
 	return s, err
 }
 
+// parseIndentRules parses the increase and decrease regular expressions
+// of the indent section of a syntax file
+func parseIndentRules(input map[any]any) (*IndentRules, error) {
+	indent := new(IndentRules)
+	for k, v := range input {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("indent %v: expected a string", k)
+		}
+		r, err := regexp.Compile(str)
+		if err != nil {
+			return nil, err
+		}
+		switch k {
+		case "increase":
+			indent.Increase = r
+		case "decrease":
+			indent.Decrease = r
+		default:
+			return nil, fmt.Errorf("unknown indent rule %v", k)
+		}
+	}
+	return indent, nil
+}
+
+// parseComment parses the line and block comment strings of the comment
+// section of a syntax file
+func parseComment(input map[any]any) (*Comment, error) {
+	comment := new(Comment)
+	for k, v := range input {
+		switch k {
+		case "line":
+			str, ok := v.(string)
+			if !ok {
+				return nil, errors.New("comment line: expected a string")
+			}
+			comment.Line = str
+		case "block":
+			block, ok := v.([]any)
+			if !ok || len(block) != 2 {
+				return nil, errors.New("comment block: expected a start and an end string")
+			}
+			start, ok1 := block[0].(string)
+			end, ok2 := block[1].(string)
+			if !ok1 || !ok2 || start == "" || end == "" {
+				return nil, errors.New("comment block: expected a start and an end string")
+			}
+			comment.BlockStart, comment.BlockEnd = start, end
+		default:
+			return nil, fmt.Errorf("unknown comment string %v", k)
+		}
+	}
+	return comment, nil
+}
+
+// CommentIn returns the comment strings which apply in the given state: the
+// ones of the innermost region with comment strings, or the ones of the
+// language. It returns nil if there are none.
+func (d *Def) CommentIn(s State) *Comment {
+	for r := s; r != nil; r = r.parent {
+		if r.comment != nil {
+			return r.comment
+		}
+	}
+	return d.Comment
+}
+
 // HasIncludes returns whether this syntax def has any include statements
 func HasIncludes(d *Def) bool {
 	hasIncludes := len(d.rules.includes) > 0
@@ -327,6 +526,9 @@ func resolveIncludesInRegion(files []*File, region *region) {
 				searchDef, _ := ParseDef(searchFile, nil)
 				region.rules.patterns = append(region.rules.patterns, searchDef.rules.patterns...)
 				region.rules.regions = append(region.rules.regions, searchDef.rules.regions...)
+				if region.comment == nil {
+					region.comment = searchDef.Comment
+				}
 			}
 		}
 	}
@@ -359,7 +561,14 @@ func parseRules(input []any, curRegion *region) (ru *rules, err error) {
 					return nil, fmt.Errorf("Empty rule %s", k)
 				}
 
-				if k == "include" {
+				if k == "include" && strings.HasPrefix(object, "$") {
+					// the language is given by a capture of the start regex
+					n, err := strconv.Atoi(object[1:])
+					if err != nil || curRegion == nil || n < 1 || n > curRegion.start.NumSubexp() {
+						return nil, fmt.Errorf("Invalid include %s: expected a capture group of the start of the region", object)
+					}
+					curRegion.inject = n
+				} else if k == "include" {
 					ru.includes = append(ru.includes, object)
 				} else {
 					// Pattern
@@ -476,6 +685,14 @@ func parseRegion(group string, regionInfo map[any]any, prevRegion *region) (r *r
 		r.limitGroup = r.group
 	}
 
+	// comment is optional
+	if comment, ok := regionInfo["comment"]; ok {
+		r.comment, err = parseComment(comment.(map[any]any))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// rules are optional
 	if rules, ok := regionInfo["rules"]; ok {
 		r.rules, err = parseRules(rules.([]any), r)
@@ -54,7 +54,11 @@ function preInsertNewline(bp)
                 bp:StartOfLine()
                 bp:CursorLeft()
                 bp:InsertNewline()
-                bp:InsertTab()
+                -- the indentation rules of the filetype may already have
+                -- indented the new line
+                if #uutil.GetLeadingWhitespace(bp.Buf:Line(bp.Cursor.Y)) <= #ws then
+                    bp:InsertTab()
+                end
                 return false
             end
         end
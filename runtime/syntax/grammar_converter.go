@@ -0,0 +1,978 @@
+//go:build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maxNesting is the maximum number of nested regions in the converted
+// syntax. TextMate grammars often include themselves inside their regions
+// (for example in string interpolations), which micro cannot express, so
+// the regions are copied up to this depth.
+const maxNesting = 3
+
+// scopeGroups maps TextMate scopes to micro highlight groups. A scope uses
+// the group of its longest prefix in this table.
+var scopeGroups = map[string]string{
+	"comment":                          "comment",
+	"constant":                         "constant",
+	"constant.character.escape":        "constant.specialChar",
+	"constant.language":                "constant.bool",
+	"constant.numeric":                 "constant.number",
+	"entity.name":                      "identifier",
+	"entity.name.class":                "identifier.class",
+	"entity.name.function":             "identifier.class",
+	"entity.name.section":              "special",
+	"entity.name.tag":                  "symbol.tag",
+	"entity.name.type":                 "type",
+	"entity.other.attribute-name":      "identifier",
+	"entity.other.inherited-class":     "type",
+	"invalid":                          "error",
+	"keyword":                          "statement",
+	"keyword.control.directive":        "preproc",
+	"keyword.operator":                 "symbol.operator",
+	"markup.bold":                      "type",
+	"markup.heading":                   "special",
+	"markup.italic":                    "type",
+	"markup.list":                      "identifier",
+	"markup.quote":                     "statement",
+	"markup.raw":                       "special",
+	"markup.underline":                 "underlined",
+	"meta.preprocessor":                "preproc",
+	"punctuation.definition.bold":      "type",
+	"punctuation.definition.comment":   "comment",
+	"punctuation.definition.directive": "preproc",
+	"punctuation.definition.heading":   "special",
+	"punctuation.definition.italic":    "type",
+	"punctuation.definition.list":      "identifier",
+	"punctuation.definition.quote":     "statement",
+	"punctuation.definition.raw":       "special",
+	"punctuation.definition.string":    "constant.string",
+	"punctuation.definition.tag":       "symbol.tag",
+	"punctuation.definition.variable":  "identifier.var",
+	"punctuation.section":              "symbol.brackets",
+	"punctuation.section.embedded":     "special",
+	"storage":                          "type",
+	"storage.modifier":                 "type.keyword",
+	"string":                           "constant.string",
+	"string.regexp":                    "constant.string",
+	"support.class":                    "type",
+	"support.constant":                 "constant",
+	"support.function":                 "identifier",
+	"support.type":                     "type",
+	"variable":                         "identifier.var",
+	"variable.language":                "constant.bool",
+}
+
+// filetypes maps the names used in the scopes of grammars to the filetypes
+// of micro when they differ
+var filetypes = map[string]string{
+	"cpp":   "c++",
+	"cs":    "csharp",
+	"js":    "javascript",
+	"jsx":   "javascript",
+	"md":    "markdown",
+	"objc":  "objective-c",
+	"py":    "python",
+	"rb":    "ruby",
+	"rs":    "rust",
+	"sh":    "shell",
+	"shell": "shell",
+	"ts":    "typescript",
+	"tsx":   "typescript",
+	"yml":   "yaml",
+}
+
+// scopeGroup returns the micro group of a scope attribute, which may
+// contain several scopes separated by spaces, or "" if none is known
+func scopeGroup(scope string) string {
+	for _, s := range strings.Fields(scope) {
+		best := ""
+		for prefix := range scopeGroups {
+			if (s == prefix || strings.HasPrefix(s, prefix+".")) && len(prefix) > len(best) {
+				best = prefix
+			}
+		}
+		if best != "" {
+			return scopeGroups[best]
+		}
+	}
+	return ""
+}
+
+// scopeFiletype returns the micro filetype of a grammar scope such as
+// "source.js" or "text.html.basic"
+func scopeFiletype(scope string) string {
+	scope, _, _ = strings.Cut(scope, "#")
+	parts := strings.Split(scope, ".")
+	name := parts[0]
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if ft, ok := filetypes[name]; ok {
+		return ft
+	}
+	return name
+}
+
+type tmCapture struct {
+	Name string `json:"name"`
+}
+
+// tmRule is a rule of a TextMate grammar. The rules of Sublime Text syntaxes
+// are converted to this format too.
+type tmRule struct {
+	Name          string               `json:"name"`
+	ContentName   string               `json:"contentName"`
+	Match         string               `json:"match"`
+	Begin         string               `json:"begin"`
+	End           string               `json:"end"`
+	While         string               `json:"while"`
+	Include       string               `json:"include"`
+	Captures      map[string]tmCapture `json:"captures"`
+	BeginCaptures map[string]tmCapture `json:"beginCaptures"`
+	EndCaptures   map[string]tmCapture `json:"endCaptures"`
+	Patterns      []*tmRule            `json:"patterns"`
+	Repository    map[string]*tmRule   `json:"repository"`
+}
+
+type tmGrammar struct {
+	ScopeName      string             `json:"scopeName"`
+	FileTypes      []string           `json:"fileTypes"`
+	FirstLineMatch string             `json:"firstLineMatch"`
+	Patterns       []*tmRule          `json:"patterns"`
+	Repository     map[string]*tmRule `json:"repository"`
+}
+
+// microRule is a pattern, region or include of a micro syntax file
+type microRule struct {
+	group string
+	regex string
+
+	region     bool
+	start      string
+	end        string
+	skip       string
+	limitGroup string
+	rules      []*microRule
+
+	include string
+}
+
+// converter converts the rules of a TextMate grammar to micro rules and
+// records the constructs which cannot be expressed in micro
+type converter struct {
+	grammar *tmGrammar
+	self    *tmRule
+	repos   []map[string]*tmRule
+	// regions are the regions being converted, and includes are the lists
+	// of rules being included in the innermost one
+	regions  []*tmRule
+	includes []*tmRule
+	notes    []string
+	noted    map[string]bool
+}
+
+func newConverter(g *tmGrammar) *converter {
+	return &converter{
+		grammar: g,
+		self:    &tmRule{Patterns: g.Patterns},
+		repos:   []map[string]*tmRule{g.Repository},
+		noted:   make(map[string]bool),
+	}
+}
+
+// note records a construct which is dropped or approximated
+func (c *converter) note(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if !c.noted[msg] {
+		c.noted[msg] = true
+		c.notes = append(c.notes, msg)
+	}
+}
+
+// describe returns a short description of a rule for the notes
+func describe(r *tmRule) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	re := r.Match + r.Begin
+	if len(re) > 40 {
+		re = re[:40] + "..."
+	}
+	return strconv.Quote(re)
+}
+
+func contains(rules []*tmRule, r *tmRule) bool {
+	for _, s := range rules {
+		if s == r {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the rule of the repository with the given name, searching
+// the repositories of the enclosing rules first
+func (c *converter) lookup(name string) *tmRule {
+	for i := len(c.repos) - 1; i >= 0; i-- {
+		if r, ok := c.repos[i][name]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// convertRules converts a list of rules. The patterns are reversed because
+// the first TextMate pattern matching at a position wins, while the last
+// micro pattern does.
+func (c *converter) convertRules(rules []*tmRule) []*microRule {
+	var includes, regions, patterns []*microRule
+	seen := make(map[[3]string]bool)
+	for _, r := range c.collect(rules) {
+		if !r.region {
+			// the same rules are often included several times
+			key := [3]string{r.group, r.regex, r.include}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		switch {
+		case r.include != "":
+			includes = append(includes, r)
+		case r.region:
+			regions = append(regions, r)
+		default:
+			patterns = append([]*microRule{r}, patterns...)
+		}
+	}
+	return append(append(includes, regions...), patterns...)
+}
+
+// collect converts the rules in order, expanding the includes of the
+// repository and the rules which only group other rules
+func (c *converter) collect(rules []*tmRule) []*microRule {
+	var res []*microRule
+	for _, r := range rules {
+		if r.Repository != nil {
+			c.repos = append(c.repos, r.Repository)
+		}
+		switch {
+		case r.Include != "":
+			res = append(res, c.convertInclude(r.Include)...)
+		case r.Match != "":
+			if p := c.convertPattern(r); p != nil {
+				res = append(res, p)
+			}
+		case r.While != "":
+			c.note("%s: begin/while rules are not supported", describe(r))
+		case r.Begin != "":
+			if reg := c.convertRegion(r); reg != nil {
+				res = append(res, reg)
+			}
+		default:
+			res = append(res, c.collect(r.Patterns)...)
+		}
+		if r.Repository != nil {
+			c.repos = c.repos[:len(c.repos)-1]
+		}
+	}
+	return res
+}
+
+func (c *converter) convertInclude(include string) []*microRule {
+	var target *tmRule
+	switch {
+	case include == "$self" || include == "$base":
+		target = c.self
+	case strings.HasPrefix(include, "#"):
+		target = c.lookup(include[1:])
+		if target == nil {
+			c.note("include %s: no such rule in the repository", include)
+			return nil
+		}
+	default:
+		if strings.Contains(include, "#") {
+			c.note("include %s: only whole grammars can be included", include)
+		}
+		ft := scopeFiletype(include)
+		if ft == scopeFiletype(c.grammar.ScopeName) {
+			return c.convertInclude("$self")
+		}
+		return []*microRule{{include: ft}}
+	}
+
+	if target.Match != "" || target.Begin != "" {
+		return c.collect([]*tmRule{target})
+	}
+	if contains(c.includes, target) {
+		// including a list of rules inside itself has no effect
+		return nil
+	}
+	c.includes = append(c.includes, target)
+	defer func() { c.includes = c.includes[:len(c.includes)-1] }()
+	return c.collect([]*tmRule{target})
+}
+
+// captureGroup returns the group of the first capture with a known group
+func (c *converter) captureGroup(r *tmRule, captures map[string]tmCapture) string {
+	if g := scopeGroup(captures["0"].Name); g != "" {
+		return g
+	}
+	var keys []int
+	for k := range captures {
+		if n, err := strconv.Atoi(k); err == nil && n > 0 {
+			keys = append(keys, n)
+		}
+	}
+	sort.Ints(keys)
+	group := ""
+	for _, k := range keys {
+		g := scopeGroup(captures[strconv.Itoa(k)].Name)
+		if g == "" {
+			continue
+		}
+		if group == "" {
+			group = g
+		} else if g != group {
+			c.note("%s: captures are highlighted with the group of the first one", describe(r))
+		}
+	}
+	return group
+}
+
+func (c *converter) convertPattern(r *tmRule) *microRule {
+	group := scopeGroup(r.Name)
+	if group == "" {
+		group = c.captureGroup(r, r.Captures)
+	}
+	if group == "" {
+		return nil
+	}
+	re, err := c.translateRegex(r.Match, describe(r))
+	if err != nil {
+		c.note("%s: dropped: %v", describe(r), err)
+		return nil
+	}
+	if re == "" {
+		c.note("%s: dropped: the regex only has assertions", describe(r))
+		return nil
+	}
+	return &microRule{group: group, regex: re}
+}
+
+func (c *converter) convertRegion(r *tmRule) *microRule {
+	if contains(c.regions, r) || len(c.regions) >= maxNesting {
+		c.note("%s: regions nested in themselves or more than %d levels deep are dropped", describe(r), maxNesting)
+		return nil
+	}
+
+	start, err := c.translateRegex(r.Begin, describe(r))
+	if err == nil && canMatchEmpty(start) {
+		err = fmt.Errorf("the begin regex can match an empty string")
+	}
+	if err != nil {
+		c.note("%s: dropped: %v", describe(r), err)
+		return nil
+	}
+	end, err := c.translateRegex(r.End, describe(r))
+	if err != nil {
+		c.note("%s: dropped: end: %v", describe(r), err)
+		return nil
+	}
+	if end == "" {
+		c.note("%s: the region ends at the end of the line", describe(r))
+		end = "$"
+	}
+
+	reg := &microRule{region: true, start: start, end: end}
+	reg.group = scopeGroup(r.ContentName)
+	if reg.group == "" {
+		reg.group = scopeGroup(r.Name)
+	}
+	if reg.group == "" {
+		reg.group = "default"
+	}
+
+	includes := c.includes
+	c.regions, c.includes = append(c.regions, r), nil
+	reg.rules = c.convertRules(r.Patterns)
+	c.regions, c.includes = c.regions[:len(c.regions)-1], includes
+
+	hasPatterns := false
+	for _, sub := range reg.rules {
+		if !sub.region && sub.include == "" {
+			hasPatterns = true
+			if sub.group == "constant.specialChar" && reg.skip == "" {
+				reg.skip = sub.regex
+			}
+		}
+	}
+
+	limit := c.captureGroup(r, r.BeginCaptures)
+	if limit == "" {
+		limit = scopeGroup(r.Name)
+	}
+	if limit != "" && limit != reg.group {
+		// micro only highlights the patterns of the limit group inside a
+		// region with a different limit group
+		if hasPatterns {
+			c.note("%s: the delimiters are highlighted like the content", describe(r))
+		} else {
+			reg.limitGroup = limit
+		}
+	}
+	return reg
+}
+
+// canMatchEmpty returns whether a valid regex can match an empty string,
+// which would start a region endlessly
+func canMatchEmpty(re string) bool {
+	parsed, err := syntax.Parse(re, syntax.Perl)
+	return err == nil && minLength(parsed) == 0
+}
+
+func minLength(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1
+	case syntax.OpCapture, syntax.OpPlus:
+		return minLength(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min * minLength(re.Sub[0])
+	case syntax.OpConcat:
+		n := 0
+		for _, sub := range re.Sub {
+			n += minLength(sub)
+		}
+		return n
+	case syntax.OpAlternate:
+		n := -1
+		for _, sub := range re.Sub {
+			if m := minLength(sub); n < 0 || m < n {
+				n = m
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+// translateRegex translates an Oniguruma regex, used by TextMate and
+// Sublime Text, to a Go regex. Lookaround assertions, atomic groups and
+// possessive quantifiers are approximated: the text of positive assertions
+// is matched and negative ones are removed. Backreferences are errors.
+// desc describes the rule of the regex in the notes.
+func (c *converter) translateRegex(re, desc string) (string, error) {
+	extended := false
+	if m := regexp.MustCompile(`^\(\?([a-z]*)x([a-z]*)\)`).FindStringSubmatch(re); m != nil {
+		extended = true
+		re = re[len(m[0]):]
+		if m[1]+m[2] != "" {
+			re = "(?" + m[1] + m[2] + ")" + re
+		}
+	}
+
+	var b strings.Builder
+	inClass := false
+	quantified := false
+	for i := 0; i < len(re); i++ {
+		ch := re[i]
+		wasQuantified := quantified
+		quantified = false
+
+		switch {
+		case ch == '\\' && i+1 < len(re):
+			i++
+			switch e := re[i]; {
+			case e >= '1' && e <= '9' && !inClass, e == 'k':
+				return "", fmt.Errorf("backreferences are not supported")
+			case e == 'h' || e == 'H':
+				class := "0-9a-fA-F"
+				if !inClass {
+					class = "[" + class + "]"
+					if e == 'H' {
+						class = "[^0-9a-fA-F]"
+					}
+				} else if e == 'H' {
+					return "", fmt.Errorf(`\H is not supported in a character class`)
+				}
+				b.WriteString(class)
+			case e == 'G':
+				c.note(`%s: \G is ignored`, desc)
+			case e == 'Z':
+				b.WriteString(`\z`)
+			case e == 'e':
+				b.WriteString(`\x1B`)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		case inClass:
+			if ch == '[' && strings.HasPrefix(re[i:], "[:") {
+				end := strings.Index(re[i:], ":]")
+				if end < 0 {
+					return "", fmt.Errorf("unterminated character class")
+				}
+				b.WriteString(re[i : i+end+2])
+				i += end + 1
+				continue
+			}
+			if ch == '[' || strings.HasPrefix(re[i:], "&&") {
+				return "", fmt.Errorf("nested character classes are not supported")
+			}
+			if ch == ']' {
+				inClass = false
+			}
+			b.WriteByte(ch)
+		case ch == '[':
+			inClass = true
+			b.WriteByte(ch)
+			// a ']' at the start of the class is literal
+			if strings.HasPrefix(re[i+1:], "^") {
+				b.WriteByte('^')
+				i++
+			}
+			if strings.HasPrefix(re[i+1:], "]") {
+				b.WriteString(`\]`)
+				i++
+			}
+		case extended && (ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'):
+		case extended && ch == '#':
+			for i+1 < len(re) && re[i+1] != '\n' {
+				i++
+			}
+		case ch == '(' && strings.HasPrefix(re[i:], "(?#"):
+			i = skipGroup(re, i)
+		case ch == '(' && (strings.HasPrefix(re[i:], "(?=") || strings.HasPrefix(re[i:], "(?<=")):
+			// the text of the assertion is matched instead
+			c.note("%s: lookaround assertions are converted to groups", desc)
+			b.WriteString("(?:")
+			i = strings.IndexByte(re[i:], '=') + i
+		case ch == '(' && (strings.HasPrefix(re[i:], "(?!") || strings.HasPrefix(re[i:], "(?<!")):
+			c.note("%s: negative lookaround assertions are removed", desc)
+			i = skipGroup(re, i)
+			// a quantifier of the assertion goes with it
+			if i+1 < len(re) && strings.IndexByte("*+?", re[i+1]) >= 0 {
+				i++
+			}
+		case ch == '(' && strings.HasPrefix(re[i:], "(?>"):
+			c.note("%s: atomic groups are converted to normal groups", desc)
+			b.WriteString("(?:")
+			i += 2
+		case ch == '(' && strings.HasPrefix(re[i:], "(?<"):
+			b.WriteString("(?P<")
+			i += 2
+		case ch == '+' && wasQuantified:
+			c.note("%s: possessive quantifiers are converted to greedy ones", desc)
+		case ch == '*' || ch == '+' || ch == '?':
+			quantified = true
+			b.WriteByte(ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	res := b.String()
+	if _, err := regexp.Compile(res); err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+// skipGroup returns the index of the parenthesis closing the group opened
+// at i
+func skipGroup(re string, i int) int {
+	depth := 0
+	inClass := false
+	for ; i < len(re); i++ {
+		switch ch := re[i]; {
+		case ch == '\\':
+			i++
+		case inClass:
+			inClass = ch != ']'
+		case ch == '[':
+			inClass = true
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(re) - 1
+}
+
+// sublimeSyntax is a Sublime Text syntax definition
+type sublimeSyntax struct {
+	FileExtensions []string          `yaml:"file_extensions"`
+	FirstLineMatch string            `yaml:"first_line_match"`
+	Scope          string            `yaml:"scope"`
+	Variables      map[string]string `yaml:"variables"`
+	Contexts       map[string][]any  `yaml:"contexts"`
+}
+
+// sublimeContext is a context of a Sublime Text syntax. The rules of a
+// context which pop it are the end of the region of the context.
+type sublimeContext struct {
+	metaScope        string
+	metaContentScope string
+	rules            []*tmRule
+	pops             []*tmRule
+}
+
+// sublimeConverter converts a Sublime Text syntax to a TextMate grammar.
+// The contexts pushed by a rule become regions ending with the rules
+// which pop them.
+type sublimeConverter struct {
+	*converter
+	syntax   *sublimeSyntax
+	contexts map[string]*sublimeContext
+	// pushed are the regions of named contexts, which are completed when
+	// all contexts are parsed
+	pushed []pushedContext
+}
+
+type pushedContext struct {
+	region *tmRule
+	name   string
+}
+
+var sublimeVariable = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// expand replaces the variables in a regex
+func (s *sublimeConverter) expand(re string) string {
+	for i := 0; i < 10 && sublimeVariable.MatchString(re); i++ {
+		re = sublimeVariable.ReplaceAllStringFunc(re, func(v string) string {
+			return s.syntax.Variables[v[2:len(v)-2]]
+		})
+	}
+	return re
+}
+
+func sublimeCaptures(v any) map[string]tmCapture {
+	captures := make(map[string]tmCapture)
+	m, _ := v.(map[any]any)
+	for k, scope := range m {
+		captures[fmt.Sprint(k)] = tmCapture{fmt.Sprint(scope)}
+	}
+	return captures
+}
+
+// sublimeInclude returns the TextMate include of a context reference
+func (s *sublimeConverter) sublimeInclude(ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "scope:"):
+		return ref[len("scope:"):]
+	case strings.HasSuffix(ref, ".sublime-syntax"):
+		return "source." + strings.ToLower(strings.TrimSuffix(filepath.Base(ref), ".sublime-syntax"))
+	}
+	return "#" + ref
+}
+
+func (s *sublimeConverter) parseContext(name string, entries []any) *sublimeContext {
+	ctx := new(sublimeContext)
+	includePrototype := name != "prototype"
+	for _, e := range entries {
+		entry, ok := e.(map[any]any)
+		if !ok {
+			continue
+		}
+		str := func(key string) string {
+			v, _ := entry[key].(string)
+			return v
+		}
+
+		if v, ok := entry["meta_include_prototype"].(bool); ok {
+			includePrototype = v
+		}
+		if v := str("meta_scope"); v != "" {
+			ctx.metaScope = v
+		}
+		if v := str("meta_content_scope"); v != "" {
+			ctx.metaContentScope = v
+		}
+		if _, ok := entry["clear_scopes"]; ok {
+			s.note("context %s: clear_scopes is ignored", name)
+		}
+		if v := str("include"); v != "" {
+			ctx.rules = append(ctx.rules, &tmRule{Include: s.sublimeInclude(v)})
+		}
+		if _, ok := entry["match"]; !ok {
+			continue
+		}
+
+		captures := sublimeCaptures(entry["captures"])
+		if scope := str("scope"); scope != "" {
+			captures["0"] = tmCapture{scope}
+		}
+		rule := &tmRule{Match: s.expand(str("match")), Captures: captures}
+		_, push := entry["push"]
+		_, set := entry["set"]
+		switch {
+		case entry["pop"] != nil && entry["pop"] != false:
+			ctx.pops = append(ctx.pops, rule)
+		case str("embed") != "":
+			ctx.rules = append(ctx.rules, &tmRule{
+				Begin:         rule.Match,
+				BeginCaptures: captures,
+				End:           s.expand(str("escape")),
+				EndCaptures:   sublimeCaptures(entry["escape_captures"]),
+				ContentName:   str("embed_scope"),
+				Patterns:      []*tmRule{{Include: s.sublimeInclude(str("embed"))}},
+			})
+		case push || set:
+			target := entry["push"]
+			if set {
+				target = entry["set"]
+				s.note("set is converted to push")
+			}
+			if region := s.pushRegion(name, rule, target); region != nil {
+				ctx.rules = append(ctx.rules, region)
+			}
+		default:
+			ctx.rules = append(ctx.rules, rule)
+		}
+	}
+
+	if includePrototype && s.syntax.Contexts["prototype"] != nil {
+		ctx.rules = append([]*tmRule{{Include: "#prototype"}}, ctx.rules...)
+	}
+	return ctx
+}
+
+// pushRegion returns the region of the context pushed by rule, which is
+// either the name of a context, a list of names or an anonymous context
+func (s *sublimeConverter) pushRegion(name string, rule *tmRule, target any) *tmRule {
+	region := &tmRule{Begin: rule.Match, BeginCaptures: rule.Captures}
+	switch t := target.(type) {
+	case string:
+		s.pushed = append(s.pushed, pushedContext{region, t})
+		region.Patterns = []*tmRule{{Include: s.sublimeInclude(t)}}
+	case []any:
+		if len(t) == 0 {
+			return nil
+		}
+		if last, ok := t[len(t)-1].(string); ok {
+			if len(t) > 1 {
+				s.note("context %s: only the last of several pushed contexts is used", name)
+			}
+			s.pushed = append(s.pushed, pushedContext{region, last})
+			region.Patterns = []*tmRule{{Include: s.sublimeInclude(last)}}
+		} else {
+			s.completeRegion(name, region, s.parseContext(name, t))
+		}
+	default:
+		return nil
+	}
+	return region
+}
+
+// completeRegion sets the scopes, end and rules of the region of ctx
+func (s *sublimeConverter) completeRegion(name string, region *tmRule, ctx *sublimeContext) {
+	region.Name = ctx.metaScope
+	region.ContentName = ctx.metaContentScope
+	if region.Patterns == nil {
+		region.Patterns = ctx.rules
+	}
+	var ends []string
+	for _, pop := range ctx.pops {
+		ends = append(ends, "(?:"+pop.Match+")")
+	}
+	switch len(ends) {
+	case 0:
+		s.note("context %s: the context never pops, so its region never ends", name)
+		region.End = `\b\B`
+	case 1:
+		region.End = ctx.pops[0].Match
+		region.EndCaptures = ctx.pops[0].Captures
+	default:
+		region.End = strings.Join(ends, "|")
+	}
+}
+
+func convertSublime(data []byte) (*tmGrammar, *converter, error) {
+	// yaml.v2 only supports YAML 1.1 directives
+	data = regexp.MustCompile(`^%YAML.*\n`).ReplaceAll(data, nil)
+	var syn sublimeSyntax
+	if err := yaml.Unmarshal(data, &syn); err != nil {
+		return nil, nil, err
+	}
+
+	g := &tmGrammar{
+		ScopeName:  syn.Scope,
+		FileTypes:  syn.FileExtensions,
+		Patterns:   []*tmRule{{Include: "#main"}},
+		Repository: make(map[string]*tmRule),
+	}
+	s := &sublimeConverter{
+		converter: newConverter(g),
+		syntax:    &syn,
+		contexts:  make(map[string]*sublimeContext),
+	}
+	g.FirstLineMatch = s.expand(syn.FirstLineMatch)
+
+	var names []string
+	for name := range syn.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ctx := s.parseContext(name, syn.Contexts[name])
+		s.contexts[name] = ctx
+		g.Repository[name] = &tmRule{Patterns: ctx.rules}
+	}
+	for _, p := range s.pushed {
+		if ctx, ok := s.contexts[p.name]; ok {
+			s.completeRegion(p.name, p.region, ctx)
+		} else {
+			s.note("context %s: the context is not defined in this file", p.name)
+			p.region.End = "$"
+		}
+	}
+	return g, s.converter, nil
+}
+
+// quote returns s as a double-quoted YAML string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func writeRules(b *strings.Builder, rules []*microRule, indent string) {
+	for _, r := range rules {
+		switch {
+		case r.include != "":
+			fmt.Fprintf(b, "%s- include: %s\n", indent, quote(r.include))
+		case r.region:
+			fmt.Fprintf(b, "%s- %s:\n", indent, r.group)
+			fmt.Fprintf(b, "%s    start: %s\n", indent, quote(r.start))
+			fmt.Fprintf(b, "%s    end: %s\n", indent, quote(r.end))
+			if r.skip != "" {
+				fmt.Fprintf(b, "%s    skip: %s\n", indent, quote(r.skip))
+			}
+			if r.limitGroup != "" {
+				fmt.Fprintf(b, "%s    limit-group: %s\n", indent, r.limitGroup)
+			}
+			if len(r.rules) == 0 {
+				fmt.Fprintf(b, "%s    rules: []\n", indent)
+			} else {
+				fmt.Fprintf(b, "%s    rules:\n", indent)
+				writeRules(b, r.rules, indent+"        ")
+			}
+		default:
+			fmt.Fprintf(b, "%s- %s: %s\n", indent, r.group, quote(r.regex))
+		}
+	}
+}
+
+// generateFile returns the micro syntax file of a grammar
+func generateFile(filetype string, c *converter) string {
+	var b strings.Builder
+	g := c.grammar
+
+	fmt.Fprintf(&b, "filetype: %s\n\ndetect:\n", filetype)
+	var exts, names []string
+	for _, ft := range g.FileTypes {
+		if ft == "" {
+			continue
+		}
+		// file types starting with an uppercase letter or containing a dot
+		// are file names, such as "Makefile" or "CMakeLists.txt"
+		if strings.Contains(ft, ".") || strings.ToLower(ft[:1]) != ft[:1] {
+			names = append(names, regexp.QuoteMeta(ft))
+		} else {
+			exts = append(exts, regexp.QuoteMeta(ft))
+		}
+	}
+	var filename []string
+	if len(exts) > 0 {
+		filename = append(filename, `\.(`+strings.Join(exts, "|")+`)$`)
+	}
+	if len(names) > 0 {
+		filename = append(filename, `(^|/)(`+strings.Join(names, "|")+`)$`)
+	}
+	if len(filename) > 0 {
+		fmt.Fprintf(&b, "    filename: %s\n", quote(strings.Join(filename, "|")))
+	}
+	if g.FirstLineMatch != "" {
+		if header, err := c.translateRegex(g.FirstLineMatch, "firstLineMatch"); err == nil {
+			fmt.Fprintf(&b, "    header: %s\n", quote(header))
+		} else {
+			c.note("firstLineMatch: dropped: %v", err)
+		}
+	}
+
+	// the grammar is already expanded where it includes itself at the top
+	c.includes = append(c.includes, c.self)
+	rules := c.convertRules(g.Patterns)
+	c.includes = c.includes[:len(c.includes)-1]
+	if len(rules) == 0 {
+		b.WriteString("\nrules: []\n")
+	} else {
+		b.WriteString("\nrules:\n")
+		writeRules(&b, rules, "    ")
+	}
+	return b.String()
+}
+
+func main() {
+	filetype := flag.String("filetype", "", "the filetype of the syntax file (by default, it is given by the scope of the grammar)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run grammar_converter.go [-filetype name] grammar.tmLanguage.json|grammar.sublime-syntax")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var c *converter
+	switch {
+	case strings.HasSuffix(path, ".sublime-syntax"):
+		_, c, err = convertSublime(data)
+	case strings.HasSuffix(path, ".json"):
+		var g tmGrammar
+		if err = json.Unmarshal(data, &g); err == nil {
+			c = newConverter(&g)
+		}
+	default:
+		err = fmt.Errorf("%s: expected a .tmLanguage.json or .sublime-syntax file", path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *filetype == "" {
+		*filetype = scopeFiletype(c.grammar.ScopeName)
+	}
+	fmt.Print(generateFile(*filetype, c))
+	for _, n := range c.notes {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, n)
+	}
+}
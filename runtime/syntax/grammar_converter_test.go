@@ -0,0 +1,341 @@
+//go:build ignore
+
+// The tests of the converter are run with:
+//
+//	go test grammar_converter.go grammar_converter_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeGroup(t *testing.T) {
+	tests := []struct {
+		scope string
+		group string
+	}{
+		{"comment.line.double-slash.go", "comment"},
+		{"keyword.control.directive.c", "preproc"},
+		{"keyword.controlled", "statement"},
+		{"entity.name.function.go", "identifier.class"},
+		{"meta.block.go", ""},
+		{"meta.block.go string.quoted.double.go", "constant.string"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.group, scopeGroup(test.scope), test.scope)
+	}
+}
+
+func TestScopeFiletype(t *testing.T) {
+	assert.Equal(t, "toy", scopeFiletype("source.toy"))
+	assert.Equal(t, "javascript", scopeFiletype("source.js"))
+	assert.Equal(t, "html", scopeFiletype("text.html.basic"))
+	assert.Equal(t, "c", scopeFiletype("source.c#preprocessor"))
+}
+
+func TestTranslateRegex(t *testing.T) {
+	tests := []struct {
+		re   string
+		want string
+		// the error or note which is expected, if any
+		note string
+	}{
+		{re: `\b(if|else)\b`, want: `\b(if|else)\b`},
+		{re: `\h+\H`, want: `[0-9a-fA-F]+[^0-9a-fA-F]`},
+		{re: `[\h_]`, want: `[0-9a-fA-F_]`},
+		{re: `[]a]`, want: `[\]a]`},
+		{re: `[[:alpha:]]+`, want: `[[:alpha:]]+`},
+		{re: `(?<name>\w+)`, want: `(?P<name>\w+)`},
+		{re: `a\Z\e`, want: `a\z\x1B`},
+		{re: "(?x) a b # comment\n c", want: `abc`},
+		{re: `a(?# comment)b`, want: `ab`},
+		{re: `(?<=\.)\w+`, want: `(?:\.)\w+`, note: "lookaround assertions are converted to groups"},
+		{re: `\w+(?!\()`, want: `\w+`, note: "negative lookaround assertions are removed"},
+		{re: `(?>a|b)`, want: `(?:a|b)`, note: "atomic groups are converted to normal groups"},
+		{re: `a++`, want: `a+`, note: "possessive quantifiers are converted to greedy ones"},
+		{re: `\Ga`, want: `a`, note: `\G is ignored`},
+		{re: `(a)\1`, note: "backreferences are not supported"},
+		{re: `\k<a>`, note: "backreferences are not supported"},
+		{re: `[a[b]]`, note: "nested character classes are not supported"},
+		{re: `[\H]`, note: `\H is not supported in a character class`},
+		{re: `(a`, note: "missing closing )"},
+	}
+	for _, test := range tests {
+		c := newConverter(&tmGrammar{})
+		got, err := c.translateRegex(test.re, "test")
+		assert.Equal(t, test.want, got, test.re)
+		if test.want == "" {
+			if assert.Error(t, err, test.re) {
+				assert.Contains(t, err.Error(), test.note, test.re)
+			}
+			continue
+		}
+		assert.NoError(t, err, test.re)
+		if test.note == "" {
+			assert.Empty(t, c.notes, test.re)
+		} else {
+			assert.Equal(t, []string{"test: " + test.note}, c.notes, test.re)
+		}
+	}
+}
+
+func TestCanMatchEmpty(t *testing.T) {
+	assert.True(t, canMatchEmpty(`a*`))
+	assert.True(t, canMatchEmpty(`^|b`))
+	assert.False(t, canMatchEmpty(`a+`))
+	assert.False(t, canMatchEmpty(`(a|bc){2}`))
+}
+
+// checkSyntax checks that a converted file is a valid micro syntax file
+func checkSyntax(t *testing.T, file string) {
+	f, err := highlight.ParseFile([]byte(file))
+	if assert.NoError(t, err) {
+		header, err := highlight.MakeHeaderYaml([]byte(file))
+		if assert.NoError(t, err) {
+			_, err = highlight.ParseDef(f, header)
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestConvertGrammar(t *testing.T) {
+	tests := []struct {
+		name    string
+		grammar string
+		// the text after "rules:" in the converted file, without the
+		// indentation of the top level
+		rules string
+		notes []string
+	}{
+		{
+			name: "patterns",
+			grammar: `{"scopeName": "source.toy", "patterns": [
+				{"name": "keyword.control.toy", "match": "\\b(if|else)\\b"},
+				{"match": "(\\w+)\\(", "captures": {"1": {"name": "entity.name.function.toy"}}},
+				{"name": "constant.numeric.toy", "match": "\\d+"},
+				{"name": "meta.unknown.toy", "match": "\\$"}
+			]}`,
+			// the first TextMate pattern wins and the last micro one does
+			rules: `
+- constant.number: "\\d+"
+- identifier.class: "(\\w+)\\("
+- statement: "\\b(if|else)\\b"`,
+		},
+		{
+			name: "captures with several groups",
+			grammar: `{"scopeName": "source.toy", "patterns": [
+				{"match": "(let) (\\w+)", "captures": {
+					"1": {"name": "keyword.toy"}, "2": {"name": "entity.name.type.toy"}}}
+			]}`,
+			rules: `
+- statement: "(let) (\\w+)"`,
+			notes: []string{`"(let) (\\w+)": captures are highlighted with the group of the first one`},
+		},
+		{
+			name: "regions",
+			grammar: `{"scopeName": "source.toy", "patterns": [
+				{"name": "string.quoted.double.toy", "begin": "\"", "end": "\"", "patterns": [
+					{"name": "constant.character.escape.toy", "match": "\\\\."}
+				]},
+				{"name": "comment.block.toy", "begin": "/\\*", "end": "\\*/"},
+				{"contentName": "meta.embedded.toy", "begin": "<%", "end": "%>",
+					"beginCaptures": {"0": {"name": "punctuation.section.embedded.toy"}}},
+				{"name": "comment.line.toy", "begin": "#", "end": "(?!x)"}
+			]}`,
+			// the escapes are the skip pattern of the string
+			rules: `
+- constant.string:
+    start: "\""
+    end: "\""
+    skip: "\\\\."
+    rules:
+        - constant.specialChar: "\\\\."
+- comment:
+    start: "/\\*"
+    end: "\\*/"
+    rules: []
+- default:
+    start: "<%"
+    end: "%>"
+    limit-group: special
+    rules: []
+- comment:
+    start: "#"
+    end: "$"
+    rules: []`,
+			notes: []string{
+				"comment.line.toy: negative lookaround assertions are removed",
+				"comment.line.toy: the region ends at the end of the line",
+			},
+		},
+		{
+			name: "nested includes",
+			grammar: `{"scopeName": "source.toy", "patterns": [{"include": "#expr"}], "repository": {
+				"expr": {"patterns": [{"include": "#number"}, {"include": "#paren"}, {"include": "#expr"}]},
+				"number": {"name": "constant.numeric.toy", "match": "\\d+"},
+				"paren": {"begin": "\\(", "end": "\\)", "patterns": [{"include": "$self"}]}
+			}}`,
+			// the list including itself is expanded once, and the region
+			// is not nested in itself
+			rules: `
+- default:
+    start: "\\("
+    end: "\\)"
+    rules:
+        - constant.number: "\\d+"
+- constant.number: "\\d+"`,
+			notes: []string{`"\\(": regions nested in themselves or more than 3 levels deep are dropped`},
+		},
+		{
+			name: "local repository and other grammars",
+			grammar: `{"scopeName": "source.toy", "patterns": [
+				{"begin": "<script>", "end": "</script>", "patterns": [{"include": "#js"}],
+					"repository": {"js": {"patterns": [{"include": "source.js"}]}}},
+				{"include": "source.toy#number"},
+				{"include": "text.html.basic#tag"}
+			], "repository": {"number": {"name": "constant.numeric.toy", "match": "\\d+"}}}`,
+			// the grammar is not expanded again where it includes itself
+			rules: `
+- include: "html"
+- default:
+    start: "<script>"
+    end: "</script>"
+    rules:
+        - include: "javascript"`,
+			notes: []string{
+				"include source.toy#number: only whole grammars can be included",
+				"include text.html.basic#tag: only whole grammars can be included",
+			},
+		},
+		{
+			name: "unsupported constructs",
+			grammar: `{"scopeName": "source.toy", "patterns": [
+				{"name": "string.heredoc.toy", "begin": "<<(\\w+)", "end": "^\\1$"},
+				{"name": "comment.line.toy", "begin": "^>", "while": "^>"},
+				{"name": "string.toy", "begin": "x*", "end": "y"},
+				{"name": "keyword.toy", "match": "(?!a)"},
+				{"include": "#missing"}
+			]}`,
+			rules: ` []`,
+			notes: []string{
+				"string.heredoc.toy: dropped: end: backreferences are not supported",
+				"comment.line.toy: begin/while rules are not supported",
+				"string.toy: dropped: the begin regex can match an empty string",
+				"keyword.toy: negative lookaround assertions are removed",
+				"keyword.toy: dropped: the regex only has assertions",
+				"include #missing: no such rule in the repository",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var g tmGrammar
+			if !assert.NoError(t, json.Unmarshal([]byte(test.grammar), &g)) {
+				return
+			}
+			c := newConverter(&g)
+			file := generateFile("toy", c)
+			checkSyntax(t, file)
+
+			_, rules, _ := strings.Cut(file, "rules:")
+			rules = strings.TrimPrefix(rules, "\n")
+			want := strings.ReplaceAll(test.rules, "\n", "\n    ")
+			assert.Equal(t, strings.TrimPrefix(want, "\n"), strings.TrimSuffix(rules, "\n"))
+			assert.Equal(t, test.notes, c.notes)
+		})
+	}
+}
+
+func TestConvertDetect(t *testing.T) {
+	g := &tmGrammar{
+		ScopeName:      "source.toy",
+		FileTypes:      []string{"toy", "t.y", "Toyfile", ""},
+		FirstLineMatch: `^#!.*\btoy\b`,
+	}
+	file := generateFile("toy", newConverter(g))
+	assert.Equal(t, `filetype: toy
+
+detect:
+    filename: "\\.(toy)$|(^|/)(t\\.y|Toyfile)$"
+    header: "^#!.*\\btoy\\b"
+
+rules: []
+`, file)
+}
+
+func TestConvertSublime(t *testing.T) {
+	_, c, err := convertSublime([]byte(`%YAML 1.2
+---
+file_extensions: [toy]
+scope: source.toy
+variables:
+  ident: '[a-z]+'
+contexts:
+  prototype:
+    - match: '#.*'
+      scope: comment.line.toy
+  main:
+    - match: '\b(let)\b'
+      scope: keyword.declaration.toy
+    - match: '"'
+      scope: punctuation.definition.string.begin.toy
+      push: string
+    - match: '{{ident}}\('
+      scope: entity.name.function.toy
+    - match: '\['
+      push:
+        - meta_scope: meta.list.toy
+        - match: '\]'
+          pop: true
+    - match: '<'
+      push: undefined
+  string:
+    - meta_include_prototype: false
+    - meta_scope: string.quoted.double.toy
+    - match: '\\.'
+      scope: constant.character.escape.toy
+    - match: '"'
+      pop: true
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	file := generateFile("toy", c)
+	checkSyntax(t, file)
+	assert.Equal(t, `filetype: toy
+
+detect:
+    filename: "\\.(toy)$"
+
+rules:
+    - constant.string:
+        start: "\""
+        end: "\""
+        skip: "\\\\."
+        rules:
+            - constant.specialChar: "\\\\."
+    - default:
+        start: "\\["
+        end: "\\]"
+        rules:
+            - comment: "#.*"
+    - default:
+        start: "<"
+        end: "$"
+        rules: []
+    - identifier.class: "[a-z]+\\("
+    - statement: "\\b(let)\\b"
+    - comment: "#.*"
+`, file)
+	assert.Equal(t, []string{
+		"context undefined: the context is not defined in this file",
+		"include #undefined: no such rule in the repository",
+	}, c.notes)
+}